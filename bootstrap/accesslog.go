@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// storageAnalyticsGroup writes GCS usage and storage logs; it needs to create objects in the log bucket
+const storageAnalyticsGroup = "group:cloud-storage-analytics@google.com"
+
+// logBucketName returns the bucket receiving the state bucket's usage and storage logs
+func (c *Config) logBucketName() string {
+	if c.TFStateBucketAccessLogging.LogBucket != "" {
+		return c.TFStateBucketAccessLogging.LogBucket
+	}
+	return c.TFStateBucketName + "-logs"
+}
+
+// logObjectPrefix returns the prefix of the log objects written for the state bucket
+func (c *Config) logObjectPrefix() string {
+	if c.TFStateBucketAccessLogging.ObjectPrefix != "" {
+		return c.TFStateBucketAccessLogging.ObjectPrefix
+	}
+	return c.TFStateBucketName
+}
+
+// setupBucketAccessLogging creates the log bucket and enables usage and storage logging on the
+// state bucket, and optionally Data Access audit logs for Cloud Storage, so reads of the state
+// can be audited
+func setupBucketAccessLogging(ctx context.Context, cfg *Config) error {
+	al := cfg.TFStateBucketAccessLogging
+	if !al.Enabled {
+		logInfo("Skipping state bucket access logging as per config.")
+		return nil
+	}
+	logURL := fmt.Sprintf("gs://%s", cfg.logBucketName())
+	exists, err := bucketExists(ctx, cfg.logBucketName(), cfg.seedProject())
+	if err != nil {
+		return err
+	}
+	if exists {
+		logInfo("Log bucket '%s' already exists.", logURL)
+		metrics.recordResource("bucket", logURL, resourceExisted)
+	} else {
+		logInfo("Creating log bucket '%s'...", logURL)
+		if err := runCommand(ctx, "gcloud", logBucketCreateArgs(cfg)...); err != nil {
+			return fmt.Errorf("failed to create log bucket: %w", err)
+		}
+		metrics.recordResource("bucket", logURL, resourceCreated)
+	}
+
+	logInfo("Allowing Cloud Storage analytics to write logs to '%s'...", logURL)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "add-iam-policy-binding", logURL,
+		"--member", storageAnalyticsGroup,
+		"--role", "roles/storage.objectCreator",
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to grant Cloud Storage analytics access to the log bucket: %w", err)
+	}
+
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	logInfo("Enabling usage and storage logging on '%s'...", bucketURL)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL,
+		"--log-bucket", logURL,
+		"--log-object-prefix", cfg.logObjectPrefix(),
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to enable usage logging: %w", err)
+	}
+	logInfo("Usage and storage logs of '%s' are written to '%s'.", bucketURL, logURL)
+
+	if al.DataAccessLogs {
+		return enableStorageDataAccessLogs(ctx, cfg)
+	}
+	return nil
+}
+
+// logBucketCreateArgs returns the gcloud arguments creating the log bucket next to the state bucket
+func logBucketCreateArgs(cfg *Config) []string {
+	return []string{"storage", "buckets", "create", fmt.Sprintf("gs://%s", cfg.logBucketName()),
+		"--project", cfg.seedProject(),
+		"--location", cfg.TFStateBucketLocation,
+		"--uniform-bucket-level-access",
+		"--public-access-prevention"}
+}
+
+// enableStorageDataAccessLogs adds DATA_READ and DATA_WRITE audit logging for
+// storage.googleapis.com to the project's IAM policy unless they are already logged
+func enableStorageDataAccessLogs(ctx context.Context, cfg *Config) error {
+	changed, err := ensureAuditLogConfigs(ctx, cfg.seedProject(), map[string][]string{"storage.googleapis.com": {"DATA_READ", "DATA_WRITE"}})
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		logInfo("Data Access audit logs for Cloud Storage are already enabled.")
+		return nil
+	}
+	logInfo("Data Access audit logs for Cloud Storage enabled on project '%s'; they are billed as Cloud Logging ingestion.", cfg.seedProject())
+	return nil
+}
+
+func planBucketAccessLogging(cfg *Config) []planAction {
+	if !cfg.TFStateBucketAccessLogging.Enabled {
+		return nil
+	}
+	logURL := fmt.Sprintf("gs://%s", cfg.logBucketName())
+	actions := []planAction{
+		{Description: fmt.Sprintf("Create log bucket '%s' if it does not exist", logURL), Command: append([]string{"gcloud"}, logBucketCreateArgs(cfg)...)},
+		{Description: "Allow Cloud Storage analytics to write logs to the log bucket", Command: []string{"gcloud", "storage", "buckets", "add-iam-policy-binding", logURL, "--member", storageAnalyticsGroup, "--role", "roles/storage.objectCreator", "--project", cfg.seedProject()}},
+		{Description: "Enable usage and storage logging on the state bucket", Command: []string{"gcloud", "storage", "buckets", "update", "gs://" + cfg.TFStateBucketName, "--log-bucket", logURL, "--log-object-prefix", cfg.logObjectPrefix(), "--project", cfg.seedProject()}},
+	}
+	if cfg.TFStateBucketAccessLogging.DataAccessLogs {
+		actions = append(actions, planAction{
+			Description: "Add DATA_READ and DATA_WRITE audit logging for storage.googleapis.com to the project IAM policy unless already logged",
+			Command:     []string{"gcloud", "projects", "set-iam-policy", cfg.seedProject(), "<updated policy>"},
+		})
+	}
+	return actions
+}
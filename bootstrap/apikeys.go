@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// apiKeyIDPattern matches the key IDs the API Keys API accepts
+var apiKeyIDPattern = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// validateAPIKeys checks that every key in api_keys is restricted to APIs and to at most
+// one kind of application, as the API Keys API allows
+func validateAPIKeys(cfg *Config) error {
+	seen := map[string]bool{}
+	for _, k := range cfg.APIKeys {
+		if !apiKeyIDPattern.MatchString(k.ID) {
+			return fmt.Errorf("api_keys id '%s' must be 1-63 lowercase letters, digits or hyphens, starting with a letter", k.ID)
+		}
+		if seen[k.ID] {
+			return fmt.Errorf("api_keys id '%s' is listed twice", k.ID)
+		}
+		seen[k.ID] = true
+		if len(k.APITargets) == 0 {
+			return fmt.Errorf("api_keys '%s' lists no api_targets; unrestricted keys are not created", k.ID)
+		}
+		kinds := 0
+		for _, n := range []int{len(k.AllowedReferrers), len(k.AllowedIPs), len(k.AllowedBundleIDs), len(k.AllowedAndroidApps)} {
+			if n > 0 {
+				kinds++
+			}
+		}
+		if kinds > 1 {
+			return fmt.Errorf("api_keys '%s' may only set one of allowed_referrers, allowed_ips, allowed_bundle_ids and allowed_android_apps", k.ID)
+		}
+		if kinds == 0 {
+			logWarning("API key '%s' has no application restriction; anyone holding it can call %s.", k.ID, strings.Join(k.APITargets, ", "))
+		}
+		for _, app := range k.AllowedAndroidApps {
+			if app.PackageName == "" || app.SHA1 == "" {
+				return fmt.Errorf("api_keys '%s' allowed_android_apps entries need package_name and sha1", k.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// createArgs returns the gcloud arguments creating the key in projectID
+func (k APIKeyConfig) createArgs(projectID string) []string {
+	args := []string{"services", "api-keys", "create", "--key-id", k.ID, "--project", projectID}
+	if k.DisplayName != "" {
+		args = append(args, "--display-name", k.DisplayName)
+	}
+	for _, target := range k.APITargets {
+		args = append(args, "--api-target=service="+target)
+	}
+	if len(k.AllowedReferrers) > 0 {
+		args = append(args, "--allowed-referrers="+strings.Join(k.AllowedReferrers, ","))
+	}
+	if len(k.AllowedIPs) > 0 {
+		args = append(args, "--allowed-ips="+strings.Join(k.AllowedIPs, ","))
+	}
+	if len(k.AllowedBundleIDs) > 0 {
+		args = append(args, "--allowed-bundle-ids="+strings.Join(k.AllowedBundleIDs, ","))
+	}
+	for _, app := range k.AllowedAndroidApps {
+		args = append(args, fmt.Sprintf("--allowed-application=sha1_fingerprint=%s,package_name=%s", app.SHA1, app.PackageName))
+	}
+	return args
+}
+
+// createAPIKeys creates the restricted API keys listed under api_keys. Existing keys are
+// left as they are; the key strings are never printed or stored.
+func createAPIKeys(ctx context.Context, cfg *Config) error {
+	if len(cfg.APIKeys) == 0 {
+		logInfo("Skipping API key creation as per config.")
+		return nil
+	}
+	if err := ensureServicesEnabled(ctx, cfg.ProjectID, []string{"apikeys.googleapis.com"}); err != nil {
+		return fmt.Errorf("failed to enable the API Keys API: %w", err)
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "services", "api-keys", "list", "--project", cfg.ProjectID, "--format=value(name)")
+	if err != nil {
+		return fmt.Errorf("failed to list the API keys of '%s': %w", cfg.ProjectID, err)
+	}
+	existing := map[string]bool{}
+	for _, name := range strings.Fields(output) {
+		existing[path.Base(name)] = true
+	}
+	for _, k := range cfg.APIKeys {
+		if existing[k.ID] {
+			logInfo("API key '%s' already exists; its restrictions are not changed.", k.ID)
+			metrics.recordResource("api_key", k.ID, resourceExisted)
+			continue
+		}
+		logInfo("Creating API key '%s' restricted to %s...", k.ID, strings.Join(k.APITargets, ", "))
+		if err := runCommand(ctx, "gcloud", k.createArgs(cfg.ProjectID)...); err != nil {
+			return fmt.Errorf("failed to create API key '%s': %w", k.ID, err)
+		}
+		metrics.recordResource("api_key", k.ID, resourceCreated)
+	}
+	logInfo("API keys ready; read a key with 'gcloud services api-keys get-key-string <id> --project %s'.", cfg.ProjectID)
+	return nil
+}
+
+func planAPIKeys(cfg *Config) []planAction {
+	if len(cfg.APIKeys) == 0 {
+		return nil
+	}
+	actions := []planAction{{
+		Description: "Enable the API Keys API",
+		Command:     []string{"gcloud", "services", "enable", "apikeys.googleapis.com", "--project", cfg.ProjectID},
+	}}
+	for _, k := range cfg.APIKeys {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Create API key '%s' restricted to %s unless it exists", k.ID, strings.Join(k.APITargets, ", ")),
+			Command:     append([]string{"gcloud"}, k.createArgs(cfg.ProjectID)...),
+		})
+	}
+	return actions
+}
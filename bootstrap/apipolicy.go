@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// requestedAPIs returns every API the bootstrap enables for cfg: enable_apis plus the
+// APIs enabled by optional steps
+func requestedAPIs(cfg *Config) []string {
+	apis := slices.Clone(cfg.EnableAPIs)
+	if cfg.WIF.enabled() {
+		apis = append(apis, wifRequiredAPIs...)
+	}
+	if cfg.Fleet.Enabled {
+		apis = append(apis, "gkehub.googleapis.com")
+	}
+	if cfg.KMS.Enabled {
+		apis = append(apis, "cloudkms.googleapis.com")
+	}
+	if cfg.BillingExport.Enabled && cfg.BillingExport.project(cfg) == cfg.ProjectID {
+		apis = append(apis, "bigquery.googleapis.com", "bigquerydatatransfer.googleapis.com")
+	}
+	if cfg.Budget.enabled() {
+		apis = append(apis, "billingbudgets.googleapis.com")
+	}
+	if len(cfg.EssentialContacts) > 0 {
+		apis = append(apis, "essentialcontacts.googleapis.com")
+	}
+	if cfg.EnableFirebase {
+		apis = append(apis, "firebase.googleapis.com")
+	}
+	if len(cfg.APIKeys) > 0 {
+		apis = append(apis, "apikeys.googleapis.com")
+	}
+	slices.Sort(apis)
+	return slices.Compact(apis)
+}
+
+// matchesAPI reports whether api matches one of patterns, which may use path.Match
+// wildcards such as "*.googleapis.com"
+func matchesAPI(patterns []string, api string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, api); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAPIPolicy checks the APIs the config enables against api_allowlist and
+// api_denylist, so a config handed to an app team cannot enable unapproved services
+func validateAPIPolicy(cfg *Config) error {
+	for _, p := range slices.Concat(cfg.APIAllowlist, cfg.APIDenylist) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("api_allowlist/api_denylist entry '%s' is not a valid pattern", p)
+		}
+	}
+	var denied []string
+	for _, api := range requestedAPIs(cfg) {
+		if matchesAPI(cfg.APIDenylist, api) || (len(cfg.APIAllowlist) > 0 && !matchesAPI(cfg.APIAllowlist, api)) {
+			denied = append(denied, api)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("API(s) not approved by api_allowlist/api_denylist: %s (enabled via enable_apis or by the wif, fleet or kms settings)", strings.Join(denied, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// assumableStates maps the step IDs --assume accepts to the state they can be assumed in
+var assumableStates = map[string]string{
+	"project":         "exists",
+	"billing":         "linked",
+	"apis":            "enabled",
+	"service_account": "exists",
+	"bucket":          "exists",
+}
+
+// parseAssumptions parses --assume, e.g. "project=exists,billing=linked"
+func parseAssumptions(s string) (map[string]string, error) {
+	assumptions := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		key, state, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		want, ok := assumableStates[key]
+		if !ok {
+			return nil, fmt.Errorf("cannot assume the state of '%s' (supported: %s)", key, strings.Join(assumableList(), ", "))
+		}
+		if state != want {
+			return nil, fmt.Errorf("'%s' can only be assumed as '%s=%s', got '%s'", key, key, want, kv)
+		}
+		assumptions[key] = state
+	}
+	return assumptions, nil
+}
+
+// assumableList returns the supported assumptions as key=state, sorted
+func assumableList() []string {
+	var list []string
+	for key, state := range assumableStates {
+		list = append(list, key+"="+state)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// assumes reports whether --assume declared the resource of step id to be in place, so the
+// plan leaves out its creation
+func (c *Config) assumes(id string) bool {
+	return c.Assumptions[id] != ""
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Finding severities; any finding makes the audit fail
+const (
+	severityHigh   = "HIGH"
+	severityMedium = "MEDIUM"
+)
+
+// auditFinding is a deviation from the expected secure configuration
+type auditFinding struct {
+	Severity string
+	Resource string
+	Message  string
+}
+
+// runAuditCommand implements 'audit': it checks the resources of an existing bootstrap
+// against the expected lockdown and exits non-zero if any finding is reported
+func runAuditCommand(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	credentialsFile := fs.String("credentials-file", "", "Authenticate gcloud with this credential JSON file instead of the active gcloud account")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap audit [-config FILE] [-env NAME]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	checkGcloud(ctx, *credentialsFile)
+	cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+	if err != nil {
+		logError("Failed to load configuration: %v", err)
+	}
+	if err := resolveExistingProjectID(ctx, cfg); err != nil {
+		logError("%v", err)
+	}
+
+	findings, err := auditStateBucket(ctx, cfg)
+	if err != nil {
+		logError("Audit failed: %v", err)
+	}
+	metadataFindings, err := auditSAMetadata(ctx, cfg)
+	if err != nil {
+		logError("Audit failed: %v", err)
+	}
+	findings = append(findings, metadataFindings...)
+	for _, f := range findings {
+		color := colorRed
+		if f.Severity != severityHigh {
+			color = colorYellow
+		}
+		fmt.Fprintf(stdout, "[%s] %s: %s\n", colorize(color, f.Severity), f.Resource, f.Message)
+	}
+	if len(findings) > 0 {
+		logError("Audit found %d finding(s).", len(findings))
+	}
+	logNotice("Audit passed: no findings.")
+}
+
+// bucketSecurity holds the security-relevant attributes of a bucket as reported by gcloud
+type bucketSecurity struct {
+	UniformBucketLevelAccess bool   `json:"uniform_bucket_level_access"`
+	PublicAccessPrevention   string `json:"public_access_prevention"`
+}
+
+// iamPolicy is the subset of an IAM policy needed to inspect its members
+type iamPolicy struct {
+	Bindings []struct {
+		Role      string   `json:"role"`
+		Members   []string `json:"members"`
+		Condition *struct {
+			Title      string `json:"title"`
+			Expression string `json:"expression,omitempty"`
+		} `json:"condition,omitempty"`
+	} `json:"bindings"`
+}
+
+// auditStateBucket verifies that the state bucket uses uniform bucket-level access,
+// enforces public access prevention and grants nothing to allUsers/allAuthenticatedUsers
+func auditStateBucket(ctx context.Context, cfg *Config) ([]auditFinding, error) {
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	logInfo("Auditing state bucket '%s'...", bucketURL)
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", bucketURL, "--format=json", "--project", cfg.seedProject())
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe bucket '%s': %w", bucketURL, err)
+	}
+	var bucket bucketSecurity
+	if err := json.Unmarshal([]byte(output), &bucket); err != nil {
+		return nil, fmt.Errorf("failed to parse description of bucket '%s': %w", bucketURL, err)
+	}
+
+	var findings []auditFinding
+	if !bucket.UniformBucketLevelAccess {
+		findings = append(findings, auditFinding{severityHigh, bucketURL, "uniform bucket-level access is disabled; legacy object ACLs can expose state files (set tf_state_bucket_repair_ubla to migrate)"})
+	}
+	if bucket.PublicAccessPrevention != "enforced" {
+		findings = append(findings, auditFinding{severityHigh, bucketURL, fmt.Sprintf("public access prevention is '%s', expected 'enforced'", bucket.PublicAccessPrevention)})
+	}
+
+	output, err = runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "get-iam-policy", bucketURL, "--format=json", "--project", cfg.seedProject())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IAM policy of bucket '%s': %w", bucketURL, err)
+	}
+	var policy iamPolicy
+	if err := json.Unmarshal([]byte(output), &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse IAM policy of bucket '%s': %w", bucketURL, err)
+	}
+	for _, b := range policy.Bindings {
+		for _, m := range b.Members {
+			if m == "allUsers" || m == "allAuthenticatedUsers" {
+				findings = append(findings, auditFinding{severityHigh, bucketURL, fmt.Sprintf("'%s' is granted '%s'; state files are publicly readable", m, b.Role)})
+			}
+		}
+	}
+	return findings, nil
+}
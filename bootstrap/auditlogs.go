@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// auditLogTypes are the Data Access and Admin Read log types an audit config can enable
+var auditLogTypes = []string{"ADMIN_READ", "DATA_READ", "DATA_WRITE"}
+
+// validateAuditLogs normalizes audit_logs: short service names get the .googleapis.com
+// suffix and log types are upper-cased and checked
+func validateAuditLogs(cfg *Config) error {
+	normalized := map[string][]string{}
+	for service, types := range cfg.AuditLogs {
+		if service != "allServices" && !strings.Contains(service, ".") {
+			service += ".googleapis.com"
+		}
+		if len(types) == 0 {
+			return fmt.Errorf("audit_logs service '%s' lists no log types (valid: %s)", service, strings.Join(auditLogTypes, ", "))
+		}
+		for _, t := range types {
+			t = strings.ToUpper(t)
+			if !slices.Contains(auditLogTypes, t) {
+				return fmt.Errorf("audit_logs service '%s' has invalid log type '%s' (valid: %s)", service, t, strings.Join(auditLogTypes, ", "))
+			}
+			if !slices.Contains(normalized[service], t) {
+				normalized[service] = append(normalized[service], t)
+			}
+		}
+		slices.Sort(normalized[service])
+	}
+	cfg.AuditLogs = normalized
+	return nil
+}
+
+// ensureAuditLogConfigs adds the wanted log types per service to the audit configs of the
+// project's IAM policy. Types already logged for the service or for allServices are left
+// as they are, as are exempted members. It returns the services that were changed.
+func ensureAuditLogConfigs(ctx context.Context, projectID string, wanted map[string][]string) ([]string, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "get-iam-policy", projectID, "--format=json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the IAM policy of '%s': %w", projectID, err)
+	}
+	var policy map[string]any
+	if err := json.Unmarshal([]byte(output), &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse the IAM policy of '%s': %w", projectID, err)
+	}
+	auditConfigs, _ := policy["auditConfigs"].([]any)
+	logged := func(service, logType string) bool {
+		for _, ac := range auditConfigs {
+			ac, _ := ac.(map[string]any)
+			if ac["service"] != service && ac["service"] != "allServices" {
+				continue
+			}
+			logConfigs, _ := ac["auditLogConfigs"].([]any)
+			for _, lc := range logConfigs {
+				if lc, _ := lc.(map[string]any); lc["logType"] == logType {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var changed []string
+	for _, service := range sortedKeys(wanted) {
+		var missing []any
+		for _, t := range wanted[service] {
+			if !logged(service, t) {
+				missing = append(missing, map[string]any{"logType": t})
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		changed = append(changed, service)
+		i := slices.IndexFunc(auditConfigs, func(v any) bool {
+			ac, _ := v.(map[string]any)
+			return ac["service"] == service
+		})
+		if i < 0 {
+			auditConfigs = append(auditConfigs, map[string]any{"service": service, "auditLogConfigs": missing})
+			continue
+		}
+		ac := auditConfigs[i].(map[string]any)
+		logConfigs, _ := ac["auditLogConfigs"].([]any)
+		ac["auditLogConfigs"] = append(logConfigs, missing...)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	if err := snapshotProjectIAM(ctx, projectID); err != nil {
+		return nil, err
+	}
+	policy["auditConfigs"] = auditConfigs
+	if err := setProjectIAMPolicy(ctx, projectID, policy); err != nil {
+		return nil, fmt.Errorf("failed to update audit logging: %w", err)
+	}
+	return changed, nil
+}
+
+// setProjectIAMPolicy replaces the IAM policy of projectID. The policy's etag makes this
+// fail rather than overwrite a concurrent change.
+func setProjectIAMPolicy(ctx context.Context, projectID string, policy map[string]any) error {
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to render the IAM policy: %w", err)
+	}
+	tmp, err := os.CreateTemp("", "iam-policy-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create IAM policy file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write IAM policy file: %w", err)
+	}
+	return runCommand(ctx, "gcloud", "projects", "set-iam-policy", projectID, tmp.Name(), "--format=none")
+}
+
+// configureAuditLogs enables the log types listed under audit_logs on the project
+func configureAuditLogs(ctx context.Context, cfg *Config) error {
+	if len(cfg.AuditLogs) == 0 {
+		logInfo("Skipping audit log configuration as per config.")
+		return nil
+	}
+	logInfo("Configuring audit logs for %s on project '%s'...", strings.Join(sortedKeys(cfg.AuditLogs), ", "), cfg.ProjectID)
+	changed, err := ensureAuditLogConfigs(ctx, cfg.ProjectID, cfg.AuditLogs)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		logInfo("Audit logs are already configured.")
+		return nil
+	}
+	logInfo("Audit logs enabled for %s; Data Access logs are billed as Cloud Logging ingestion.", strings.Join(changed, ", "))
+	return nil
+}
+
+func planAuditLogs(cfg *Config) []planAction {
+	var actions []planAction
+	for _, service := range sortedKeys(cfg.AuditLogs) {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Add %s audit logging for %s to the project IAM policy unless already logged", strings.Join(cfg.AuditLogs[service], ", "), service),
+			Command:     []string{"gcloud", "projects", "set-iam-policy", cfg.ProjectID, "<updated policy>"},
+		})
+	}
+	return actions
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Backstage catalog entry defaults
+const (
+	defaultBackstagePath = "catalog-info.yaml"
+	defaultBackstageKind = "Resource"
+	defaultBackstageType = "gcp-project"
+)
+
+// validateBackstage fills in the defaults of the Backstage catalog entry
+func validateBackstage(cfg *Config) error {
+	bs := &cfg.Backstage
+	if !bs.Enabled {
+		return nil
+	}
+	if bs.Path == "" {
+		bs.Path = defaultBackstagePath
+	}
+	if bs.Kind == "" {
+		bs.Kind = defaultBackstageKind
+	}
+	if bs.Kind != "Resource" && bs.Kind != "Component" {
+		return fmt.Errorf("backstage.kind must be Resource or Component, got '%s'", bs.Kind)
+	}
+	if bs.Type == "" {
+		bs.Type = defaultBackstageType
+		if bs.Kind == "Component" {
+			bs.Type = "service"
+		}
+	}
+	if bs.Owner == "" {
+		bs.Owner = cfg.TFServiceAccountMetadata.Owner
+	}
+	if bs.Owner == "" {
+		return fmt.Errorf("backstage.owner is not set (e.g. group:platform-team) and tf_service_account_metadata.owner is empty")
+	}
+	if bs.Lifecycle == "" {
+		bs.Lifecycle = "experimental"
+		if cfg.isProduction() {
+			bs.Lifecycle = "production"
+		}
+	}
+	return nil
+}
+
+// renderBackstageCatalog renders the Backstage catalog entry of the project, so it appears
+// in the developer portal right after the bootstrap
+func renderBackstageCatalog(cfg *Config) string {
+	bs := cfg.Backstage
+	var b strings.Builder
+	b.WriteString(generatedFileHeader)
+	b.WriteString("apiVersion: backstage.io/v1alpha1\n")
+	fmt.Fprintf(&b, "kind: %s\n", bs.Kind)
+	b.WriteString("metadata:\n")
+	fmt.Fprintf(&b, "  name: %q\n", cfg.ProjectID)
+	fmt.Fprintf(&b, "  title: %q\n", cfg.ProjectName)
+	fmt.Fprintf(&b, "  description: %q\n", fmt.Sprintf("GCP project %s bootstrapped by gcp-bootstrap", cfg.ProjectID))
+	b.WriteString("  annotations:\n")
+	fmt.Fprintf(&b, "    cloud.google.com/project-id: %q\n", cfg.ProjectID)
+	fmt.Fprintf(&b, "    cloud.google.com/terraform-state-bucket: %q\n", "gs://"+cfg.TFStateBucketName)
+	fmt.Fprintf(&b, "    cloud.google.com/terraform-service-account: %q\n", cfg.TFServiceAccountEmail)
+	if len(bs.Tags) > 0 {
+		b.WriteString("  tags:\n")
+		for _, tag := range bs.Tags {
+			fmt.Fprintf(&b, "    - %q\n", tag)
+		}
+	}
+	b.WriteString("  links:\n")
+	links := [][2]string{
+		{"GCP Console", "https://console.cloud.google.com/home/dashboard?project=" + cfg.ProjectID},
+		{"Terraform state bucket", fmt.Sprintf("https://console.cloud.google.com/storage/browser/%s?project=%s", cfg.TFStateBucketName, cfg.seedProject())},
+		{"IAM", "https://console.cloud.google.com/iam-admin/iam?project=" + cfg.ProjectID},
+	}
+	for _, l := range links {
+		fmt.Fprintf(&b, "    - title: %q\n      url: %q\n", l[0], l[1])
+	}
+	b.WriteString("spec:\n")
+	fmt.Fprintf(&b, "  type: %q\n", bs.Type)
+	fmt.Fprintf(&b, "  owner: %q\n", bs.Owner)
+	if bs.Kind == "Component" {
+		fmt.Fprintf(&b, "  lifecycle: %q\n", bs.Lifecycle)
+	}
+	if bs.System != "" {
+		fmt.Fprintf(&b, "  system: %q\n", bs.System)
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Billing link retry defaults; a freshly provisioned billing account can take minutes to accept links
+const (
+	defaultBillingLinkAttempts = 6
+	defaultBillingLinkBackoff  = 10 * time.Second
+	maxBillingLinkBackoff      = 2 * time.Minute
+)
+
+// cloudBillingAPI is the Cloud Billing API endpoint; gcloud has no command creating subaccounts
+const cloudBillingAPI = "https://cloudbilling.googleapis.com/v1"
+
+// billingAccount is the part of a Cloud Billing account resource the tool reads
+type billingAccount struct {
+	Name                 string `json:"name"` // billingAccounts/ID
+	DisplayName          string `json:"displayName"`
+	MasterBillingAccount string `json:"masterBillingAccount"`
+	Open                 bool   `json:"open"`
+}
+
+// linkBillingWithRetry links the project to billingAccountID, retrying failures that can
+// be transient for a just-provisioned account with exponential backoff
+func linkBillingWithRetry(ctx context.Context, cfg *Config, billingAccountID string) error {
+	bl := cfg.BillingLink
+	backoff := bl.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := runCommand(ctx, "gcloud", "beta", "billing", "projects", "link", cfg.ProjectID, "--billing-account", billingAccountID)
+		if err == nil {
+			return nil
+		}
+		if strings.Contains(err.Error(), "already associated") {
+			return err
+		}
+		if strings.Contains(strings.ToLower(err.Error()), "quota") {
+			return fmt.Errorf("billing account '%s' has reached its project quota; request an increase or use another billing account: %w", billingAccountID, err)
+		}
+		if attempt >= bl.Attempts || ctx.Err() != nil {
+			return fmt.Errorf("billing link failed after %d attempt(s): %w", attempt, err)
+		}
+		logWarning("Billing link attempt %d of %d failed (%s); the billing account may still be provisioning. Retrying in %s...", attempt, bl.Attempts, errorClass(err.Error()), backoff)
+		if err := waitForPropagation(ctx, fmt.Sprintf("billing link retry %d/%d", attempt+1, bl.Attempts), backoff); err != nil {
+			return err
+		}
+		backoff = min(backoff*2, maxBillingLinkBackoff)
+	}
+}
+
+// resolveBillingSubaccount returns the ID of the subaccount of billing_account_id with the
+// configured display name, creating it if there is none
+func resolveBillingSubaccount(ctx context.Context, cfg *Config) (string, error) {
+	sub := cfg.BillingLink.Subaccount
+	master := "billingAccounts/" + cfg.BillingAccountID
+	output, err := runCommandGetOutput(ctx, "gcloud", "billing", "accounts", "list",
+		"--filter", fmt.Sprintf("masterBillingAccount=%s", master),
+		"--format=json(name,displayName,masterBillingAccount,open)")
+	if err != nil {
+		return "", fmt.Errorf("failed to list subaccounts of '%s': %w", cfg.BillingAccountID, err)
+	}
+	var accounts []billingAccount
+	if err := json.Unmarshal([]byte(output), &accounts); err != nil {
+		return "", fmt.Errorf("failed to parse billing accounts: %w", err)
+	}
+	for _, a := range accounts {
+		if a.MasterBillingAccount == master && a.DisplayName == sub.DisplayName {
+			id := strings.TrimPrefix(a.Name, "billingAccounts/")
+			logInfo("Using existing billing subaccount '%s' (%s).", sub.DisplayName, id)
+			metrics.recordResource("billing_subaccount", id, resourceExisted)
+			return id, nil
+		}
+	}
+
+	logInfo("Creating billing subaccount '%s' under '%s'...", sub.DisplayName, cfg.BillingAccountID)
+	created, err := createBillingSubaccount(ctx, master, sub.DisplayName)
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimPrefix(created.Name, "billingAccounts/")
+	logInfo("Billing subaccount '%s' created (%s).", sub.DisplayName, id)
+	metrics.recordResource("billing_subaccount", id, resourceCreated)
+	return id, nil
+}
+
+// createBillingSubaccount creates a subaccount of master through the Cloud Billing API,
+// authenticated with gcloud's access token
+func createBillingSubaccount(ctx context.Context, master, displayName string) (*billingAccount, error) {
+	body, err := json.Marshal(billingAccount{DisplayName: displayName, MasterBillingAccount: master})
+	if err != nil {
+		return nil, err
+	}
+	if fakeGCP != nil {
+		return fakeGCP.createBillingSubaccount(master, displayName)
+	}
+	token, err := runCommandGetOutput(ctx, "gcloud", "auth", "print-access-token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get an access token: %w", err)
+	}
+	logInfo("Executing: POST %s/billingAccounts", cloudBillingAPI)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cloudBillingAPI+"/billingAccounts", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create billing subaccount: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("failed to create billing subaccount: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var created billingAccount
+	if err := json.Unmarshal(data, &created); err != nil || created.Name == "" {
+		return nil, fmt.Errorf("failed to parse the created billing subaccount: %s", strings.TrimSpace(string(data)))
+	}
+	return &created, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// bigQueryAPI is the BigQuery REST endpoint; gcloud has no GA command managing datasets
+const bigQueryAPI = "https://bigquery.googleapis.com/bigquery/v2"
+
+// Billing export defaults
+const (
+	defaultBillingExportDataset  = "billing_export"
+	defaultBillingExportLocation = "US"
+)
+
+// billingExportTablePrefixes are the prefixes of the tables Cloud Billing writes the
+// standard and detailed (resource-level) usage cost exports to
+var billingExportTablePrefixes = []string{"gcp_billing_export_v1_", "gcp_billing_export_resource_v1_"}
+
+// bigQueryDatasetPattern matches BigQuery dataset IDs
+var bigQueryDatasetPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// project returns the project holding the export dataset
+func (b BillingExportConfig) project(cfg *Config) string {
+	if b.ProjectID != "" {
+		return b.ProjectID
+	}
+	return cfg.ProjectID
+}
+
+// validateBillingExport fills in the billing export defaults
+func validateBillingExport(cfg *Config) error {
+	be := &cfg.BillingExport
+	if !be.Enabled {
+		return nil
+	}
+	if be.DatasetID == "" {
+		be.DatasetID = defaultBillingExportDataset
+	}
+	if !bigQueryDatasetPattern.MatchString(be.DatasetID) {
+		return fmt.Errorf("billing_export.dataset_id '%s' may only contain letters, digits and underscores", be.DatasetID)
+	}
+	if be.Location == "" {
+		be.Location = defaultBillingExportLocation
+	}
+	return nil
+}
+
+// billingExportConsoleURL is where the export is switched on; Cloud Billing has no API for it
+func billingExportConsoleURL(billingAccountID string) string {
+	return "https://console.cloud.google.com/billing/" + billingAccountID + "/export"
+}
+
+// setupBillingExport creates the BigQuery dataset for the billing export and reports
+// whether Cloud Billing already writes to it. The export itself can only be configured in
+// the console, so a missing export is reported with the link to configure it.
+func setupBillingExport(ctx context.Context, cfg *Config) error {
+	be := cfg.BillingExport
+	if !be.Enabled {
+		logInfo("Skipping billing export setup as per config.")
+		return nil
+	}
+	project := be.project(cfg)
+	if err := ensureServicesEnabled(ctx, project, []string{"bigquery.googleapis.com", "bigquerydatatransfer.googleapis.com"}); err != nil {
+		return fmt.Errorf("failed to enable the BigQuery APIs: %w", err)
+	}
+	dataset := project + ":" + be.DatasetID
+	exists, err := bigQueryDatasetExists(ctx, project, be.DatasetID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		logInfo("BigQuery dataset '%s' already exists.", dataset)
+		metrics.recordResource("bigquery_dataset", dataset, resourceExisted)
+	} else {
+		logInfo("Creating BigQuery dataset '%s' in %s...", dataset, be.Location)
+		if err := createBigQueryDataset(ctx, project, be.DatasetID, be.Location); err != nil {
+			return err
+		}
+		metrics.recordResource("bigquery_dataset", dataset, resourceCreated)
+	}
+
+	tables, err := bigQueryTables(ctx, project, be.DatasetID)
+	if err != nil {
+		return err
+	}
+	var exports []string
+	for _, t := range tables {
+		for _, prefix := range billingExportTablePrefixes {
+			if strings.HasPrefix(t, prefix) {
+				exports = append(exports, t)
+			}
+		}
+	}
+	if len(exports) == 0 {
+		logWarning("No billing export writes to '%s' yet. Enable the detailed usage cost export of billing account '%s' into this dataset at %s (it needs roles/billing.admin and cannot be set through an API).",
+			dataset, cfg.BillingAccountID, billingExportConsoleURL(cfg.BillingAccountID))
+		return nil
+	}
+	logInfo("Billing export is active in '%s': %s.", dataset, strings.Join(exports, ", "))
+	return nil
+}
+
+// bigQueryDatasetExists reports whether the dataset exists in project
+func bigQueryDatasetExists(ctx context.Context, project, dataset string) (bool, error) {
+	if fakeGCP != nil {
+		return fakeGCP.bigQueryDatasetExists(project, dataset)
+	}
+	status, data, err := googleAPIRequest(ctx, http.MethodGet, fmt.Sprintf("%s/projects/%s/datasets/%s", bigQueryAPI, project, dataset), nil)
+	switch {
+	case err != nil:
+		return false, fmt.Errorf("failed to check BigQuery dataset '%s': %w", dataset, err)
+	case status == http.StatusNotFound:
+		return false, nil
+	case status/100 != 2:
+		return false, fmt.Errorf("failed to check BigQuery dataset '%s': %d: %s", dataset, status, data)
+	}
+	return true, nil
+}
+
+// createBigQueryDataset creates the dataset in project at location
+func createBigQueryDataset(ctx context.Context, project, dataset, location string) error {
+	if fakeGCP != nil {
+		return fakeGCP.createBigQueryDataset(project, dataset)
+	}
+	body, err := json.Marshal(map[string]any{
+		"datasetReference": map[string]string{"projectId": project, "datasetId": dataset},
+		"location":         location,
+		"description":      "Cloud Billing export, created by gcp-bootstrap",
+	})
+	if err != nil {
+		return err
+	}
+	logInfo("Executing: POST %s/projects/%s/datasets", bigQueryAPI, project)
+	status, data, err := googleAPIRequest(ctx, http.MethodPost, fmt.Sprintf("%s/projects/%s/datasets", bigQueryAPI, project), body)
+	if err != nil {
+		return fmt.Errorf("failed to create BigQuery dataset '%s': %w", dataset, err)
+	}
+	if status/100 != 2 {
+		return fmt.Errorf("failed to create BigQuery dataset '%s': %d: %s", dataset, status, data)
+	}
+	return nil
+}
+
+// bigQueryTables lists the table IDs of the dataset
+func bigQueryTables(ctx context.Context, project, dataset string) ([]string, error) {
+	if fakeGCP != nil {
+		return fakeGCP.bigQueryTables(project, dataset)
+	}
+	status, data, err := googleAPIRequest(ctx, http.MethodGet, fmt.Sprintf("%s/projects/%s/datasets/%s/tables?maxResults=1000", bigQueryAPI, project, dataset), nil)
+	if err != nil || status/100 != 2 {
+		return nil, fmt.Errorf("failed to list the tables of BigQuery dataset '%s': %v %s", dataset, err, data)
+	}
+	var list struct {
+		Tables []struct {
+			TableReference struct {
+				TableID string `json:"tableId"`
+			} `json:"tableReference"`
+		} `json:"tables"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse the tables of BigQuery dataset '%s': %w", dataset, err)
+	}
+	var tables []string
+	for _, t := range list.Tables {
+		tables = append(tables, t.TableReference.TableID)
+	}
+	return tables, nil
+}
+
+func planBillingExport(cfg *Config) []planAction {
+	be := cfg.BillingExport
+	if !be.Enabled {
+		return nil
+	}
+	project := be.project(cfg)
+	return []planAction{
+		{Description: "Enable the BigQuery APIs", Command: []string{"gcloud", "services", "enable", "bigquery.googleapis.com", "bigquerydatatransfer.googleapis.com", "--project", project}},
+		{Description: fmt.Sprintf("Create BigQuery dataset '%s:%s' in %s if it does not exist (BigQuery API)", project, be.DatasetID, be.Location)},
+		{Description: fmt.Sprintf("Check for billing export tables in the dataset; if there are none, warn to enable the export at %s", billingExportConsoleURL(cfg.BillingAccountID))},
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// breakGlassConditionTitle identifies the break-glass binding in the project IAM policy
+const breakGlassConditionTitle = "break-glass"
+
+// Break-glass defaults. The binding is always conditional, which IAM rejects on basic roles,
+// so the default role lets the group grant whatever an incident needs instead of being Owner.
+const (
+	defaultBreakGlassRole     = "roles/resourcemanager.projectIamAdmin"
+	defaultBreakGlassDuration = 72 * time.Hour
+)
+
+// basicRoles are the primitive roles IAM conditions cannot be attached to
+var basicRoles = []string{"roles/owner", "roles/editor", "roles/viewer"}
+
+// breakGlassExpiryPattern extracts the expiry from the condition expression
+var breakGlassExpiryPattern = regexp.MustCompile(`request\.time < timestamp\("([^"]+)"\)`)
+
+// member returns the IAM member of the break-glass group
+func (b BreakGlassConfig) member() string {
+	return "group:" + b.Group
+}
+
+// breakGlassCondition returns the --condition value of a binding that expires at expires
+func breakGlassCondition(expires string) string {
+	return fmt.Sprintf(`expression=request.time < timestamp("%s"),title=%s,description=Day-one emergency access granted by gcp-bootstrap`, expires, breakGlassConditionTitle)
+}
+
+// setupBreakGlass grants the break-glass group its role on the project with an IAM
+// condition that expires after the configured duration. A binding from an earlier run
+// is kept as it is, even once expired, so re-runs never extend the emergency access.
+func setupBreakGlass(ctx context.Context, cfg *Config) error {
+	bg := &cfg.BreakGlass
+	if !bg.Enabled {
+		logInfo("Skipping break-glass binding as per config.")
+		return nil
+	}
+	expires, found, err := findBreakGlassBinding(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if found {
+		bg.Expires, bg.Status = expires, resourceExisted
+		metrics.recordResource("iam_binding", bg.Role+" "+bg.member(), resourceExisted)
+		if !expires.IsZero() && time.Now().After(expires) {
+			logNotice("Break-glass binding for '%s' expired at %s; it is not re-created.", bg.Group, expires.Format(time.RFC3339))
+		} else {
+			logInfo("Break-glass binding for '%s' already exists (expires %s).", bg.Group, expires.Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	expires = time.Now().Add(bg.Duration).UTC().Truncate(time.Second)
+	if err := snapshotProjectIAM(ctx, cfg.ProjectID); err != nil {
+		return err
+	}
+	logInfo("Granting '%s' to break-glass group '%s' until %s...", bg.Role, bg.Group, expires.Format(time.RFC3339))
+	err = runCommand(ctx, "gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID,
+		"--member", bg.member(),
+		"--role", bg.Role,
+		"--condition="+breakGlassCondition(expires.Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("failed to grant break-glass role: %w", err)
+	}
+	bg.Expires, bg.Status = expires, resourceCreated
+	metrics.recordResource("iam_binding", bg.Role+" "+bg.member(), resourceCreated)
+	logNotice("Break-glass access for '%s' granted; it expires at %s.", bg.Group, expires.Format(time.RFC3339))
+	return nil
+}
+
+// findBreakGlassBinding looks for the break-glass binding of the configured group and role
+// in the project IAM policy and returns its expiry
+func findBreakGlassBinding(ctx context.Context, cfg *Config) (time.Time, bool, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "get-iam-policy", cfg.ProjectID, "--format=json")
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read the IAM policy of '%s': %w", cfg.ProjectID, err)
+	}
+	var policy iamPolicy
+	if err := json.Unmarshal([]byte(output), &policy); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to parse the IAM policy of '%s': %w", cfg.ProjectID, err)
+	}
+	bg := cfg.BreakGlass
+	for _, b := range policy.Bindings {
+		if b.Role != bg.Role || b.Condition == nil || b.Condition.Title != breakGlassConditionTitle || !slices.Contains(b.Members, bg.member()) {
+			continue
+		}
+		var expires time.Time
+		if m := breakGlassExpiryPattern.FindStringSubmatch(b.Condition.Expression); m != nil {
+			expires, _ = time.Parse(time.RFC3339, m[1])
+		}
+		return expires, true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+func planBreakGlass(cfg *Config) []planAction {
+	bg := cfg.BreakGlass
+	if !bg.Enabled {
+		return nil
+	}
+	return []planAction{{
+		Description: fmt.Sprintf("Grant '%s' to break-glass group '%s' for %s, unless an earlier break-glass binding exists", bg.Role, bg.Group, bg.Duration),
+		Command:     []string{"gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID, "--member", bg.member(), "--role", bg.Role, "--condition=" + breakGlassCondition("<run time + "+bg.Duration.String()+">")},
+	}}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Placeholders of tf_state_bucket_name_template
+const (
+	projectIDPlaceholder     = "{{project_id}}"
+	projectNumberPlaceholder = "{{project_number}}"
+)
+
+// templatePlaceholderPattern matches any {{...}} placeholder, to reject unknown ones
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
+// expandBucketNameTemplate sets the state bucket name from tf_state_bucket_name_template.
+// The project number is only known once the project exists; for a new project the
+// placeholder is kept until the project step resolves it.
+func expandBucketNameTemplate(ctx context.Context, cfg *Config) error {
+	template := cfg.TFStateBucketNameTemplate
+	for _, p := range templatePlaceholderPattern.FindAllString(template, -1) {
+		if p != projectIDPlaceholder && p != projectNumberPlaceholder {
+			return fmt.Errorf("tf_state_bucket_name_template has unknown placeholder '%s' (supported: %s, %s)", p, projectIDPlaceholder, projectNumberPlaceholder)
+		}
+	}
+	cfg.TFStateBucketName = strings.ReplaceAll(template, projectIDPlaceholder, cfg.ProjectID)
+	if !cfg.bucketNamePending() || configOffline || cfg.projectIDPending() {
+		return nil
+	}
+	if number, err := projectNumber(ctx, cfg.ProjectID); err == nil {
+		cfg.TFStateBucketName = strings.ReplaceAll(cfg.TFStateBucketName, projectNumberPlaceholder, number)
+	} else {
+		logDebug("Project number not known yet, the state bucket name is resolved after project creation: %v", err)
+	}
+	return nil
+}
+
+// bucketNamePending reports whether the state bucket name still waits for the project
+// number or the project ID generated from project_id_prefix
+func (c *Config) bucketNamePending() bool {
+	return strings.Contains(c.TFStateBucketName, projectNumberPlaceholder) ||
+		(c.projectIDPending() && strings.Contains(c.TFStateBucketName, c.ProjectID))
+}
+
+// resolveBucketNameTemplate fills the project number into the state bucket name once the
+// project exists
+func resolveBucketNameTemplate(ctx context.Context, cfg *Config) error {
+	if !cfg.bucketNamePending() {
+		return nil
+	}
+	number, err := projectNumber(ctx, cfg.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve tf_state_bucket_name_template: %w", err)
+	}
+	cfg.TFStateBucketName = strings.ReplaceAll(cfg.TFStateBucketName, projectNumberPlaceholder, number)
+	logInfo("State bucket name resolved to 'gs://%s'.", cfg.TFStateBucketName)
+	return nil
+}
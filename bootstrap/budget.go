@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultBudgetThresholds are the alert thresholds, as fractions of the amount, when none are configured
+var defaultBudgetThresholds = []float64{0.5, 0.9, 1.0}
+
+// currencyPattern matches ISO 4217 currency codes
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// budget is the part of a Cloud Billing budget the tool reads
+type budget struct {
+	Name        string `json:"name"` // billingAccounts/ID/budgets/ID
+	DisplayName string `json:"displayName"`
+}
+
+// enabled reports whether a budget is configured
+func (b BudgetConfig) enabled() bool {
+	return b.Amount > 0
+}
+
+// validateBudget fills in the budget defaults and checks the thresholds and topic
+func validateBudget(cfg *Config) error {
+	b := &cfg.Budget
+	if !b.enabled() {
+		if b.Amount < 0 {
+			return fmt.Errorf("budget.amount must be positive")
+		}
+		return nil
+	}
+	if b.Currency == "" {
+		b.Currency = "USD"
+	}
+	b.Currency = strings.ToUpper(b.Currency)
+	if !currencyPattern.MatchString(b.Currency) {
+		return fmt.Errorf("budget.currency '%s' is not a currency code like USD or EUR", b.Currency)
+	}
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = defaultBudgetThresholds
+	}
+	for _, t := range b.Thresholds {
+		if t <= 0 {
+			return fmt.Errorf("budget.thresholds must be positive fractions of the amount (e.g. 0.5 for 50%%), got %g", t)
+		}
+	}
+	if b.DisplayName == "" {
+		b.DisplayName = cfg.ProjectID + " budget"
+	}
+	if b.PubSubTopic != "" && !strings.HasPrefix(b.PubSubTopic, "projects/") {
+		b.PubSubTopic = fmt.Sprintf("projects/%s/topics/%s", cfg.ProjectID, b.PubSubTopic)
+	}
+	return nil
+}
+
+// budgetCreateArgs returns the gcloud arguments creating the budget scoped to the project
+func budgetCreateArgs(cfg *Config, projectRef string) []string {
+	b := cfg.Budget
+	args := []string{"billing", "budgets", "create",
+		"--billing-account", cfg.BillingAccountID,
+		"--display-name", b.DisplayName,
+		"--budget-amount", strconv.FormatFloat(b.Amount, 'f', -1, 64) + b.Currency,
+		"--filter-projects", "projects/" + projectRef,
+		"--billing-project", cfg.ProjectID}
+	for _, t := range b.Thresholds {
+		args = append(args, "--threshold-rule=percent="+strconv.FormatFloat(t, 'f', -1, 64))
+	}
+	if b.PubSubTopic != "" {
+		args = append(args, "--notifications-rule-pubsub-topic", b.PubSubTopic)
+	}
+	return args
+}
+
+// createBudget creates a budget with alert thresholds scoped to the project on its billing
+// account, unless a budget with the same display name exists there
+func createBudget(ctx context.Context, cfg *Config) error {
+	b := cfg.Budget
+	if !b.enabled() {
+		logInfo("Skipping budget creation as per config.")
+		return nil
+	}
+	if err := ensureServicesEnabled(ctx, cfg.ProjectID, []string{"billingbudgets.googleapis.com"}); err != nil {
+		return fmt.Errorf("failed to enable the Cloud Billing Budget API: %w", err)
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "billing", "budgets", "list",
+		"--billing-account", cfg.BillingAccountID, "--billing-project", cfg.ProjectID, "--format=json")
+	if err != nil {
+		return fmt.Errorf("failed to list the budgets of billing account '%s': %w", cfg.BillingAccountID, err)
+	}
+	var budgets []budget
+	if err := json.Unmarshal([]byte(output), &budgets); err != nil {
+		return fmt.Errorf("failed to parse the budgets of billing account '%s': %w", cfg.BillingAccountID, err)
+	}
+	for _, existing := range budgets {
+		if existing.DisplayName == b.DisplayName {
+			logInfo("Budget '%s' already exists (%s); it is not changed.", b.DisplayName, existing.Name)
+			metrics.recordResource("budget", existing.Name, resourceExisted)
+			return nil
+		}
+	}
+
+	number, err := projectNumber(ctx, cfg.ProjectID)
+	if err != nil {
+		return err
+	}
+	logInfo("Creating budget '%s' of %g %s for project '%s'...", b.DisplayName, b.Amount, b.Currency, cfg.ProjectID)
+	if err := runCommand(ctx, "gcloud", budgetCreateArgs(cfg, number)...); err != nil {
+		if strings.Contains(err.Error(), "currency") {
+			return fmt.Errorf("failed to create budget: %w (budget.currency must match the billing account's currency)", err)
+		}
+		return fmt.Errorf("failed to create budget: %w", err)
+	}
+	metrics.recordResource("budget", b.DisplayName, resourceCreated)
+	logInfo("Budget '%s' created; alerts go to the billing account's billing admins and users.", b.DisplayName)
+	return nil
+}
+
+func planBudget(cfg *Config) []planAction {
+	b := cfg.Budget
+	if !b.enabled() {
+		return nil
+	}
+	var thresholds []string
+	for _, t := range b.Thresholds {
+		thresholds = append(thresholds, strconv.FormatFloat(t*100, 'f', -1, 64)+"%")
+	}
+	return []planAction{
+		{Description: "Enable the Cloud Billing Budget API", Command: []string{"gcloud", "services", "enable", "billingbudgets.googleapis.com", "--project", cfg.ProjectID}},
+		{
+			Description: fmt.Sprintf("Create budget '%s' of %g %s with alerts at %s unless it exists", b.DisplayName, b.Amount, b.Currency, strings.Join(thresholds, ", ")),
+			Command:     append([]string{"gcloud"}, budgetCreateArgs(cfg, "<project-number>")...),
+		},
+	}
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// projectsKey is the top-level config key holding the project definitions of a bulk config
+const projectsKey = "projects"
+
+// runStatusSkipped marks bulk runs that were not started, after --fail-fast or an interrupt
+const runStatusSkipped = "skipped"
+
+// defaultBulkLogDir is where the output of parallel bulk runs is written, one file per project
+const defaultBulkLogDir = ".gcp-bootstrap/bulk"
+
+// configProjectEntry is the project_id of the 'projects' entry selected with --project-entry;
+// loadConfig merges that entry over the shared settings of bulk configs
+var configProjectEntry string
+
+// projectEntryIDs returns the project_id of every entry of the config's 'projects' list, in order
+func projectEntryIDs(doc []byte) ([]string, error) {
+	var root struct {
+		Projects []map[string]any `yaml:"projects"`
+	}
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, fmt.Errorf("%s must be a list of project definitions: %w", projectsKey, err)
+	}
+	var ids []string
+	for i, entry := range root.Projects {
+		id, _ := entry["project_id"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("%s[%d] has no project_id", projectsKey, i)
+		}
+		if slices.Contains(ids, id) {
+			return nil, fmt.Errorf("%s lists project_id '%s' more than once", projectsKey, id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// selectProjectEntry returns the config document with the 'projects' entry whose project_id
+// is id merged over the shared settings. Configs without a projects list are returned unchanged.
+func selectProjectEntry(doc []byte, id string) ([]byte, error) {
+	ids, err := projectEntryIDs(doc)
+	if err != nil {
+		return nil, err
+	}
+	if ids == nil {
+		if id != "" {
+			return nil, fmt.Errorf("--project-entry '%s' was given but the config has no %s list", id, projectsKey)
+		}
+		return doc, nil
+	}
+	index := slices.Index(ids, id)
+	switch {
+	case id == "":
+		return nil, fmt.Errorf("the config lists %d projects; bootstrap them with 'gcp-bootstrap bulk' or pick one with --project-entry", len(ids))
+	case index < 0:
+		return nil, fmt.Errorf("no entry with project_id '%s' in %s (%s)", id, projectsKey, strings.Join(ids, ", "))
+	}
+
+	root := map[string]any{}
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	entry := root[projectsKey].([]any)[index].(map[string]any)
+	delete(root, projectsKey)
+	for key := range entry {
+		if key == "extends" || key == environmentsKey || !isConfigKey(key) {
+			return nil, fmt.Errorf("%s entry '%s': '%s' cannot be set per project", projectsKey, id, key)
+		}
+	}
+	return yaml.Marshal(mergeConfigMaps(root, entry))
+}
+
+// bulkJob is one bootstrap run of a bulk invocation
+type bulkJob struct {
+	ConfigPath string
+	Entry      string // project_id of the 'projects' entry, empty for single-project configs
+	ProjectID  string
+	Production bool
+}
+
+// bulkRun is the outcome of one bulk job in the consolidated report
+type bulkRun struct {
+	Config          string     `json:"config"`
+	ProjectID       string     `json:"project_id"`
+	Status          string     `json:"status"`
+	DurationSeconds float64    `json:"duration_seconds"`
+	Error           string     `json:"error,omitempty"`
+	LogFile         string     `json:"log_file,omitempty"`
+	Report          *runReport `json:"report,omitempty"` // The run's own report, if it got far enough to write one
+}
+
+// bulkReport is the consolidated report of a bulk invocation
+type bulkReport struct {
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Environment     string    `json:"environment,omitempty"`
+	Succeeded       int       `json:"succeeded"`
+	Failed          int       `json:"failed"`
+	Skipped         int       `json:"skipped"`
+	Runs            []bulkRun `json:"runs"`
+}
+
+// runBulkCommand implements 'bulk': it bootstraps every project of the given configs,
+// directories of configs and configs with a 'projects' list, each in a child process of
+// this binary, sequentially or in parallel, and prints a consolidated report at the end
+func runBulkCommand(args []string) {
+	fs := flag.NewFlagSet("bulk", flag.ExitOnError)
+	parallel := fs.Int("parallel", 1, "Number of projects bootstrapped at the same time")
+	failFast := fs.Bool("fail-fast", false, "Start no further runs after the first failure")
+	reportPath := fs.String("report-json", "", "Write the consolidated JSON report of all runs to this file")
+	logDir := fs.String("log-dir", defaultBulkLogDir, "With -parallel above 1, directory for the output of each run (one file per project)")
+	timeout := fs.Duration("timeout", 0, "Abort a single run if it runs longer than this; 0 disables the limit")
+	historyDir := fs.String("history-dir", defaultHistoryDir, "Directory where a report of every run is stored; empty disables it")
+	credentialsFile := fs.String("credentials-file", "", "Authenticate gcloud with this credential JSON file instead of the active gcloud account")
+	productionAck := fs.Bool("production-ack", false, "Allow bootstrapping production configs without a typed confirmation")
+	changeTicket := fs.String("change-ticket", "", "Change-management ticket recorded by every run")
+	fakeGCPFlag := fs.Bool("fake-gcp", false, "Bootstrap against the in-process fake of GCP (for demos and tests)")
+	fakeGCPState := fs.String("fake-gcp-state", "", "With -fake-gcp, keep the fake's state in this JSON file across runs (sequential only)")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the configs' 'environments' to bootstrap")
+	addVariableFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap bulk [-parallel N] [-fail-fast] [-report-json FILE] [-env NAME] [-var NAME=VALUE] [-var-file FILE] [CONFIG|DIR ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	// Runs are unattended, so a variable without a value is an error rather than a prompt
+	variablePrompts = false
+	if *parallel < 1 {
+		logError("-parallel must be at least 1, got %d", *parallel)
+	}
+	if configEnvironment == allEnvironments {
+		logError("bulk runs one environment at a time; pass a single -env")
+	}
+	if *fakeGCPState != "" && *parallel > 1 {
+		logError("-fake-gcp-state cannot be shared by parallel runs; use -parallel 1")
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{defaultConfigFilename}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobs, err := bulkJobs(ctx, paths)
+	if err != nil {
+		logError("%v", err)
+	}
+	for _, job := range jobs {
+		if job.Production && !*productionAck {
+			logError("'%s' (%s) is a production config; pass -production-ack to bootstrap it unattended.", job.ConfigPath, job.ProjectID)
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		logError("Failed to locate the gcp-bootstrap executable: %v", err)
+	}
+	childArgs := []string{"-yes", "-no-color", "-history-dir", *historyDir}
+	if configEnvironment != "" {
+		childArgs = append(childArgs, "-env", configEnvironment)
+	}
+	childArgs = append(childArgs, variableArgs()...)
+	if *timeout > 0 {
+		childArgs = append(childArgs, "-timeout", timeout.String())
+	}
+	if *credentialsFile != "" {
+		childArgs = append(childArgs, "-credentials-file", *credentialsFile)
+	}
+	if *productionAck {
+		childArgs = append(childArgs, "-production-ack")
+	}
+	if *changeTicket != "" {
+		childArgs = append(childArgs, "-change-ticket", *changeTicket)
+	}
+	if *fakeGCPFlag {
+		childArgs = append(childArgs, "-fake-gcp")
+		if *fakeGCPState != "" {
+			childArgs = append(childArgs, "-fake-gcp-state", *fakeGCPState)
+		}
+	}
+	if *parallel > 1 {
+		// Interleaved output of parallel runs is unreadable, so each run gets its own file
+		*logDir = filepath.Join(*logDir, time.Now().Format(runIDFormat))
+		if err := os.MkdirAll(*logDir, 0755); err != nil {
+			logError("Failed to create log directory '%s': %v", *logDir, err)
+		}
+		logInfo("Writing the output of each run to '%s'.", *logDir)
+	}
+
+	report := &bulkReport{StartedAt: time.Now(), Environment: configEnvironment, Runs: make([]bulkRun, len(jobs))}
+	logNotice("Bootstrapping %d project(s), %d at a time.", len(jobs), *parallel)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+	slots := make(chan struct{}, *parallel)
+	for i, job := range jobs {
+		slots <- struct{}{}
+		mu.Lock()
+		skip := ctx.Err() != nil || (*failFast && failed)
+		mu.Unlock()
+		if skip {
+			<-slots
+			report.Runs[i] = bulkRun{Config: job.ConfigPath, ProjectID: job.ProjectID, Status: runStatusSkipped}
+			continue
+		}
+		logFile := ""
+		if *parallel > 1 {
+			logFile = filepath.Join(*logDir, job.ProjectID+".log")
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			run := runBulkJob(ctx, executable, childArgs, job, logFile)
+			mu.Lock()
+			failed = failed || run.Status != runStatusSucceeded
+			mu.Unlock()
+			report.Runs[i] = run
+		}()
+	}
+	wg.Wait()
+
+	report.FinishedAt = time.Now()
+	report.DurationSeconds = report.FinishedAt.Sub(report.StartedAt).Seconds()
+	for _, run := range report.Runs {
+		switch run.Status {
+		case runStatusSucceeded:
+			report.Succeeded++
+		case runStatusSkipped:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+	}
+	printBulkReport(report)
+	if *reportPath != "" {
+		if err := writeBulkReport(*reportPath, report); err != nil {
+			logWarning("%v", err)
+		} else {
+			logInfo("Consolidated report written to '%s'.", *reportPath)
+		}
+	}
+	if report.Failed > 0 || report.Skipped > 0 {
+		logError("%d of %d bootstrap run(s) did not succeed.", report.Failed+report.Skipped, len(jobs))
+	}
+	logNotice("All %d bootstrap run(s) succeeded.", len(jobs))
+}
+
+// bulkJobs expands the given configs and directories of configs into one job per project,
+// loading each config up front so invalid ones fail before anything is created
+func bulkJobs(ctx context.Context, paths []string) ([]bulkJob, error) {
+	var configPaths []string
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config path '%s': %w", path, err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("config path '%s': %w", path, err)
+		}
+		if !info.IsDir() {
+			configPaths = append(configPaths, abs)
+			continue
+		}
+		var found []string
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(abs, pattern))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list configs in '%s': %w", path, err)
+			}
+			found = append(found, matches...)
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no *.yaml or *.yml configs in directory '%s'", path)
+		}
+		slices.Sort(found)
+		configPaths = append(configPaths, found...)
+	}
+
+	var jobs []bulkJob
+	seen := map[string]string{}    // Project ID -> config, to catch the same project twice
+	outputs := map[string]string{} // Generated file or key path -> project writing it
+	for _, path := range configPaths {
+		entries, err := readProjectEntryIDs(path)
+		if err != nil {
+			return nil, err
+		}
+		if entries == nil {
+			entries = []string{""}
+		}
+		for _, entry := range entries {
+			configProjectEntry = entry
+			cfg, err := loadConfig(ctx, path, bootstrapModeProject, nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load configuration: %w", err)
+			}
+			if other, ok := seen[cfg.ProjectID]; ok {
+				return nil, fmt.Errorf("project '%s' is defined in both '%s' and '%s'", cfg.ProjectID, other, path)
+			}
+			seen[cfg.ProjectID] = path
+			// All runs share the working directory, so files they write must not collide
+			var written []string
+			if !cfg.isStepDisabled("terraform_files") {
+				written = append(written, cfg.Terraform.OutputDir)
+			}
+			if cfg.writesPrivateKey() && !cfg.keyInKeychain() {
+				written = append(written, cfg.TFSAKeyPath)
+			}
+			for _, out := range written {
+				abs, err := filepath.Abs(out)
+				if err != nil {
+					return nil, fmt.Errorf("invalid output path '%s' of project '%s': %w", out, cfg.ProjectID, err)
+				}
+				if other, ok := outputs[abs]; ok {
+					return nil, fmt.Errorf("projects '%s' and '%s' would both write '%s'; set terraform.output_dir and tf_sa_key_path per project", other, cfg.ProjectID, out)
+				}
+				outputs[abs] = cfg.ProjectID
+			}
+			jobs = append(jobs, bulkJob{ConfigPath: path, Entry: entry, ProjectID: cfg.ProjectID, Production: cfg.isProduction()})
+		}
+	}
+	configProjectEntry = ""
+	return jobs, nil
+}
+
+// readProjectEntryIDs returns the project IDs of the config file's 'projects' list, or nil
+// for a single-project config
+func readProjectEntryIDs(configPath string) ([]string, error) {
+	doc, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", configPath, err)
+	}
+	if doc, err = resolveExtends(configPath, doc); err != nil {
+		return nil, fmt.Errorf("error resolving extends in %s: %w", configPath, err)
+	}
+	if doc, err = selectEnvironment(doc, configEnvironment); err != nil {
+		return nil, fmt.Errorf("error selecting environment in %s: %w", configPath, err)
+	}
+	ids, err := projectEntryIDs(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %w", projectsKey, configPath, err)
+	}
+	return ids, nil
+}
+
+// runBulkJob bootstraps one project in a child process and returns its outcome from the
+// child's JSON report. With logFile set the child's output goes there instead of stdout.
+func runBulkJob(ctx context.Context, executable string, childArgs []string, job bulkJob, logFile string) bulkRun {
+	run := bulkRun{Config: job.ConfigPath, ProjectID: job.ProjectID, LogFile: logFile}
+	logInfo("Bootstrapping '%s' from '%s'...", job.ProjectID, job.ConfigPath)
+	reportFile, err := os.CreateTemp("", "gcp-bootstrap-report-*.json")
+	if err != nil {
+		run.Status, run.Error = runStatusFailed, fmt.Sprintf("failed to create report file: %v", err)
+		return run
+	}
+	reportFile.Close()
+	defer os.Remove(reportFile.Name())
+
+	args := append([]string{"-config", job.ConfigPath, "-report-json", reportFile.Name()}, childArgs...)
+	if job.Entry != "" {
+		args = append(args, "-project-entry", job.Entry)
+	}
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if logFile != "" {
+		out, err := os.Create(logFile)
+		if err != nil {
+			run.Status, run.Error = runStatusFailed, fmt.Sprintf("failed to create log file: %v", err)
+			return run
+		}
+		defer out.Close()
+		cmd.Stdout, cmd.Stderr = out, out
+	}
+	// Let the run stop cleanly and write its report instead of killing it outright
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = daemonChildGracePeriod
+	start := time.Now()
+	runErr := cmd.Run()
+	run.DurationSeconds = time.Since(start).Seconds()
+
+	if report, err := readReport(reportFile.Name()); err == nil {
+		run.Report = report
+		run.Status = report.Status
+	} else if runErr == nil {
+		// The run exited before writing a report, e.g. on an invalid config
+		runErr = errors.New("run finished without writing a report")
+	}
+	if runErr != nil {
+		run.Error = runErr.Error()
+		if run.Status == "" || run.Status == runStatusSucceeded {
+			run.Status = runStatusFailed
+		}
+	}
+	if run.Status == runStatusSucceeded {
+		logNotice("Bootstrapped '%s' in %s.", job.ProjectID, formatSeconds(run.DurationSeconds))
+	} else {
+		logWarning("Bootstrap of '%s' %s: %s", job.ProjectID, run.Status, run.Error)
+	}
+	return run
+}
+
+// writeBulkReport writes the consolidated JSON report to path
+func writeBulkReport(path string, r *bulkReport) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bulk report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write bulk report to %s: %w", path, err)
+	}
+	return nil
+}
+
+// printBulkReport prints one line per run and the totals
+func printBulkReport(r *bulkReport) {
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	fmt.Fprintln(stdout, colorize(colorBold+colorCyan, " Bulk Bootstrap Report"))
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	for _, run := range r.Runs {
+		detail := run.Config
+		if run.LogFile != "" && run.Status != runStatusSucceeded {
+			detail += " (log: " + run.LogFile + ")"
+		}
+		fmt.Fprintf(stdout, " %-11s  %-30s  %8s  %s\n", run.Status, run.ProjectID, formatSeconds(run.DurationSeconds), detail)
+	}
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	fmt.Fprintf(stdout, " Succeeded: %d  Failed: %d  Skipped: %d  Total time: %s\n", r.Succeeded, r.Failed, r.Skipped, formatSeconds(r.DurationSeconds))
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// catalogDirName is the directory next to the config file that holds named templates
+const catalogDirName = "catalog"
+
+// resolveExtends merges the config document over the catalog template named by its
+// 'extends' key (catalog/<name>.yaml next to the config). Templates may extend other
+// templates. Mappings are merged recursively; scalars and lists in the extending
+// document replace the template's value entirely.
+func resolveExtends(configPath string, doc []byte) ([]byte, error) {
+	root := map[string]any{}
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	extends, ok := root["extends"]
+	if !ok {
+		return doc, nil
+	}
+	dir := filepath.Join(filepath.Dir(configPath), catalogDirName)
+	merged, err := mergeCatalogTemplates(dir, root, nil)
+	if err != nil {
+		return nil, err
+	}
+	merged["extends"] = extends // Keep the config's own template name for the summary
+	return yaml.Marshal(merged)
+}
+
+// mergeCatalogTemplates returns doc merged over its template chain; chain holds the
+// templates already visited so cycles are reported instead of recursing forever
+func mergeCatalogTemplates(dir string, doc map[string]any, chain []string) (map[string]any, error) {
+	extends, ok := doc["extends"]
+	if !ok {
+		return doc, nil
+	}
+	name, ok := extends.(string)
+	if !ok || name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("extends must be the name of a template in '%s', got '%v'", dir, extends)
+	}
+	for _, seen := range chain {
+		if seen == name {
+			return nil, fmt.Errorf("catalog templates extend each other in a cycle: %s -> %s", strings.Join(chain, " -> "), name)
+		}
+	}
+	path := filepath.Join(dir, name+".yaml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog template '%s': %w", name, err)
+	}
+	template := map[string]any{}
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("error parsing catalog template %s: %w", path, err)
+	}
+	logInfo("Extending catalog template '%s'.", name)
+	base, err := mergeCatalogTemplates(dir, template, append(chain, name))
+	if err != nil {
+		return nil, err
+	}
+	delete(doc, "extends")
+	return mergeConfigMaps(base, doc), nil
+}
+
+// mergeConfigMaps merges override into base: nested mappings are merged key by key,
+// any other value in override replaces the one in base
+func mergeConfigMaps(base, override map[string]any) map[string]any {
+	for key, value := range override {
+		if child, ok := value.(map[string]any); ok {
+			if parent, ok := base[key].(map[string]any); ok {
+				base[key] = mergeConfigMaps(parent, child)
+				continue
+			}
+		}
+		base[key] = value
+	}
+	return base
+}
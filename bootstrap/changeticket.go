@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// changeTicketLabel is the project label carrying the change ticket of the latest run
+const changeTicketLabel = "change-ticket"
+
+// changeTicketPattern keeps tickets usable as label values once lowercased
+var changeTicketPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// setChangeTicket records the change ticket the run is made under: it is added to the
+// project labels and the TF SA description, and ends up in the run report
+func (c *Config) setChangeTicket(ticket string) error {
+	if !changeTicketPattern.MatchString(ticket) {
+		return fmt.Errorf("'%s' must be 1-63 letters, digits, '_' or '-'", ticket)
+	}
+	c.ChangeTicket = ticket
+	c.TFServiceAccountMetadata.ChangeTicket = ticket
+	return c.TFServiceAccountMetadata.validate()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+// workloadIdentityProviderName returns the full resource name of a provider in the pool
+func workloadIdentityProviderName(cfg *Config, projectNumber, providerID string) string {
+	return fmt.Sprintf("projects/%s/locations/global/workloadIdentityPools/%s/providers/%s", projectNumber, cfg.WIF.PoolID, providerID)
+}
+
+// renderGitHubWorkflow renders a GitHub Actions workflow that authenticates through the
+// GitHub WIF provider, plans on pull requests and applies on pushes to the branch
+func renderGitHubWorkflow(cfg *Config, projectNumber string) string {
+	gh := cfg.WIF.GitHub
+	dir := filepath.ToSlash(filepath.Clean(cfg.Terraform.OutputDir))
+	var b bytes.Buffer
+	b.WriteString(generatedFileHeader)
+	b.WriteString("name: Terraform\n\n")
+	b.WriteString("on:\n")
+	b.WriteString("  pull_request:\n")
+	fmt.Fprintf(&b, "    paths: [\"%s/**\"]\n", dir)
+	b.WriteString("  push:\n")
+	fmt.Fprintf(&b, "    branches: [%q]\n", gh.Branch)
+	fmt.Fprintf(&b, "    paths: [\"%s/**\"]\n\n", dir)
+	b.WriteString("permissions:\n")
+	b.WriteString("  contents: read\n")
+	b.WriteString("  id-token: write # Required to request the OIDC token for Workload Identity Federation\n\n")
+	b.WriteString("env:\n")
+	fmt.Fprintf(&b, "  WORKLOAD_IDENTITY_PROVIDER: %q\n", workloadIdentityProviderName(cfg, projectNumber, gh.ProviderID))
+	fmt.Fprintf(&b, "  SERVICE_ACCOUNT: %q\n", cfg.TFServiceAccountEmail)
+	fmt.Fprintf(&b, "  TF_STATE_BUCKET: %q\n", cfg.TFStateBucketName)
+	b.WriteString("  TF_IN_AUTOMATION: \"true\"\n")
+	b.WriteString("  TF_VAR_impersonate_tf_service_account: \"false\" # The job is already authenticated as the SA\n\n")
+	b.WriteString("jobs:\n")
+	writeGitHubJob(&b, "plan", dir, "", []string{"terraform plan -input=false"})
+	b.WriteString("\n")
+	writeGitHubJob(&b, "apply", dir,
+		fmt.Sprintf("github.event_name == 'push' && github.ref == 'refs/heads/%s'", gh.Branch),
+		[]string{"terraform apply -input=false -auto-approve"})
+	return b.String()
+}
+
+// writeGitHubJob writes a job that authenticates to GCP, initializes Terraform against
+// the state bucket and runs commands; a non-empty condition gates the job after plan
+func writeGitHubJob(b *bytes.Buffer, name, dir, condition string, commands []string) {
+	fmt.Fprintf(b, "  %s:\n", name)
+	if condition != "" {
+		b.WriteString("    needs: plan\n")
+		fmt.Fprintf(b, "    if: %s\n", condition)
+	}
+	b.WriteString("    runs-on: ubuntu-latest\n")
+	b.WriteString("    defaults:\n")
+	b.WriteString("      run:\n")
+	fmt.Fprintf(b, "        working-directory: %s\n", dir)
+	b.WriteString("    steps:\n")
+	b.WriteString("      - uses: actions/checkout@v4\n")
+	b.WriteString("      - uses: google-github-actions/auth@v2\n")
+	b.WriteString("        with:\n")
+	b.WriteString("          workload_identity_provider: ${{ env.WORKLOAD_IDENTITY_PROVIDER }}\n")
+	b.WriteString("          service_account: ${{ env.SERVICE_ACCOUNT }}\n")
+	b.WriteString("      - uses: hashicorp/setup-terraform@v3\n")
+	b.WriteString("      - run: terraform init -input=false -backend-config=\"bucket=${{ env.TF_STATE_BUCKET }}\"\n")
+	for _, c := range commands {
+		fmt.Fprintf(b, "      - run: %s\n", c)
+	}
+}
+
+// Settings of the generated GitLab pipeline. The id_token goes to an absolute path
+// because the credential configuration refers to it regardless of the job's directory.
+const (
+	gitlabTerraformImage = "hashicorp/terraform:1.9"
+	gitlabIDTokenFile    = "/tmp/gcp_id_token"
+)
+
+// renderGitLabPipeline renders a .gitlab-ci.yml that authenticates through the GitLab WIF
+// provider with an id_token, plans on merge requests and applies on the branch
+func renderGitLabPipeline(cfg *Config, projectNumber string) string {
+	gl := cfg.WIF.GitLab
+	dir := filepath.ToSlash(filepath.Clean(cfg.Terraform.OutputDir))
+	provider := workloadIdentityProviderName(cfg, projectNumber, gl.ProviderID)
+	audience := "https://iam.googleapis.com/" + provider
+	if len(gl.AllowedAudiences) > 0 {
+		audience = gl.AllowedAudiences[0]
+	}
+	var b bytes.Buffer
+	b.WriteString(generatedFileHeader)
+	b.WriteString("stages: [plan, apply]\n\n")
+	b.WriteString("variables:\n")
+	fmt.Fprintf(&b, "  TF_ROOT: %q\n", dir)
+	fmt.Fprintf(&b, "  TF_STATE_BUCKET: %q\n", cfg.TFStateBucketName)
+	b.WriteString("  TF_IN_AUTOMATION: \"true\"\n")
+	b.WriteString("  TF_VAR_impersonate_tf_service_account: \"false\" # The job is already authenticated as the SA\n\n")
+	b.WriteString(".terraform:\n")
+	b.WriteString("  image:\n")
+	fmt.Fprintf(&b, "    name: %s\n", gitlabTerraformImage)
+	b.WriteString("    entrypoint: [\"\"]\n")
+	b.WriteString("  id_tokens:\n")
+	b.WriteString("    GCP_ID_TOKEN:\n")
+	fmt.Fprintf(&b, "      aud: %q\n", audience)
+	b.WriteString("  before_script:\n")
+	fmt.Fprintf(&b, "    - echo \"$GCP_ID_TOKEN\" > %s\n", gitlabIDTokenFile)
+	fmt.Fprintf(&b, "    - echo '%s' > \"$CI_PROJECT_DIR/.gcp_credentials.json\"\n", gitlabCredentialConfig(cfg, provider))
+	b.WriteString("    - export GOOGLE_APPLICATION_CREDENTIALS=\"$CI_PROJECT_DIR/.gcp_credentials.json\"\n")
+	b.WriteString("    - cd \"$TF_ROOT\"\n")
+	b.WriteString("    - terraform init -input=false -backend-config=\"bucket=$TF_STATE_BUCKET\"\n\n")
+	b.WriteString("plan:\n")
+	b.WriteString("  extends: .terraform\n")
+	b.WriteString("  stage: plan\n")
+	b.WriteString("  script:\n")
+	b.WriteString("    - terraform plan -input=false\n")
+	b.WriteString("  rules:\n")
+	b.WriteString("    - if: $CI_PIPELINE_SOURCE == \"merge_request_event\"\n")
+	fmt.Fprintf(&b, "      changes: [\"%s/**/*\"]\n", dir)
+	fmt.Fprintf(&b, "    - if: $CI_COMMIT_BRANCH == %q\n", gl.Branch)
+	fmt.Fprintf(&b, "      changes: [\"%s/**/*\"]\n\n", dir)
+	b.WriteString("apply:\n")
+	b.WriteString("  extends: .terraform\n")
+	b.WriteString("  stage: apply\n")
+	b.WriteString("  needs: [plan]\n")
+	b.WriteString("  script:\n")
+	b.WriteString("    - terraform apply -input=false -auto-approve\n")
+	b.WriteString("  rules:\n")
+	fmt.Fprintf(&b, "    - if: $CI_COMMIT_BRANCH == %q\n", gl.Branch)
+	fmt.Fprintf(&b, "      changes: [\"%s/**/*\"]\n", dir)
+	return b.String()
+}
+
+// gitlabCredentialConfig returns the external account credential configuration that
+// exchanges the job's id_token for the TF SA's credentials, so the image needs no gcloud
+func gitlabCredentialConfig(cfg *Config, provider string) string {
+	config := map[string]any{
+		"type":                              "external_account",
+		"audience":                          "//iam.googleapis.com/" + provider,
+		"subject_token_type":                "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":                         "https://sts.googleapis.com/v1/token",
+		"credential_source":                 map[string]string{"file": gitlabIDTokenFile},
+		"service_account_impersonation_url": fmt.Sprintf("https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken", cfg.TFServiceAccountEmail),
+	}
+	data, _ := json.Marshal(config) // Marshalling a map of strings cannot fail
+	return string(data)
+}
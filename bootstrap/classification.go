@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Supported data_classification values
+var dataClassifications = []string{"internal", "confidential", "restricted"}
+
+// dataClassificationLabel is the project label recording the classification
+const dataClassificationLabel = "data_classification"
+
+// classification returns the settings of the configured data classification, or nil if none is set
+func (c *Config) classification() *DataClassificationConfig {
+	if c.DataClassification == "" {
+		return nil
+	}
+	dc := c.DataClassifications[c.DataClassification]
+	return &dc
+}
+
+// validateDataClassification checks the classification mapping and that the enabled APIs
+// are allowed for the project's classification
+func validateDataClassification(cfg *Config) error {
+	for name := range cfg.DataClassifications {
+		if !slices.Contains(dataClassifications, name) {
+			return fmt.Errorf("data_classifications key '%s' must be one of %s", name, strings.Join(dataClassifications, ", "))
+		}
+	}
+	if cfg.DataClassification == "" {
+		return nil
+	}
+	if !slices.Contains(dataClassifications, cfg.DataClassification) {
+		return fmt.Errorf("data_classification must be one of %s, got '%s'", strings.Join(dataClassifications, ", "), cfg.DataClassification)
+	}
+	dc, ok := cfg.DataClassifications[cfg.DataClassification]
+	if !ok {
+		return fmt.Errorf("data_classification '%s' has no entry in data_classifications", cfg.DataClassification)
+	}
+	if dc.ParentFolder != "" && cfg.FolderID != "" && dc.ParentFolder != cfg.FolderID {
+		return fmt.Errorf("folder_id '%s' contradicts data_classifications.%s.parent_folder '%s'", cfg.FolderID, cfg.DataClassification, dc.ParentFolder)
+	}
+	if dc.ParentFolder != "" && cfg.FolderPath != "" {
+		return fmt.Errorf("folder_path and data_classifications.%s.parent_folder both set the project's parent; keep one", cfg.DataClassification)
+	}
+	for _, policy := range dc.OrgPolicies {
+		if !strings.HasPrefix(policy, "constraints/") {
+			return fmt.Errorf("data_classifications.%s.org_policies entry '%s' must start with 'constraints/'", cfg.DataClassification, policy)
+		}
+	}
+	if len(dc.AllowedAPIs) > 0 {
+		var denied []string
+		for _, api := range requestedAPIs(cfg) {
+			if !matchesAPI(dc.AllowedAPIs, api) {
+				denied = append(denied, api)
+			}
+		}
+		if len(denied) > 0 {
+			return fmt.Errorf("API(s) not allowed for '%s' data: %s", cfg.DataClassification, strings.Join(denied, ", "))
+		}
+	}
+	return nil
+}
+
+// applyClassificationPolicies enforces the boolean organization policy constraints of the
+// project's data classification
+func applyClassificationPolicies(ctx context.Context, cfg *Config) error {
+	dc := cfg.classification()
+	if dc == nil || len(dc.OrgPolicies) == 0 {
+		logInfo("Skipping data classification policies as per config.")
+		return nil
+	}
+	logInfo("Enforcing %d policy constraint(s) for '%s' data on project '%s'...", len(dc.OrgPolicies), cfg.DataClassification, cfg.ProjectID)
+	for _, constraint := range dc.OrgPolicies {
+		if err := runCommand(ctx, "gcloud", "resource-manager", "org-policies", "enable-enforce", constraint, "--project", cfg.ProjectID); err != nil {
+			return fmt.Errorf("failed to enforce %s: %w", constraint, err)
+		}
+	}
+	logInfo("Data classification policies applied.")
+	return nil
+}
+
+func planClassificationPolicies(cfg *Config) []planAction {
+	dc := cfg.classification()
+	if dc == nil {
+		return nil
+	}
+	var actions []planAction
+	for _, constraint := range dc.OrgPolicies {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Enforce %s for '%s' data", constraint, cfg.DataClassification),
+			Command:     []string{"gcloud", "resource-manager", "org-policies", "enable-enforce", constraint, "--project", cfg.ProjectID},
+		})
+	}
+	return actions
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inCloudShell reports whether the tool runs in Google Cloud Shell, which sets CLOUD_SHELL
+// and, once a project is selected, DEVSHELL_PROJECT_ID
+func inCloudShell() bool {
+	return os.Getenv("CLOUD_SHELL") == "true" || os.Getenv("DEVSHELL_PROJECT_ID") != ""
+}
+
+// noteCloudShell explains how a run in Cloud Shell differs: the session's credentials are
+// used, its active project is switched, and files outside the home directory do not survive
+// the session
+func noteCloudShell(cfg *Config) {
+	if !inCloudShell() {
+		return
+	}
+	logInfo("Running in Cloud Shell: gcloud uses your Cloud Shell credentials, which also serve as Application Default Credentials.")
+	if current := os.Getenv("DEVSHELL_PROJECT_ID"); current != "" && current != cfg.ProjectID {
+		logNotice("The Cloud Shell project switches from '%s' to '%s' for this session; 'gcloud config set project %s' switches back.", current, cfg.ProjectID, current)
+	}
+	if !cfg.writesPrivateKey() || cfg.keyInKeychain() || (cfg.GitHubSecrets.Enabled && !cfg.GitHubSecrets.KeepLocalKey) {
+		return
+	}
+	path, err := filepath.Abs(cfg.TFSAKeyPath)
+	if err != nil {
+		path = cfg.TFSAKeyPath
+	}
+	home, _ := os.UserHomeDir()
+	if home == "" || !strings.HasPrefix(path, home+string(filepath.Separator)) {
+		logWarning("tf_sa_key_path '%s' is outside your Cloud Shell home directory and is lost when the session ends.", path)
+	} else {
+		logWarning("tf_sa_key_path '%s' is in your Cloud Shell home directory, which is deleted after 120 days of inactivity, or when the session ends in ephemeral mode.", path)
+	}
+	logWarning("Alternatives: download the key with 'cloudshell download %s' and delete it here, upload it to GitHub (github_secrets), store keys in Secret Manager with 'rotate-key' (key_rotation.secret_id), or use Workload Identity Federation (wif) instead of a key.", path)
+}
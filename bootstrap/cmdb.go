@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// CMDB registration defaults
+const (
+	defaultCMDBTable       = "cmdb_ci_cloud_service_account"
+	defaultCMDBLookupField = "name"
+	defaultCMDBPasswordEnv = "SERVICENOW_PASSWORD"
+)
+
+// defaultCMDBFields is the field mapping used when cmdb.fields is empty
+var defaultCMDBFields = map[string]string{
+	"name":              projectIDPlaceholder,
+	"short_description": "{{project_name}}",
+}
+
+// cmdbTablePattern matches ServiceNow table names
+var cmdbTablePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// validateCMDB fills in the defaults of the ServiceNow registration and checks its placeholders
+func validateCMDB(cfg *Config) error {
+	c := &cfg.CMDB
+	if !c.Enabled {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("cmdb.url must be the https:// URL of the ServiceNow instance, got '%s'", c.URL)
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	if c.Table == "" {
+		c.Table = defaultCMDBTable
+	}
+	if !cmdbTablePattern.MatchString(c.Table) {
+		return fmt.Errorf("cmdb.table '%s' is not a ServiceNow table name", c.Table)
+	}
+	if c.LookupField == "" {
+		c.LookupField = defaultCMDBLookupField
+	}
+	if len(c.Fields) == 0 {
+		c.Fields = defaultCMDBFields
+	}
+	if _, ok := c.Fields[c.LookupField]; !ok {
+		return fmt.Errorf("cmdb.fields must map the lookup field '%s' that identifies the project's CI", c.LookupField)
+	}
+	supported := cmdbPlaceholders(cfg, "")
+	for field, value := range c.Fields {
+		for _, p := range templatePlaceholderPattern.FindAllString(value, -1) {
+			if _, ok := supported[p]; !ok {
+				return fmt.Errorf("cmdb.fields.%s has unknown placeholder '%s' (supported: %s)", field, p, strings.Join(sortedKeys(supported), ", "))
+			}
+		}
+	}
+	if c.Username == "" && c.TokenEnv == "" {
+		return fmt.Errorf("cmdb needs username (with the password in $%s or password_env) or token_env", defaultCMDBPasswordEnv)
+	}
+	if c.Username != "" && c.PasswordEnv == "" {
+		c.PasswordEnv = defaultCMDBPasswordEnv
+	}
+	return nil
+}
+
+// cmdbPlaceholders returns the values of the placeholders cmdb.fields can use
+func cmdbPlaceholders(cfg *Config, projectNumber string) map[string]string {
+	return map[string]string{
+		projectIDPlaceholder:     cfg.ProjectID,
+		projectNumberPlaceholder: projectNumber,
+		"{{project_name}}":       cfg.ProjectName,
+		"{{owner}}":              cfg.TFServiceAccountMetadata.Owner,
+		"{{environment}}":        cfg.Environment,
+		"{{environment_class}}":  cfg.EnvironmentClass,
+		"{{billing_account}}":    cfg.BillingAccountID,
+		"{{organization_id}}":    cfg.OrganizationID,
+		"{{state_bucket}}":       "gs://" + cfg.TFStateBucketName,
+		"{{tf_service_account}}": cfg.TFServiceAccountEmail,
+		"{{change_ticket}}":      cfg.ChangeTicket,
+	}
+}
+
+// cmdbRecord returns the CI fields with the placeholders filled in
+func cmdbRecord(cfg *Config, projectNumber string) map[string]string {
+	var pairs []string
+	for p, v := range cmdbPlaceholders(cfg, projectNumber) {
+		pairs = append(pairs, p, v)
+	}
+	r := strings.NewReplacer(pairs...)
+	record := map[string]string{}
+	for field, value := range cfg.CMDB.Fields {
+		record[field] = r.Replace(value)
+	}
+	return record
+}
+
+// registerCMDB registers the project as a configuration item in ServiceNow: the CI matching
+// the lookup field is updated, or created if there is none
+func registerCMDB(ctx context.Context, cfg *Config) error {
+	c := cfg.CMDB
+	if !c.Enabled {
+		logInfo("Skipping CMDB registration as per config.")
+		return nil
+	}
+	number, err := projectNumber(ctx, cfg.ProjectID)
+	if err != nil {
+		return err
+	}
+	record := cmdbRecord(cfg, number)
+	key := record[c.LookupField]
+	if fakeGCP != nil {
+		created, err := fakeGCP.registerCMDB(c.Table, key, record)
+		if err != nil {
+			return err
+		}
+		recordCMDBResult(c.Table, key, created)
+		return nil
+	}
+
+	query := url.Values{
+		"sysparm_query":  {c.LookupField + "=" + key},
+		"sysparm_fields": {"sys_id"},
+		"sysparm_limit":  {"1"},
+	}
+	var found struct {
+		Result []struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}
+	if err := cmdbRequest(ctx, c, http.MethodGet, "?"+query.Encode(), nil, &found); err != nil {
+		return fmt.Errorf("failed to look up the CI '%s' in %s: %w", key, c.Table, err)
+	}
+	if len(found.Result) > 0 {
+		logInfo("Updating CI '%s' in ServiceNow table '%s'...", key, c.Table)
+		if err := cmdbRequest(ctx, c, http.MethodPatch, "/"+found.Result[0].SysID, record, nil); err != nil {
+			return fmt.Errorf("failed to update the CI '%s': %w", key, err)
+		}
+		recordCMDBResult(c.Table, key, false)
+		return nil
+	}
+	logInfo("Creating CI '%s' in ServiceNow table '%s'...", key, c.Table)
+	if err := cmdbRequest(ctx, c, http.MethodPost, "", record, nil); err != nil {
+		return fmt.Errorf("failed to create the CI '%s': %w", key, err)
+	}
+	recordCMDBResult(c.Table, key, true)
+	return nil
+}
+
+// recordCMDBResult logs and records a created or updated CI
+func recordCMDBResult(table, key string, created bool) {
+	status := resourceExisted
+	if created {
+		status = resourceCreated
+	}
+	metrics.recordResource("cmdb_ci", table+"/"+key, status)
+	logInfo("Project registered in the CMDB as '%s' (%s).", key, table)
+}
+
+// cmdbRequest calls the ServiceNow Table API for the configured table and decodes the
+// response into out, if given
+func cmdbRequest(ctx context.Context, c CMDBConfig, method, suffix string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	endpoint := fmt.Sprintf("%s/api/now/table/%s%s", c.URL, c.Table, suffix)
+	logInfo("Executing: %s %s", method, strings.SplitN(endpoint, "?", 2)[0])
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.TokenEnv != "" {
+		token := os.Getenv(c.TokenEnv)
+		if token == "" {
+			return fmt.Errorf("$%s is empty", c.TokenEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		password := os.Getenv(c.PasswordEnv)
+		if password == "" {
+			return fmt.Errorf("$%s is empty", c.PasswordEnv)
+		}
+		req.SetBasicAuth(c.Username, password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to parse the ServiceNow response: %w", err)
+		}
+	}
+	return nil
+}
+
+func planCMDB(cfg *Config) []planAction {
+	c := cfg.CMDB
+	if !c.Enabled {
+		return nil
+	}
+	record := cmdbRecord(cfg, "<project-number>")
+	return []planAction{{
+		Description: fmt.Sprintf("Create or update the CI with %s '%s' in ServiceNow table '%s' at %s (fields: %s)", c.LookupField, record[c.LookupField], c.Table, c.URL, strings.Join(sortedKeys(record), ", ")),
+	}}
+}
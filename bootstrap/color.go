@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+)
+
+// ANSI escape sequences used for console output
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+	colorGray   = "\033[90m"
+)
+
+// colorEnabled records whether console output may contain ANSI colors
+var colorEnabled bool
+
+// setupColor enables colors unless disabled via --no-color, the NO_COLOR environment
+// variable (https://no-color.org), or because stdout is not a terminal
+func setupColor(noColor bool) {
+	colorEnabled = !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is attached to a character device (a TTY)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in the given color when colors are enabled
+func colorize(color, s string) string {
+	if !colorEnabled {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// levelColor returns the color used for a log level tag
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return colorRed
+	case level >= slog.LevelWarn:
+		return colorYellow
+	case level >= slog.LevelInfo:
+		return colorGreen
+	default:
+		return colorGray
+	}
+}
+
+// ansiPattern matches ANSI escape sequences
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// ansiStripWriter removes ANSI escape sequences before writing, keeping log files plain
+type ansiStripWriter struct {
+	w io.Writer
+}
+
+func (a ansiStripWriter) Write(p []byte) (int, error) {
+	if _, err := a.w.Write(ansiPattern.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -1,39 +1,535 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application configuration structure, matching config.yaml
 type Config struct {
-	BillingAccountID string `yaml:"billing_account_id"`
-	OrganizationID   string `yaml:"organization_id,omitempty"` // Optional
+	// Optional catalog template (catalog/<name>.yaml next to this file) this config is merged over
+	Extends string `yaml:"extends,omitempty"`
+	// Optional input variables referenced as {{var.NAME}}, set with --var/--var-file or prompted for
+	Variables map[string]VariableConfig `yaml:"variables,omitempty"`
 
-	ProjectID     string `yaml:"project_id"`
-	ProjectName   string `yaml:"project_name"`
-	ProjectRegion string `yaml:"project_region"`
+	// Optional environment classification; "production" enables hardened defaults
+	EnvironmentClass string `yaml:"environment_class,omitempty"`
+	// Strict turns tolerated failures (IAM grants, API enablement) into fatal errors
+	Strict bool `yaml:"strict,omitempty"`
 
-	TFStateBucketName string `yaml:"tf_state_bucket_name"`
+	BillingAccountID string            `yaml:"billing_account_id"`
+	BillingLink      BillingLinkConfig `yaml:"billing_link,omitempty"`    // Optional: link retries and subaccount creation
+	OrganizationID   string            `yaml:"organization_id,omitempty"` // Optional
+	FolderID         string            `yaml:"folder_id,omitempty"`       // Optional: create the project in this folder instead of the organization root
+	FolderPath       string            `yaml:"folder_path,omitempty"`     // Optional: folder chain below the organization, e.g. Engineering/Platform/prod, created if missing
 
-	TFServiceAccountName string `yaml:"tf_service_account_name"`
+	ProjectID       string `yaml:"project_id"`
+	ProjectIDPrefix string `yaml:"project_id_prefix,omitempty"` // Optional instead of project_id: generate <prefix>-<random suffix>, reused on re-runs
+	SeedProjectID   string `yaml:"seed_project_id,omitempty"`   // Optional: existing project hosting the TF SA and state bucket
+	ProjectName     string `yaml:"project_name"`
+	ProjectRegion   string `yaml:"project_region"`
 
-	GenerateTFSAKey bool   `yaml:"generate_tf_sa_key"`
-	TFSAKeyPath     string `yaml:"tf_sa_key_path"`
+	ProjectLabels map[string]string `yaml:"project_labels,omitempty"` // Optional: applied at creation and reconciled on re-runs
 
-	EnableAPIs []string `yaml:"enable_apis"`
+	TFStateBucketName          string `yaml:"tf_state_bucket_name"`
+	TFStateBucketNameTemplate  string `yaml:"tf_state_bucket_name_template,omitempty"` // Optional instead of tf_state_bucket_name, e.g. {{project_id}}-tfstate
+	TFStateBucketLocation      string `yaml:"tf_state_bucket_location,omitempty"`      // Region, multi-region or dual-region; defaults to project_region
+	TFStateBucketRetention     string `yaml:"tf_state_bucket_retention,omitempty"`     // Optional retention period, e.g. 7d
+	TFStateBucketRetentionLock bool   `yaml:"tf_state_bucket_retention_lock"`          // Permanently lock the retention policy
+	TFStateBucketRepairUBLA    bool   `yaml:"tf_state_bucket_repair_ubla"`             // Migrate the ACL of an existing legacy-ACL bucket to IAM and enable uniform access
+
+	TFStateBucketLabels       map[string]string `yaml:"tf_state_bucket_labels,omitempty"`               // Optional: applied at creation and reconciled on re-runs
+	TFStateBucketStorageClass string            `yaml:"tf_state_bucket_storage_class,omitempty"`        // Optional: STANDARD (GCS default), NEARLINE, COLDLINE or ARCHIVE
+	TFStateBucketAutoclass    bool              `yaml:"tf_state_bucket_autoclass,omitempty"`            // Optional: let GCS move objects between storage classes
+	TFStateBucketSoftDelete   string            `yaml:"tf_state_bucket_soft_delete_duration,omitempty"` // Optional: e.g. 7d, or 0 to disable; unset keeps the GCS default
+	TFStateBucketSARole       string            `yaml:"tf_state_bucket_sa_role,omitempty"`              // Optional: role granted to the TF SA on the state bucket only
+
+	TFStateBucketLifecycle     StateBucketLifecycleConfig     `yaml:"tf_state_bucket_lifecycle,omitempty"`      // Optional: prune noncurrent state versions
+	TFStateBucketAccessLogging StateBucketAccessLoggingConfig `yaml:"tf_state_bucket_access_logging,omitempty"` // Optional: audit who reads the state
+
+	TFServiceAccountName     string                 `yaml:"tf_service_account_name"`
+	TFServiceAccountMetadata ServiceAccountMetadata `yaml:"tf_service_account_metadata,omitempty"` // Optional: ownership metadata kept in the SA description
+
+	GenerateTFSAKey bool              `yaml:"generate_tf_sa_key"`
+	TFSAKeyPath     string            `yaml:"tf_sa_key_path"`
+	TFSAKeyStorage  string            `yaml:"tf_sa_key_storage,omitempty"` // file (default) or keychain
+	SAKeyMode       string            `yaml:"sa_key_mode,omitempty"`       // generate (default) or upload
+	TFSAPublicKey   string            `yaml:"tf_sa_public_key_path"`       // Public key uploaded in upload mode
+	KeyRotation     KeyRotationConfig `yaml:"key_rotation,omitempty"`      // Used by the rotate-key command
+
+	SAKeyCleanup SAKeyCleanupConfig `yaml:"sa_key_cleanup,omitempty"` // Optional: delete stale TF SA keys
+
+	EnableAPIs   []string `yaml:"enable_apis"`
+	APIAllowlist []string `yaml:"api_allowlist,omitempty"` // Optional: only these APIs (patterns like "*.googleapis.com" allowed) may be enabled
+	APIDenylist  []string `yaml:"api_denylist,omitempty"`  // Optional: these APIs may never be enabled
 
 	TFServiceAccountProjectRoles []string `yaml:"tf_service_account_project_roles"`
+	RolePreset                   string   `yaml:"role_preset,omitempty"` // Optional: curated project roles added to the list above
 	TFServiceAccountBillingRole  string   `yaml:"tf_service_account_billing_role"`
 
-	// Derived field, not directly from YAML
+	OpsServiceAccount OpsServiceAccountConfig `yaml:"ops_service_account,omitempty"` // Optional
+	BreakGlass        BreakGlassConfig        `yaml:"break_glass,omitempty"`         // Optional: time-bound emergency access
+	ProjectLien       ProjectLienConfig       `yaml:"project_lien,omitempty"`        // Optional: lien against deleting the project
+	Budget            BudgetConfig            `yaml:"budget,omitempty"`              // Optional: billing budget with alerts scoped to the project
+	BillingExport     BillingExportConfig     `yaml:"billing_export,omitempty"`      // Optional: BigQuery dataset for the Cloud Billing export
+	Fleet             FleetConfig             `yaml:"fleet,omitempty"`               // Optional
+
+	DomainRestrictedSharing DomainRestrictedSharingConfig `yaml:"domain_restricted_sharing,omitempty"` // Optional
+	OrgPolicies             OrgPoliciesConfig             `yaml:"org_policies,omitempty"`              // Optional: constraints set on the project
+	DefaultServiceAccounts  DefaultServiceAccountsConfig  `yaml:"default_service_accounts,omitempty"`  // Optional: hardening of Google's default SAs
+	AuditLogs               map[string][]string           `yaml:"audit_logs,omitempty"`                // Optional: service -> ADMIN_READ, DATA_READ and/or DATA_WRITE
+	EssentialContacts       map[string][]string           `yaml:"essential_contacts,omitempty"`        // Optional: notification category -> contact emails
+	LogSinks                []LogSinkConfig               `yaml:"log_sinks,omitempty"`                 // Optional: log sinks and the grants their writers need
+	APIKeys                 []APIKeyConfig                `yaml:"api_keys,omitempty"`                  // Optional: restricted API keys, e.g. for Maps or Firebase
+	EnableFirebase          bool                          `yaml:"enable_firebase,omitempty"`           // Optional: add Firebase to the project
+
+	DataClassification  string                              `yaml:"data_classification,omitempty"`  // Optional: internal, confidential or restricted
+	DataClassifications map[string]DataClassificationConfig `yaml:"data_classifications,omitempty"` // Placement and guardrails per classification
+
+	KMS KMSConfig `yaml:"kms,omitempty"` // Optional: customer-managed encryption key for the state bucket
+
+	WIF WIFConfig `yaml:"wif,omitempty"` // Optional: Workload Identity Federation for CI/CD
+
+	GitHubSecrets GitHubSecretsConfig `yaml:"github_secrets,omitempty"` // Optional: push credentials to GitHub Actions
+
+	Terraform TerraformConfig `yaml:"terraform,omitempty"` // Optional: generated Terraform files
+
+	Backstage BackstageConfig `yaml:"backstage,omitempty"` // Optional: Backstage catalog entry for the project
+	CMDB      CMDBConfig      `yaml:"cmdb,omitempty"`      // Optional: ServiceNow CMDB registration of the project
+
+	OrgBootstrap OrgBootstrapConfig `yaml:"org_bootstrap,omitempty"` // Used by the org-bootstrap mode
+
+	Folders []FolderConfig `yaml:"folders,omitempty"` // Optional: folder hierarchy under the organization
+
+	Steps StepsConfig `yaml:"steps,omitempty"` // Optional
+
+	// Optional per-step timeouts keyed by step ID, and what to do when one is exceeded
+	StepTimeouts   map[string]time.Duration `yaml:"step_timeouts,omitempty"`
+	TimeoutPolicy  string                   `yaml:"timeout_policy,omitempty"` // abort (default) or retry
+	TimeoutRetries int                      `yaml:"timeout_retries,omitempty"`
+
+	// Derived fields, not directly from YAML
+	Mode                  string `yaml:"-"` // bootstrapModeProject or bootstrapModeOrg
+	Environment           string `yaml:"-"` // --env: the entry of 'environments' merged over the shared settings
 	TFServiceAccountEmail string `yaml:"-"`
+	ConfigHash            string `yaml:"-"` // SHA-256 of the config file contents
+	AssumeYes             bool   `yaml:"-"` // --yes: skip interactive prompts during the run
+	ChangeTicket          string `yaml:"-"` // --change-ticket: change-management ticket the run is made under
+
+	Assumptions map[string]string `yaml:"-"` // --assume: step ID -> resource state declared for --plan instead of read
+}
+
+// FolderConfig is a folder of the hierarchy created under the organization
+type FolderConfig struct {
+	Name     string              `yaml:"name"`               // Display name, unique among siblings
+	IAM      map[string][]string `yaml:"iam,omitempty"`      // Role -> members granted on the folder
+	Children []FolderConfig      `yaml:"children,omitempty"` // Nested folders
+}
+
+// OrgBootstrapConfig holds settings that only apply to the org-bootstrap mode
+type OrgBootstrapConfig struct {
+	TFSAOrgRoles []string `yaml:"tf_sa_org_roles"` // Defaults to roles/resourcemanager.projectCreator
+}
+
+// TerraformConfig controls the Terraform files generated for the root module
+type TerraformConfig struct {
+	OutputDir          string `yaml:"output_dir"`          // Defaults to ./terraform
+	StatePrefix        string `yaml:"state_prefix"`        // Defaults to terraform/state
+	ImpersonateBackend bool   `yaml:"impersonate_backend"` // Access the state bucket by impersonating the TF SA
+	Overwrite          bool   `yaml:"overwrite"`           // Replace generated files that were modified
+
+	Format string `yaml:"format"` // terraform (default) or terragrunt
+
+	RequiredVersion       string `yaml:"required_version"`        // Terraform version constraint for provider.tf
+	GoogleProviderVersion string `yaml:"google_provider_version"` // hashicorp/google version constraint
+}
+
+// BackstageConfig generates a Backstage catalog-info.yaml entry for the project
+type BackstageConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Path      string   `yaml:"path,omitempty"`      // Defaults to catalog-info.yaml
+	Kind      string   `yaml:"kind,omitempty"`      // Resource (default) or Component
+	Type      string   `yaml:"type,omitempty"`      // Defaults to gcp-project (service for a Component)
+	Owner     string   `yaml:"owner,omitempty"`     // Backstage entity ref; defaults to tf_service_account_metadata.owner
+	System    string   `yaml:"system,omitempty"`    // Optional system the entry belongs to
+	Lifecycle string   `yaml:"lifecycle,omitempty"` // Components only; defaults to production for production environments, else experimental
+	Tags      []string `yaml:"tags,omitempty"`
+}
+
+// CMDBConfig registers the project as a configuration item through the ServiceNow Table API.
+// Secrets are read from the environment, never from the config.
+type CMDBConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	URL         string            `yaml:"url"`                    // e.g. https://acme.service-now.com
+	Table       string            `yaml:"table,omitempty"`        // Defaults to cmdb_ci_cloud_service_account
+	LookupField string            `yaml:"lookup_field,omitempty"` // Field identifying the project's CI; defaults to name
+	Fields      map[string]string `yaml:"fields,omitempty"`       // CI field -> value with placeholders like {{project_id}}
+	Username    string            `yaml:"username,omitempty"`     // Basic auth user
+	PasswordEnv string            `yaml:"password_env,omitempty"` // Defaults to SERVICENOW_PASSWORD
+	TokenEnv    string            `yaml:"token_env,omitempty"`    // OAuth bearer token instead of basic auth
+}
+
+// Output formats of the generated Terraform configuration
+const (
+	terraformFormatTerraform  = "terraform"
+	terraformFormatTerragrunt = "terragrunt"
+)
+
+// Terraform file generation defaults
+const (
+	defaultTerraformOutputDir   = "terraform"
+	defaultTerraformStatePrefix = "terraform/state"
+
+	defaultTerraformRequiredVersion = ">= 1.5.0"
+	defaultGoogleProviderVersion    = "~> 6.0"
+)
+
+// StepsConfig controls which built-in steps run
+type StepsConfig struct {
+	Disabled []string `yaml:"disabled"` // Step IDs to permanently skip
+}
+
+// isStepDisabled reports whether the step with the given ID is disabled
+func (c *Config) isStepDisabled(id string) bool {
+	for _, d := range c.Steps.Disabled {
+		if d == id {
+			return true
+		}
+	}
+	return false
+}
+
+// disableSteps adds step IDs to the disabled list, validating them
+func (c *Config) disableSteps(ids []string) error {
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if !isStepID(id) {
+			return fmt.Errorf("unknown step '%s' (valid steps: %s)", id, strings.Join(stepIDs(), ", "))
+		}
+		if !c.isStepDisabled(id) {
+			c.Steps.Disabled = append(c.Steps.Disabled, id)
+		}
+	}
+	return nil
+}
+
+// OpsServiceAccountConfig describes an optional read-only observability service account
+type OpsServiceAccountConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Name         string   `yaml:"name"`
+	Roles        []string `yaml:"roles"`
+	WIFPrincipal string   `yaml:"wif_principal,omitempty"` // Optional: principal allowed to impersonate via WIF
+
+	// Derived field, not directly from YAML
+	Email string `yaml:"-"`
+}
+
+// BudgetConfig is a Cloud Billing budget scoped to the project on its billing account
+type BudgetConfig struct {
+	Amount      float64   `yaml:"amount"`                 // Creates the budget when positive
+	Currency    string    `yaml:"currency,omitempty"`     // Must match the billing account's currency; defaults to USD
+	Thresholds  []float64 `yaml:"thresholds,omitempty"`   // Alert thresholds as fractions of the amount; defaults to 0.5, 0.9, 1.0
+	PubSubTopic string    `yaml:"pubsub_topic,omitempty"` // Optional existing topic (name or projects/P/topics/T) for programmatic alerts
+	DisplayName string    `yaml:"display_name,omitempty"` // Defaults to "<project_id> budget"
+}
+
+// BillingExportConfig is the BigQuery dataset the billing account's cost data is exported to
+type BillingExportConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	ProjectID string `yaml:"project_id,omitempty"` // Project holding the dataset; defaults to project_id
+	DatasetID string `yaml:"dataset_id,omitempty"` // Defaults to billing_export
+	Location  string `yaml:"location,omitempty"`   // BigQuery location; defaults to US
+}
+
+// ProjectLienConfig places a resource manager lien against deleting the project
+type ProjectLienConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Reason  string `yaml:"reason,omitempty"` // Shown to whoever tries to delete the project
+}
+
+// BreakGlassConfig is a time-bound emergency binding for a group on the new project
+type BreakGlassConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Group    string        `yaml:"group"`              // Group email, e.g. breakglass@example.com
+	Role     string        `yaml:"role,omitempty"`     // Defaults to roles/owner
+	Duration time.Duration `yaml:"duration,omitempty"` // How long the binding is valid after it is created; defaults to 72h
+
+	// Derived fields, not directly from YAML
+	Expires time.Time `yaml:"-"` // Expiry of the binding created or found by the run
+	Status  string    `yaml:"-"` // resourceCreated or resourceExisted once the step ran
+}
+
+// Supported environment_class values
+const (
+	environmentClassDevelopment = "development"
+	environmentClassStaging     = "staging"
+	environmentClassProduction  = "production"
+)
+
+// Ways the sa_key step provides a key for the TF SA
+const (
+	saKeyModeGenerate = "generate" // Google generates the key pair and the private key is downloaded
+	saKeyModeUpload   = "upload"   // The user's own public key is uploaded; no private key leaves their machine
+)
+
+// Where a generated private key is stored
+const (
+	saKeyStorageFile     = "file"     // At tf_sa_key_path
+	saKeyStorageKeychain = "keychain" // In the OS keychain or credential manager, never as a plain file
+)
+
+// writesPrivateKey reports whether the sa_key step downloads a private key to tf_sa_key_path
+func (c *Config) writesPrivateKey() bool {
+	return c.GenerateTFSAKey && c.SAKeyMode == saKeyModeGenerate
+}
+
+// keyInKeychain reports whether the generated private key is kept in the OS keychain
+func (c *Config) keyInKeychain() bool {
+	return c.writesPrivateKey() && c.TFSAKeyStorage == saKeyStorageKeychain
+}
+
+// isProduction reports whether the config is tagged as a production environment
+func (c *Config) isProduction() bool {
+	return c.EnvironmentClass == environmentClassProduction
+}
+
+// BillingLinkConfig tunes how the project is linked to billing. Freshly provisioned billing
+// accounts can take minutes to accept links, so failed links are retried with backoff.
+type BillingLinkConfig struct {
+	Attempts       int                     `yaml:"attempts,omitempty"`        // Default 6; 1 disables retries
+	InitialBackoff time.Duration           `yaml:"initial_backoff,omitempty"` // Default 10s, doubled per attempt up to 2m
+	Subaccount     BillingSubaccountConfig `yaml:"subaccount,omitempty"`
+}
+
+// BillingSubaccountConfig makes the billing step link the project to a subaccount of
+// billing_account_id (reseller scenarios), creating it if no subaccount has the display name
+type BillingSubaccountConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	DisplayName string `yaml:"display_name"`
+}
+
+// FleetConfig describes optional registration of the project with a GKE Hub fleet
+type FleetConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	HostProjectID string   `yaml:"host_project_id"`
+	TFSAHostRoles []string `yaml:"tf_sa_host_roles"` // Roles for the TF SA on the fleet host project
+}
+
+// WIFConfig describes the workload identity pool and CI/CD providers federated with the TF SA
+type WIFConfig struct {
+	PoolID         string                  `yaml:"pool_id"`
+	GitHub         GitHubWIFConfig         `yaml:"github,omitempty"`
+	GitLab         GitLabWIFConfig         `yaml:"gitlab,omitempty"`
+	TerraformCloud TerraformCloudWIFConfig `yaml:"terraform_cloud,omitempty"`
+	Providers      []OIDCProviderConfig    `yaml:"providers,omitempty"` // Generic OIDC providers for any other CI system
+}
+
+// enabled reports whether any WIF provider is configured
+func (w WIFConfig) enabled() bool {
+	return w.GitHub.Enabled || w.GitLab.Enabled || w.TerraformCloud.Enabled || len(w.Providers) > 0
+}
+
+// OIDCProviderConfig describes an arbitrary OIDC issuer federated with the pool
+type OIDCProviderConfig struct {
+	ID                 string            `yaml:"id"`
+	DisplayName        string            `yaml:"display_name"`
+	IssuerURI          string            `yaml:"issuer_uri"`
+	AllowedAudiences   []string          `yaml:"allowed_audiences,omitempty"`
+	AttributeMapping   map[string]string `yaml:"attribute_mapping"` // Must map google.subject
+	AttributeCondition string            `yaml:"attribute_condition,omitempty"`
+	// Principals allowed to impersonate: "subject/VALUE", "attribute.NAME/VALUE", "*" or full principal URIs
+	Principals      []string `yaml:"principals"`
+	ServiceAccounts []string `yaml:"service_accounts,omitempty"` // Defaults to the TF SA
+}
+
+// GitHubWIFConfig federates GitHub Actions OIDC tokens for a single repository
+type GitHubWIFConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	ProviderID   string `yaml:"provider_id"`
+	Repository   string `yaml:"repository"`    // e.g. my-org/my-infra-repo
+	Branch       string `yaml:"branch"`        // Branch the generated workflow applies from; defaults to main
+	WorkflowPath string `yaml:"workflow_path"` // Generated workflow; defaults to .github/workflows/terraform.yml
+}
+
+// GitLabWIFConfig federates GitLab CI OIDC id_tokens for a single GitLab project
+type GitLabWIFConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	ProviderID       string   `yaml:"provider_id"`
+	IssuerURI        string   `yaml:"issuer_uri"`   // Defaults to https://gitlab.com; set for self-managed GitLab
+	ProjectPath      string   `yaml:"project_path"` // e.g. my-group/my-infra-repo
+	Ref              string   `yaml:"ref,omitempty"`
+	AllowedAudiences []string `yaml:"allowed_audiences,omitempty"`
+	Branch           string   `yaml:"branch"`        // Branch the generated pipeline applies from; defaults to main
+	PipelinePath     string   `yaml:"pipeline_path"` // Generated pipeline; defaults to .gitlab-ci.yml
+}
+
+// TerraformCloudWIFConfig federates Terraform Cloud / HCP Terraform dynamic provider credentials
+type TerraformCloudWIFConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	ProviderID   string `yaml:"provider_id"`
+	IssuerURI    string `yaml:"issuer_uri"`   // Defaults to https://app.terraform.io; set for Terraform Enterprise
+	Organization string `yaml:"organization"` // Only runs in this organization may impersonate the TF SA
+	Project      string `yaml:"project,omitempty"`
+	Workspace    string `yaml:"workspace,omitempty"`
+}
+
+// Workload Identity Federation defaults
+const (
+	defaultWIFPoolID                = "ci-pool"
+	defaultGitHubProviderID         = "github"
+	defaultGitHubBranch             = "main" // Also the default for the GitLab pipeline
+	defaultGitHubWorkflowPath       = ".github/workflows/terraform.yml"
+	defaultGitLabProviderID         = "gitlab"
+	defaultGitLabIssuerURI          = "https://gitlab.com"
+	defaultGitLabPipelinePath       = ".gitlab-ci.yml"
+	defaultTerraformCloudProviderID = "terraform-cloud"
+	defaultTerraformCloudIssuerURI  = "https://app.terraform.io"
+)
+
+// GCP label key and value formats
+var (
+	folderIDPattern   = regexp.MustCompile(`^[0-9]+$`)
+	labelKeyPattern   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	labelValuePattern = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+)
+
+// bucketStorageClasses are the storage classes accepted for the state bucket
+var bucketStorageClasses = []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE"}
+
+// KMSConfig encrypts the state bucket with a customer-managed Cloud KMS key (CMEK)
+type KMSConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	KeyRing        string        `yaml:"key_ring"`        // Created in project_region; defaults to tfstate
+	Key            string        `yaml:"key"`             // Defaults to tfstate
+	RotationPeriod time.Duration `yaml:"rotation_period"` // Defaults to 2160h (90 days)
+}
+
+// ServiceAccountMetadata is ownership metadata for the TF SA. Service accounts do not
+// support labels, so it is stored in the description as "purpose=...; owner=...; ticket=...".
+type ServiceAccountMetadata struct {
+	Purpose string `yaml:"purpose,omitempty"`
+	Owner   string `yaml:"owner,omitempty"` // e.g. a team or group email
+	Ticket  string `yaml:"ticket,omitempty"`
+
+	// Derived field, not directly from YAML
+	ChangeTicket string `yaml:"-"` // --change-ticket of the run that last updated the description
+}
+
+// StateBucketLifecycleConfig prunes noncurrent versions of state objects, which otherwise
+// accumulate forever once versioning is enabled
+type StateBucketLifecycleConfig struct {
+	MaxNoncurrentVersions int `yaml:"max_noncurrent_versions"` // Keep at most this many noncurrent versions per object
+	NoncurrentAgeDays     int `yaml:"noncurrent_age_days"`     // Delete versions noncurrent for longer than this
+}
+
+// StateBucketAccessLoggingConfig enables usage and storage logging on the state bucket
+type StateBucketAccessLoggingConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	LogBucket      string `yaml:"log_bucket,omitempty"`       // Defaults to <tf_state_bucket_name>-logs
+	ObjectPrefix   string `yaml:"object_prefix,omitempty"`    // Defaults to tf_state_bucket_name
+	DataAccessLogs bool   `yaml:"data_access_logs,omitempty"` // Also enable Data Access audit logs for storage.googleapis.com on the project
+}
+
+// KeyRotationConfig controls where rotate-key stores new keys and which old keys it deletes
+type KeyRotationConfig struct {
+	SecretID   string `yaml:"secret_id,omitempty"` // Store new keys in this Secret Manager secret instead of tf_sa_key_path
+	MaxAgeDays int    `yaml:"max_age_days"`        // Delete user-managed keys older than this; defaults to 30
+}
+
+// SAKeyCleanupConfig selects the stale user-managed TF SA keys the sa_key_cleanup step deletes
+type SAKeyCleanupConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	MaxAgeDays int  `yaml:"max_age_days"` // Delete keys older than this; defaults to 90
+	KeepNewest bool `yaml:"keep_newest"`  // Instead delete all keys but the newest
 }
 
-// loadConfig reads the YAML configuration file and parses it into the Config struct
-func loadConfig(configPath string) (*Config, error) {
+// GitHubSecretsConfig uploads the SA key and WIF details to a GitHub repository's Actions secrets and variables
+type GitHubSecretsConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Repository   string `yaml:"repository"`      // Defaults to wif.github.repository
+	Token        string `yaml:"token,omitempty"` // Passed to gh as GH_TOKEN; defaults to gh's own authentication
+	KeepLocalKey bool   `yaml:"keep_local_key"`  // Keep the SA key file after uploading it
+}
+
+// DomainRestrictedSharingConfig restricts which Workspace/Cloud Identity customers may be granted IAM roles
+type DomainRestrictedSharingConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	CustomerIDs []string `yaml:"customer_ids"` // Directory customer IDs, e.g. C0abc123
+}
+
+// OrgPoliciesConfig lists organization policy constraints set on the project. Names may
+// omit the constraints/ prefix.
+type OrgPoliciesConfig struct {
+	Enforce []string            `yaml:"enforce,omitempty"` // Boolean constraints, e.g. iam.disableServiceAccountKeyCreation
+	Allow   map[string][]string `yaml:"allow,omitempty"`   // List constraint -> the only values allowed
+	Deny    map[string][]string `yaml:"deny,omitempty"`    // List constraint -> values denied
+}
+
+// DefaultServiceAccountsConfig hardens the default service accounts Google creates when the
+// Compute Engine or App Engine API is enabled
+type DefaultServiceAccountsConfig struct {
+	RemoveEditor bool `yaml:"remove_editor"` // Remove the roles/editor grant
+	Disable      bool `yaml:"disable"`       // Disable the accounts; workloads must then use their own SAs
+}
+
+// APIKeyConfig is an API key restricted to APIs and optionally to one kind of application
+type APIKeyConfig struct {
+	ID                 string              `yaml:"id"` // Key ID, unique in the project
+	DisplayName        string              `yaml:"display_name,omitempty"`
+	APITargets         []string            `yaml:"api_targets"`                    // Services the key may call, e.g. maps-backend.googleapis.com
+	AllowedReferrers   []string            `yaml:"allowed_referrers,omitempty"`    // Browser keys: HTTP referrers, e.g. "https://example.com/*"
+	AllowedIPs         []string            `yaml:"allowed_ips,omitempty"`          // Server keys: caller IPs or CIDR ranges
+	AllowedBundleIDs   []string            `yaml:"allowed_bundle_ids,omitempty"`   // iOS apps
+	AllowedAndroidApps []AndroidAppKeyRule `yaml:"allowed_android_apps,omitempty"` // Android apps
+}
+
+// AndroidAppKeyRule identifies an Android app allowed to use an API key
+type AndroidAppKeyRule struct {
+	PackageName string `yaml:"package_name"`
+	SHA1        string `yaml:"sha1"` // Fingerprint of the signing certificate
+}
+
+// VariableConfig declares an input variable of the config
+type VariableConfig struct {
+	Type        string `yaml:"type,omitempty"`        // string (default), number, bool or list
+	Default     any    `yaml:"default,omitempty"`     // Without a default the value must be given or is prompted for
+	Description string `yaml:"description,omitempty"` // Shown when prompting
+}
+
+// LogSinkConfig is a Cloud Logging sink exporting the logs matching a filter
+type LogSinkConfig struct {
+	Name                 string `yaml:"name"`
+	Destination          string `yaml:"destination"`                      // state_bucket, bucket:NAME, bigquery:[PROJECT.]DATASET or a full sink destination
+	Filter               string `yaml:"filter,omitempty"`                 // Empty exports all logs
+	Scope                string `yaml:"scope,omitempty"`                  // project (default), or organization/folder for an aggregated sink including children
+	UsePartitionedTables bool   `yaml:"use_partitioned_tables,omitempty"` // BigQuery destinations only
+}
+
+// DataClassificationConfig is the placement and guardrails of projects holding data of one classification
+type DataClassificationConfig struct {
+	ParentFolder string   `yaml:"parent_folder,omitempty"` // Folder ID new projects are created in, e.g. an Assured Workloads folder
+	OrgPolicies  []string `yaml:"org_policies,omitempty"`  // Boolean constraints enforced on the project
+	AllowedAPIs  []string `yaml:"allowed_apis,omitempty"`  // If set, the APIs the config enables must match these
+}
+
+// defaultFleetTFSAHostRoles are granted to the TF SA on the fleet host project when none are configured
+var defaultFleetTFSAHostRoles = []string{"roles/gkehub.admin"}
+
+// defaultOpsServiceAccountRoles are granted to the ops SA when no roles are configured
+var defaultOpsServiceAccountRoles = []string{"roles/logging.viewer", "roles/monitoring.viewer"}
+
+// loadConfig reads the YAML configuration file, applies the command-line overrides in
+// order and parses the result into the Config struct
+func loadConfig(ctx context.Context, configPath, mode string, overrides []configOverride) (*Config, error) {
 	logInfo("Reading configuration from %s...", configPath)
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("configuration file not found at %s. Please copy config.yaml.example to config.yaml and fill it out", configPath)
@@ -44,25 +540,118 @@ func loadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("error reading config file %s: %w", configPath, err)
 	}
 
-	var cfg Config
+	if err := checkVariableRefs(yamlFile); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	// Merge over the catalog template first so the template is covered by the hash
+	if yamlFile, err = resolveExtends(configPath, yamlFile); err != nil {
+		return nil, fmt.Errorf("error resolving extends in %s: %w", configPath, err)
+	}
+	if yamlFile, err = selectEnvironment(yamlFile, configEnvironment); err != nil {
+		return nil, fmt.Errorf("error selecting environment in %s: %w", configPath, err)
+	}
+	if yamlFile, err = selectProjectEntry(yamlFile, configProjectEntry); err != nil {
+		return nil, fmt.Errorf("error selecting project entry in %s: %w", configPath, err)
+	}
+	if yamlFile, err = resolveVariables(yamlFile); err != nil {
+		return nil, fmt.Errorf("error resolving variables in %s: %w", configPath, err)
+	}
+
+	// The hash covers the overrides too, so history diffs show runs with different --set values
+	hashed := yamlFile
+	if len(overrides) > 0 {
+		for _, o := range overrides {
+			hashed = append(hashed, "\n# "+o.Flag...)
+		}
+		if yamlFile, err = applyConfigOverrides(yamlFile, overrides); err != nil {
+			return nil, fmt.Errorf("error applying overrides to %s: %w", configPath, err)
+		}
+	}
+
+	cfg := Config{Mode: bootstrapModeProject}
 	err = yaml.Unmarshal(yamlFile, &cfg)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing config file %s: %w", configPath, err)
 	}
+	cfg.ConfigHash = fmt.Sprintf("%x", sha256.Sum256(hashed))
+	cfg.Environment = configEnvironment
+
+	// Validate environment class and apply hardened production defaults
+	switch cfg.EnvironmentClass {
+	case "", environmentClassDevelopment, environmentClassStaging:
+	case environmentClassProduction:
+		if cfg.GenerateTFSAKey {
+			return nil, fmt.Errorf("generate_tf_sa_key must be false for production configs in %s; use Workload Identity Federation or impersonation instead", configPath)
+		}
+		if !cfg.Strict {
+			logInfo("Production config: enabling strict mode.")
+			cfg.Strict = true
+		}
+	default:
+		return nil, fmt.Errorf("environment_class must be one of '%s', '%s' or '%s' in %s", environmentClassDevelopment, environmentClassStaging, environmentClassProduction, configPath)
+	}
 
 	// Validate required fields
 	if cfg.BillingAccountID == "" || cfg.BillingAccountID == "0X0X0X-XXXXXX-XXXXXX" {
 		return nil, fmt.Errorf("billing_account_id is not set or is placeholder in %s", configPath)
 	}
+	bl := &cfg.BillingLink
+	if bl.Attempts < 0 || bl.InitialBackoff < 0 {
+		return nil, fmt.Errorf("billing_link values must not be negative in %s", configPath)
+	}
+	if bl.Attempts == 0 {
+		bl.Attempts = defaultBillingLinkAttempts
+	}
+	if bl.InitialBackoff == 0 {
+		bl.InitialBackoff = defaultBillingLinkBackoff
+	}
+	if bl.Subaccount.Enabled && bl.Subaccount.DisplayName == "" {
+		return nil, fmt.Errorf("billing_link.subaccount.display_name is required when the subaccount is enabled in %s", configPath)
+	}
+	if cfg.ProjectIDPrefix != "" {
+		if cfg.ProjectID != "" && cfg.ProjectID != "your-unique-project-id" {
+			return nil, fmt.Errorf("project_id and project_id_prefix are mutually exclusive in %s", configPath)
+		}
+		if err := validateProjectIDPrefix(cfg.ProjectIDPrefix); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, configPath)
+		}
+		// Resolved by the project step; see resolveProjectIDPrefix
+		cfg.ProjectID = cfg.ProjectIDPrefix + "-" + projectIDSuffixPlaceholder
+	}
 	if cfg.ProjectID == "" || cfg.ProjectID == "your-unique-project-id" {
 		return nil, fmt.Errorf("project_id is not set or is placeholder in %s", configPath)
 	}
 	if cfg.ProjectName == "" {
 		return nil, fmt.Errorf("project_name is not set in %s", configPath)
 	}
+	if cfg.SeedProjectID != "" {
+		if cfg.SeedProjectID == cfg.ProjectID {
+			return nil, fmt.Errorf("seed_project_id must differ from project_id in %s; leave it unset to host everything in the project", configPath)
+		}
+		if cfg.TFStateBucketSARole == "" {
+			// Project roles on the workload project do not cover the bucket in the seed project
+			cfg.TFStateBucketSARole = defaultSeedBucketSARole
+		}
+	}
 	if cfg.ProjectRegion == "" {
 		return nil, fmt.Errorf("project_region is not set in %s", configPath)
 	}
+	if cfg.TFStateBucketLocation == "" {
+		cfg.TFStateBucketLocation = cfg.ProjectRegion
+	}
+	location, err := normalizeBucketLocation(cfg.TFStateBucketLocation)
+	if err != nil {
+		return nil, fmt.Errorf("tf_state_bucket_location: %w in %s", err, configPath)
+	}
+	cfg.TFStateBucketLocation = location
+	if cfg.TFStateBucketNameTemplate != "" {
+		if cfg.TFStateBucketName != "" && cfg.TFStateBucketName != "your-unique-tfstate-bucket-name-xyz" {
+			return nil, fmt.Errorf("tf_state_bucket_name and tf_state_bucket_name_template are mutually exclusive in %s", configPath)
+		}
+		if err := expandBucketNameTemplate(ctx, &cfg); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, configPath)
+		}
+	}
 	if cfg.TFStateBucketName == "" || cfg.TFStateBucketName == "your-unique-tfstate-bucket-name-xyz" {
 		return nil, fmt.Errorf("tf_state_bucket_name is not set or is placeholder in %s", configPath)
 	}
@@ -72,15 +661,343 @@ func loadConfig(configPath string) (*Config, error) {
 	if len(cfg.EnableAPIs) == 0 {
 		logWarning("No APIs listed under 'enable_apis' in config. Ensure essential APIs are enabled.")
 	}
+	if cfg.RolePreset != "" {
+		roles, err := expandRolePreset(cfg.RolePreset, cfg.TFServiceAccountProjectRoles)
+		if err != nil {
+			return nil, fmt.Errorf("%w in %s", err, configPath)
+		}
+		cfg.TFServiceAccountProjectRoles = roles
+	}
 	if len(cfg.TFServiceAccountProjectRoles) == 0 {
-		return nil, fmt.Errorf("tf_service_account_project_roles list is empty in %s", configPath)
+		return nil, fmt.Errorf("tf_service_account_project_roles list is empty and no role_preset is set in %s; run 'gcp-bootstrap roles' to pick roles", configPath)
+	}
+	if mode == bootstrapModeOrg {
+		if err := cfg.enableOrgMode(); err != nil {
+			return nil, fmt.Errorf("%w (set it in %s)", err, configPath)
+		}
 	}
 	if cfg.TFServiceAccountBillingRole == "" {
 		logWarning("tf_service_account_billing_role is not set in config. Terraform SA won't be able to link other projects to billing.")
 	}
 
-	// Derive SA email
-	cfg.TFServiceAccountEmail = fmt.Sprintf("%s@%s.iam.gserviceaccount.com", cfg.TFServiceAccountName, cfg.ProjectID)
+	if cfg.OpsServiceAccount.Enabled {
+		if cfg.OpsServiceAccount.Name == "" {
+			return nil, fmt.Errorf("ops_service_account.name is not set in %s", configPath)
+		}
+		if cfg.OpsServiceAccount.Name == cfg.TFServiceAccountName {
+			return nil, fmt.Errorf("ops_service_account.name must differ from tf_service_account_name in %s", configPath)
+		}
+		if len(cfg.OpsServiceAccount.Roles) == 0 {
+			cfg.OpsServiceAccount.Roles = defaultOpsServiceAccountRoles
+		}
+	}
+
+	if bg := &cfg.BreakGlass; bg.Enabled {
+		bg.Group = strings.TrimPrefix(bg.Group, "group:")
+		if !strings.Contains(bg.Group, "@") {
+			return nil, fmt.Errorf("break_glass.group must be a group email address, got '%s' in %s", bg.Group, configPath)
+		}
+		if bg.Role == "" {
+			bg.Role = defaultBreakGlassRole
+		}
+		if slices.Contains(basicRoles, bg.Role) {
+			return nil, fmt.Errorf("break_glass.role cannot be the basic role '%s', as IAM rejects the expiry condition on basic roles; use a predefined role in %s", bg.Role, configPath)
+		}
+		if bg.Duration < 0 {
+			return nil, fmt.Errorf("break_glass.duration must not be negative in %s", configPath)
+		}
+		if bg.Duration == 0 {
+			bg.Duration = defaultBreakGlassDuration
+		}
+	}
+
+	if cfg.ProjectLien.Enabled && cfg.ProjectLien.Reason == "" {
+		cfg.ProjectLien.Reason = defaultLienReason
+	}
+
+	if cfg.Fleet.Enabled {
+		if cfg.Fleet.HostProjectID == "" {
+			return nil, fmt.Errorf("fleet.host_project_id is not set in %s", configPath)
+		}
+		if len(cfg.Fleet.TFSAHostRoles) == 0 {
+			cfg.Fleet.TFSAHostRoles = defaultFleetTFSAHostRoles
+		}
+	}
+
+	if cfg.FolderID != "" {
+		cfg.FolderID = strings.TrimPrefix(cfg.FolderID, "folders/")
+		if !folderIDPattern.MatchString(cfg.FolderID) {
+			return nil, fmt.Errorf("folder_id '%s' is not a numeric folder ID in %s", cfg.FolderID, configPath)
+		}
+		if cfg.OrganizationID != "" {
+			return nil, fmt.Errorf("folder_id and organization_id are mutually exclusive; the project is created under one parent in %s", configPath)
+		}
+	}
+
+	if cfg.FolderPath != "" {
+		cfg.FolderPath = strings.Trim(cfg.FolderPath, "/")
+		if cfg.OrganizationID == "" {
+			return nil, fmt.Errorf("folder_path requires organization_id to be set in %s", configPath)
+		}
+		if cfg.FolderID != "" {
+			return nil, fmt.Errorf("folder_path and folder_id are mutually exclusive in %s", configPath)
+		}
+		if err := validateFolderPath(cfg.FolderPath); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, configPath)
+		}
+	}
+
+	if len(cfg.Folders) > 0 {
+		if cfg.OrganizationID == "" {
+			return nil, fmt.Errorf("folders require organization_id to be set in %s", configPath)
+		}
+		if err := validateFolders(cfg.Folders, ""); err != nil {
+			return nil, fmt.Errorf("invalid folders in %s: %w", configPath, err)
+		}
+	}
+
+	if cfg.DomainRestrictedSharing.Enabled {
+		if len(cfg.DomainRestrictedSharing.CustomerIDs) == 0 {
+			return nil, fmt.Errorf("domain_restricted_sharing.customer_ids is empty in %s", configPath)
+		}
+		for _, id := range cfg.DomainRestrictedSharing.CustomerIDs {
+			if !strings.HasPrefix(id, "C") {
+				return nil, fmt.Errorf("domain_restricted_sharing.customer_ids entry '%s' is not a customer ID (expected e.g. 'C0abc123') in %s", id, configPath)
+			}
+		}
+	}
+
+	if err := validateAPIKeys(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateCMDB(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateBackstage(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateBillingExport(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateBudget(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateLogSinks(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateEssentialContacts(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateAuditLogs(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateOrgPolicies(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+
+	if cfg.WIF.PoolID == "" {
+		cfg.WIF.PoolID = defaultWIFPoolID
+	}
+	if gh := &cfg.WIF.GitHub; gh.Enabled {
+		if !strings.Contains(gh.Repository, "/") {
+			return nil, fmt.Errorf("wif.github.repository must be 'owner/repo' in %s", configPath)
+		}
+		if gh.ProviderID == "" {
+			gh.ProviderID = defaultGitHubProviderID
+		}
+		if gh.Branch == "" {
+			gh.Branch = defaultGitHubBranch
+		}
+		if gh.WorkflowPath == "" {
+			gh.WorkflowPath = defaultGitHubWorkflowPath
+		}
+		if cfg.Terraform.ImpersonateBackend {
+			logWarning("The generated GitHub workflow authenticates as the Terraform SA; with terraform.impersonate_backend the SA needs roles/iam.serviceAccountTokenCreator on itself.")
+		}
+	}
+	if gl := &cfg.WIF.GitLab; gl.Enabled {
+		if gl.ProjectPath == "" {
+			return nil, fmt.Errorf("wif.gitlab.project_path is not set in %s", configPath)
+		}
+		if gl.ProviderID == "" {
+			gl.ProviderID = defaultGitLabProviderID
+		}
+		if gl.IssuerURI == "" {
+			gl.IssuerURI = defaultGitLabIssuerURI
+		}
+		if gl.Branch == "" {
+			gl.Branch = defaultGitHubBranch
+		}
+		if gl.PipelinePath == "" {
+			gl.PipelinePath = defaultGitLabPipelinePath
+		}
+	}
+	if tfc := &cfg.WIF.TerraformCloud; tfc.Enabled {
+		if tfc.Organization == "" {
+			return nil, fmt.Errorf("wif.terraform_cloud.organization is not set in %s", configPath)
+		}
+		if tfc.ProviderID == "" {
+			tfc.ProviderID = defaultTerraformCloudProviderID
+		}
+		if tfc.IssuerURI == "" {
+			tfc.IssuerURI = defaultTerraformCloudIssuerURI
+		}
+	}
+
+	switch cfg.SAKeyMode {
+	case "":
+		cfg.SAKeyMode = saKeyModeGenerate
+	case saKeyModeGenerate:
+	case saKeyModeUpload:
+		if cfg.GenerateTFSAKey && cfg.TFSAPublicKey == "" {
+			return nil, fmt.Errorf("tf_sa_public_key_path is required with sa_key_mode '%s' in %s", saKeyModeUpload, configPath)
+		}
+	default:
+		return nil, fmt.Errorf("sa_key_mode must be '%s' or '%s', got '%s' in %s", saKeyModeGenerate, saKeyModeUpload, cfg.SAKeyMode, configPath)
+	}
+	switch cfg.TFSAKeyStorage {
+	case "":
+		cfg.TFSAKeyStorage = saKeyStorageFile
+	case saKeyStorageFile:
+	case saKeyStorageKeychain:
+		if cfg.SAKeyMode != saKeyModeGenerate {
+			return nil, fmt.Errorf("tf_sa_key_storage '%s' requires sa_key_mode '%s' in %s", saKeyStorageKeychain, saKeyModeGenerate, configPath)
+		}
+	default:
+		return nil, fmt.Errorf("tf_sa_key_storage must be '%s' or '%s', got '%s' in %s", saKeyStorageFile, saKeyStorageKeychain, cfg.TFSAKeyStorage, configPath)
+	}
+	if kms := &cfg.KMS; kms.Enabled {
+		if kms.KeyRing == "" {
+			kms.KeyRing = defaultKMSKeyRing
+		}
+		if kms.Key == "" {
+			kms.Key = defaultKMSKey
+		}
+		if kms.RotationPeriod == 0 {
+			kms.RotationPeriod = defaultKMSRotationPeriod
+		}
+		if kms.RotationPeriod < 24*time.Hour {
+			return nil, fmt.Errorf("kms.rotation_period must be at least 24h, got %s in %s", kms.RotationPeriod, configPath)
+		}
+	}
+	if err := cfg.TFServiceAccountMetadata.validate(); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	for key, value := range cfg.TFStateBucketLabels {
+		if !labelKeyPattern.MatchString(key) || !labelValuePattern.MatchString(value) {
+			return nil, fmt.Errorf("tf_state_bucket_labels entry '%s: %s' is invalid: keys must start with a lowercase letter and keys and values may only contain lowercase letters, digits, '_' and '-' (at most 63 characters) in %s", key, value, configPath)
+		}
+	}
+	for key, value := range cfg.ProjectLabels {
+		if !labelKeyPattern.MatchString(key) || !labelValuePattern.MatchString(value) {
+			return nil, fmt.Errorf("project_labels entry '%s: %s' is invalid: keys must start with a lowercase letter and keys and values may only contain lowercase letters, digits, '_' and '-' (at most 63 characters) in %s", key, value, configPath)
+		}
+	}
+	if value, ok := cfg.ProjectLabels[dataClassificationLabel]; ok && cfg.DataClassification != "" && value != cfg.DataClassification {
+		return nil, fmt.Errorf("project_labels.%s '%s' contradicts data_classification '%s' in %s", dataClassificationLabel, value, cfg.DataClassification, configPath)
+	}
+	if class := strings.ToUpper(cfg.TFStateBucketStorageClass); class != "" {
+		if !slices.Contains(bucketStorageClasses, class) {
+			return nil, fmt.Errorf("tf_state_bucket_storage_class must be one of %s, got '%s' in %s", strings.Join(bucketStorageClasses, ", "), cfg.TFStateBucketStorageClass, configPath)
+		}
+		cfg.TFStateBucketStorageClass = class
+	}
+	if cfg.TFStateBucketAutoclass && cfg.TFStateBucketStorageClass != "" && cfg.TFStateBucketStorageClass != "STANDARD" {
+		return nil, fmt.Errorf("tf_state_bucket_autoclass manages storage classes itself; remove tf_state_bucket_storage_class '%s' in %s", cfg.TFStateBucketStorageClass, configPath)
+	}
+	if err := validateAPIPolicy(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if err := validateDataClassification(&cfg); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, configPath)
+	}
+	if cfg.TFStateBucketSoftDelete != "" {
+		if _, err := softDeleteSeconds(cfg.TFStateBucketSoftDelete); err != nil {
+			return nil, fmt.Errorf("tf_state_bucket_soft_delete_duration: %w in %s", err, configPath)
+		}
+	}
+	if cfg.TFStateBucketAccessLogging.Enabled {
+		if name := cfg.logBucketName(); name == cfg.TFStateBucketName {
+			return nil, fmt.Errorf("tf_state_bucket_access_logging.log_bucket must differ from tf_state_bucket_name in %s", configPath)
+		} else if len(name) > 63 {
+			return nil, fmt.Errorf("log bucket name '%s' is longer than 63 characters; set tf_state_bucket_access_logging.log_bucket in %s", name, configPath)
+		}
+	}
+	if lc := cfg.TFStateBucketLifecycle; lc.MaxNoncurrentVersions < 0 || lc.NoncurrentAgeDays < 0 {
+		return nil, fmt.Errorf("tf_state_bucket_lifecycle values must not be negative in %s", configPath)
+	}
+	if cfg.TFStateBucketRetention != "" && !retentionPattern.MatchString(cfg.TFStateBucketRetention) {
+		return nil, fmt.Errorf("tf_state_bucket_retention must be a duration like 7d, 1y or 1y6m, got '%s' in %s", cfg.TFStateBucketRetention, configPath)
+	}
+	if cfg.TFStateBucketRetentionLock && cfg.TFStateBucketRetention == "" {
+		return nil, fmt.Errorf("tf_state_bucket_retention_lock requires tf_state_bucket_retention in %s", configPath)
+	}
+	if cfg.SAKeyCleanup.MaxAgeDays <= 0 {
+		cfg.SAKeyCleanup.MaxAgeDays = defaultKeyCleanupMaxAgeDays
+	}
+	if cfg.KeyRotation.MaxAgeDays <= 0 {
+		cfg.KeyRotation.MaxAgeDays = defaultKeyMaxAgeDays
+	}
+
+	if gs := &cfg.GitHubSecrets; gs.Enabled {
+		if gs.Repository == "" {
+			gs.Repository = cfg.WIF.GitHub.Repository
+		}
+		if gs.Repository == "" {
+			return nil, fmt.Errorf("github_secrets.repository is required (or set wif.github.repository) in %s", configPath)
+		}
+	}
+
+	if cfg.Terraform.OutputDir == "" {
+		// Keep the generated files of each environment and bulk project entry apart
+		cfg.Terraform.OutputDir = filepath.Join(defaultTerraformOutputDir, cfg.Environment, configProjectEntry)
+	}
+	switch cfg.Terraform.Format {
+	case "":
+		cfg.Terraform.Format = terraformFormatTerraform
+	case terraformFormatTerraform:
+	case terraformFormatTerragrunt:
+		if cfg.WIF.GitHub.Enabled || cfg.WIF.GitLab.Enabled {
+			logWarning("CI pipelines are not generated for terraform.format '%s'; only terragrunt.hcl is written.", terraformFormatTerragrunt)
+		}
+	default:
+		return nil, fmt.Errorf("terraform.format must be '%s' or '%s', got '%s' in %s", terraformFormatTerraform, terraformFormatTerragrunt, cfg.Terraform.Format, configPath)
+	}
+	if cfg.Terraform.StatePrefix == "" {
+		cfg.Terraform.StatePrefix = defaultTerraformStatePrefix
+		if cfg.Environment != "" {
+			// Environments may share one state bucket, so each gets its own prefix
+			cfg.Terraform.StatePrefix = "terraform/" + cfg.Environment + "/state"
+		}
+	}
+	cfg.Terraform.StatePrefix = strings.Trim(cfg.Terraform.StatePrefix, "/")
+	if cfg.Terraform.RequiredVersion == "" {
+		cfg.Terraform.RequiredVersion = defaultTerraformRequiredVersion
+	}
+	if cfg.Terraform.GoogleProviderVersion == "" {
+		cfg.Terraform.GoogleProviderVersion = defaultGoogleProviderVersion
+	}
+
+	disabled := cfg.Steps.Disabled
+	cfg.Steps.Disabled = nil
+	if err := cfg.disableSteps(disabled); err != nil {
+		return nil, fmt.Errorf("invalid steps.disabled in %s: %w", configPath, err)
+	}
+
+	if err := validateTimeoutConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid timeout configuration in %s: %w", configPath, err)
+	}
+
+	// Derive SA emails
+	cfg.TFServiceAccountEmail = fmt.Sprintf("%s@%s.iam.gserviceaccount.com", cfg.TFServiceAccountName, cfg.seedProject())
+	if cfg.OpsServiceAccount.Enabled {
+		cfg.OpsServiceAccount.Email = fmt.Sprintf("%s@%s.iam.gserviceaccount.com", cfg.OpsServiceAccount.Name, cfg.ProjectID)
+	}
+
+	// Generic providers default to binding the TF SA, so validate them once its email is known
+	if err := validateOIDCProviders(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid wif.providers in %s: %w", configPath, err)
+	}
 
 	logInfo("Configuration loaded successfully.")
 	return &cfg, nil
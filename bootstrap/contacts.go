@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// essentialContactCategories are the notification categories of the Essential Contacts API
+var essentialContactCategories = []string{"all", "billing", "legal", "product_updates", "security", "suspension", "technical", "technical_incidents"}
+
+// essentialContactLanguage is the language of the notifications sent to the contacts
+const essentialContactLanguage = "en"
+
+// essentialContact is a contact as 'gcloud essential-contacts list' returns it
+type essentialContact struct {
+	Name       string   `json:"name"` // projects/N/contacts/ID
+	Email      string   `json:"email"`
+	Categories []string `json:"notificationCategorySubscriptions"`
+}
+
+// validateEssentialContacts checks the categories and emails of essential_contacts
+func validateEssentialContacts(cfg *Config) error {
+	for category, emails := range cfg.EssentialContacts {
+		if !slices.Contains(essentialContactCategories, category) {
+			return fmt.Errorf("essential_contacts category '%s' is invalid (valid: %s)", category, strings.Join(essentialContactCategories, ", "))
+		}
+		for _, email := range emails {
+			if !strings.Contains(email, "@") {
+				return fmt.Errorf("essential_contacts.%s entry '%s' is not an email address", category, email)
+			}
+		}
+	}
+	return nil
+}
+
+// contactCategories returns the API categories each configured email subscribes to
+func contactCategories(contacts map[string][]string) map[string][]string {
+	byEmail := map[string][]string{}
+	for _, category := range sortedKeys(contacts) {
+		for _, email := range contacts[category] {
+			email = strings.ToLower(email)
+			if !slices.Contains(byEmail[email], strings.ToUpper(category)) {
+				byEmail[email] = append(byEmail[email], strings.ToUpper(category))
+			}
+		}
+	}
+	return byEmail
+}
+
+// setupEssentialContacts registers the essential_contacts emails on the project so security,
+// billing and technical notifications reach the right people from day one. Existing contacts
+// are subscribed to missing categories; categories are never removed.
+func setupEssentialContacts(ctx context.Context, cfg *Config) error {
+	if len(cfg.EssentialContacts) == 0 {
+		logInfo("Skipping Essential Contacts as per config.")
+		return nil
+	}
+	if err := ensureServicesEnabled(ctx, cfg.ProjectID, []string{"essentialcontacts.googleapis.com"}); err != nil {
+		return fmt.Errorf("failed to enable the Essential Contacts API: %w", err)
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "essential-contacts", "list", "--project", cfg.ProjectID, "--format=json")
+	if err != nil {
+		return fmt.Errorf("failed to list the essential contacts of '%s': %w", cfg.ProjectID, err)
+	}
+	var existing []essentialContact
+	if err := json.Unmarshal([]byte(output), &existing); err != nil {
+		return fmt.Errorf("failed to parse the essential contacts of '%s': %w", cfg.ProjectID, err)
+	}
+
+	wanted := contactCategories(cfg.EssentialContacts)
+	for _, email := range sortedKeys(wanted) {
+		categories := wanted[email]
+		i := slices.IndexFunc(existing, func(c essentialContact) bool { return strings.EqualFold(c.Email, email) })
+		if i < 0 {
+			logInfo("Registering essential contact '%s' for %s...", email, strings.Join(categories, ", "))
+			err := runCommand(ctx, "gcloud", "essential-contacts", "create",
+				"--email", email,
+				"--notification-categories", strings.Join(categories, ","),
+				"--language", essentialContactLanguage,
+				"--project", cfg.ProjectID)
+			if err != nil {
+				return fmt.Errorf("failed to register essential contact '%s': %w", email, err)
+			}
+			metrics.recordResource("essential_contact", email, resourceCreated)
+			continue
+		}
+		c := existing[i]
+		merged := slices.Clone(c.Categories)
+		for _, category := range categories {
+			if !slices.Contains(merged, category) {
+				merged = append(merged, category)
+			}
+		}
+		metrics.recordResource("essential_contact", email, resourceExisted)
+		if len(merged) == len(c.Categories) {
+			logInfo("Essential contact '%s' is already registered.", email)
+			continue
+		}
+		logInfo("Subscribing essential contact '%s' to %s...", email, strings.Join(merged, ", "))
+		if err := runCommand(ctx, "gcloud", "essential-contacts", "update", path.Base(c.Name), "--notification-categories", strings.Join(merged, ","), "--project", cfg.ProjectID); err != nil {
+			return fmt.Errorf("failed to update essential contact '%s': %w", email, err)
+		}
+	}
+	logInfo("Essential contacts registered.")
+	return nil
+}
+
+func planEssentialContacts(cfg *Config) []planAction {
+	if len(cfg.EssentialContacts) == 0 {
+		return nil
+	}
+	actions := []planAction{{
+		Description: "Enable the Essential Contacts API",
+		Command:     []string{"gcloud", "services", "enable", "essentialcontacts.googleapis.com", "--project", cfg.ProjectID},
+	}}
+	wanted := contactCategories(cfg.EssentialContacts)
+	for _, email := range sortedKeys(wanted) {
+		categories := strings.Join(wanted[email], ",")
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Register essential contact '%s' for %s, or add missing categories if registered", email, categories),
+			Command:     []string{"gcloud", "essential-contacts", "create", "--email", email, "--notification-categories", categories, "--language", essentialContactLanguage, "--project", cfg.ProjectID},
+		})
+	}
+	return actions
+}
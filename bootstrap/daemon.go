@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonChildGracePeriod is how long a reconcile run may take to exit after being interrupted
+const daemonChildGracePeriod = 30 * time.Second
+
+// daemonConfigStatus is the outcome of the most recent reconcile runs of one config
+type daemonConfigStatus struct {
+	ProjectID        string         `json:"project_id"`
+	LastStatus       string         `json:"last_status,omitempty"` // Empty until the first run finishes
+	LastRun          time.Time      `json:"last_run"`
+	LastDuration     float64        `json:"last_duration_seconds"`
+	LastCreated      int            `json:"last_resources_created"` // Resources the last run had to (re)create, i.e. corrected drift
+	LastError        string         `json:"last_error,omitempty"`
+	Runs             map[string]int `json:"runs"` // Run count by status
+	consecutiveFails int
+	createdTotal     int
+	stepErrors       map[string]int // Count by "step/error class"
+	lastReport       *runReport
+}
+
+// daemonState is the reconcile state shared with the HTTP handlers
+type daemonState struct {
+	mu      sync.Mutex
+	started time.Time
+	configs map[string]*daemonConfigStatus // Keyed by config path
+}
+
+// runDaemonCommand implements 'daemon': it reconciles every given config on an interval,
+// each run in a child process of this binary, and serves /healthz and /metrics
+func runDaemonCommand(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Hour, "Time between the starts of reconcile cycles")
+	listen := fs.String("listen", ":8080", "Address serving /healthz and /metrics; empty disables the HTTP server")
+	timeout := fs.Duration("timeout", 0, "Abort a single reconcile run if it runs longer than this; 0 disables the limit")
+	historyDir := fs.String("history-dir", defaultHistoryDir, "Directory where a report of every run is stored; empty disables it")
+	credentialsFile := fs.String("credentials-file", "", "Authenticate gcloud with this credential JSON file instead of the active gcloud account")
+	productionAck := fs.Bool("production-ack", false, "Allow reconciling production configs without a typed confirmation")
+	fakeGCPFlag := fs.Bool("fake-gcp", false, "Reconcile against the in-process fake of GCP (for demos and tests)")
+	fakeGCPState := fs.String("fake-gcp-state", "", "With -fake-gcp, keep the fake's state in this JSON file across runs")
+	addVariableFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap daemon [-interval 1h] [-listen :8080] [CONFIG ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	// Reconcile runs are unattended, so a variable without a value is an error instead of a prompt
+	variablePrompts = false
+	if *interval <= 0 {
+		logError("-interval must be positive, got %s", *interval)
+	}
+
+	configPaths := fs.Args()
+	if len(configPaths) == 0 {
+		configPaths = []string{defaultConfigFilename}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	state := &daemonState{started: time.Now(), configs: map[string]*daemonConfigStatus{}}
+	for i, path := range configPaths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			logError("Invalid config path '%s': %v", path, err)
+		}
+		configPaths[i] = abs
+		// Fail fast on configs that would never reconcile
+		cfg, err := loadConfig(ctx, abs, bootstrapModeProject, nil)
+		if err != nil {
+			logError("Failed to load configuration: %v", err)
+		}
+		if cfg.isProduction() && !*productionAck {
+			logError("'%s' is a production config; pass -production-ack to reconcile it unattended.", path)
+		}
+		// Every reconcile would mint another key until the per-SA key quota is exhausted
+		if cfg.writesPrivateKey() && !cfg.isStepDisabled("sa_key") {
+			logError("'%s' sets generate_tf_sa_key, which would create a new key on every reconcile; use sa_key_mode: upload or Workload Identity Federation, or add sa_key to steps.disabled.", path)
+		}
+		state.configs[abs] = &daemonConfigStatus{ProjectID: cfg.ProjectID, Runs: map[string]int{}, stepErrors: map[string]int{}}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		logError("Failed to locate the gcp-bootstrap executable: %v", err)
+	}
+	childArgs := []string{"-yes", "-no-color", "-history-dir", *historyDir}
+	childArgs = append(childArgs, variableArgs()...)
+	if *timeout > 0 {
+		childArgs = append(childArgs, "-timeout", timeout.String())
+	}
+	if *credentialsFile != "" {
+		childArgs = append(childArgs, "-credentials-file", *credentialsFile)
+	}
+	if *productionAck {
+		childArgs = append(childArgs, "-production-ack")
+	}
+	if *fakeGCPFlag {
+		childArgs = append(childArgs, "-fake-gcp")
+		if *fakeGCPState != "" {
+			childArgs = append(childArgs, "-fake-gcp-state", *fakeGCPState)
+		}
+	}
+
+	if *listen != "" {
+		server := &http.Server{Addr: *listen, Handler: state.handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logError("HTTP server failed: %v", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+		logInfo("Serving /healthz and /metrics on '%s'.", *listen)
+	}
+
+	logNotice("Reconciling %d config(s) every %s.", len(configPaths), *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		for _, path := range configPaths {
+			if ctx.Err() != nil {
+				break
+			}
+			state.reconcile(ctx, executable, childArgs, path)
+		}
+		select {
+		case <-ctx.Done():
+			logNotice("Daemon stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile runs the bootstrap for one config in a child process and records the outcome
+// from its JSON report
+func (s *daemonState) reconcile(ctx context.Context, executable string, childArgs []string, configPath string) {
+	logInfo("Reconciling '%s'...", configPath)
+	reportFile, err := os.CreateTemp("", "gcp-bootstrap-report-*.json")
+	if err != nil {
+		s.record(configPath, nil, fmt.Errorf("failed to create report file: %w", err), 0)
+		return
+	}
+	reportFile.Close()
+	defer os.Remove(reportFile.Name())
+
+	args := append([]string{"-config", configPath, "-report-json", reportFile.Name()}, childArgs...)
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	// Let the run stop cleanly and write its report instead of killing it outright
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = daemonChildGracePeriod
+	start := time.Now()
+	runErr := cmd.Run()
+	duration := time.Since(start)
+
+	var report runReport
+	data, err := os.ReadFile(reportFile.Name())
+	if err == nil && len(data) > 0 {
+		err = json.Unmarshal(data, &report)
+	}
+	switch {
+	case err != nil || len(data) == 0:
+		// The run exited before writing a report, e.g. on an invalid config
+		if runErr == nil {
+			runErr = errors.New("run finished without writing a report")
+		}
+		s.record(configPath, nil, runErr, duration)
+	default:
+		s.record(configPath, &report, runErr, duration)
+	}
+}
+
+// record stores the outcome of a reconcile run. Runs that exited without a report,
+// e.g. on an invalid config, are recorded as failed.
+func (s *daemonState) record(configPath string, report *runReport, runErr error, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.configs[configPath]
+	if report == nil {
+		now := time.Now()
+		report = &runReport{Status: runStatusFailed, StartedAt: now.Add(-duration), FinishedAt: now, DurationSeconds: duration.Seconds(), ProjectID: st.ProjectID}
+	}
+	st.lastReport = report
+	st.LastRun = report.FinishedAt
+	st.LastDuration = duration.Seconds()
+	st.LastStatus = report.Status
+	st.LastCreated = 0
+	for _, r := range report.Resources {
+		if r.Status == resourceCreated {
+			st.LastCreated++
+		}
+	}
+	st.createdTotal += st.LastCreated
+	for _, step := range report.Steps {
+		if step.Error != "" {
+			st.stepErrors[step.ID+"/"+errorClass(step.Error)]++
+		}
+	}
+	st.LastError = ""
+	if runErr != nil {
+		st.LastError = runErr.Error()
+	}
+	st.Runs[st.LastStatus]++
+	if st.LastStatus == runStatusSucceeded {
+		st.consecutiveFails = 0
+		if st.LastCreated > 0 {
+			logNotice("Reconciled '%s': corrected drift by creating %d resource(s).", configPath, st.LastCreated)
+		} else {
+			logNotice("Reconciled '%s': no drift.", configPath)
+		}
+		return
+	}
+	st.consecutiveFails++
+	logWarning("Reconcile of '%s' %s (%d consecutive failure(s)): %v", configPath, st.LastStatus, st.consecutiveFails, runErr)
+}
+
+// handler serves the daemon's HTTP endpoints
+func (s *daemonState) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	return mux
+}
+
+// serveHealth reports 200 while the last run of every config succeeded (or none has
+// finished yet) and 503 otherwise, with the per-config status as JSON
+func (s *daemonState) serveHealth(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	healthy := true
+	for _, st := range s.configs {
+		if st.LastStatus != "" && st.LastStatus != runStatusSucceeded {
+			healthy = false
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"healthy": healthy, "configs": s.configs})
+}
+
+// serveMetrics exposes the reconcile state and the outcome of each config's last run in
+// the Prometheus text format
+func (s *daemonState) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	paths := sortedKeys(s.configs)
+
+	writeMetricHeader(w, "gcp_bootstrap_daemon_start_time_seconds", "gauge", "Unix time the daemon started.")
+	fmt.Fprintf(w, "gcp_bootstrap_daemon_start_time_seconds %d\n", s.started.Unix())
+
+	writeMetricHeader(w, "gcp_bootstrap_daemon_runs_total", "counter", "Reconcile runs by config and status.")
+	for _, path := range paths {
+		st := s.configs[path]
+		for _, status := range sortedKeys(st.Runs) {
+			fmt.Fprintf(w, "gcp_bootstrap_daemon_runs_total{%s,status=%s} %d\n", configLabels(path, st), promQuote(status), st.Runs[status])
+		}
+	}
+
+	writeMetricHeader(w, "gcp_bootstrap_daemon_step_errors_total", "counter", "Failed or warned steps across reconcile runs, by step and error class.")
+	for _, path := range paths {
+		st := s.configs[path]
+		for _, key := range sortedKeys(st.stepErrors) {
+			step, class, _ := strings.Cut(key, "/")
+			fmt.Fprintf(w, "gcp_bootstrap_daemon_step_errors_total{%s,step=%s,error_class=%s} %d\n", configLabels(path, st), promQuote(step), promQuote(class), st.stepErrors[key])
+		}
+	}
+
+	writeMetricHeader(w, "gcp_bootstrap_daemon_resources_created_total", "counter", "Resources recreated across reconcile runs, i.e. corrected drift.")
+	for _, path := range paths {
+		st := s.configs[path]
+		fmt.Fprintf(w, "gcp_bootstrap_daemon_resources_created_total{%s} %d\n", configLabels(path, st), st.createdTotal)
+	}
+
+	var reports []labeledReport
+	for _, path := range paths {
+		if st := s.configs[path]; st.lastReport != nil {
+			reports = append(reports, labeledReport{Labels: configLabels(path, st), Report: st.lastReport})
+		}
+	}
+	writeRunMetrics(w, reports)
+}
+
+// configLabels returns the Prometheus labels identifying a config
+func configLabels(path string, st *daemonConfigStatus) string {
+	return fmt.Sprintf("config=%s,project_id=%s", promQuote(path), promQuote(st.ProjectID))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// defaultSAEditorRole is the basic role Google grants the default service accounts on creation
+const defaultSAEditorRole = "roles/editor"
+
+// defaultServiceAccounts returns the default service accounts Google creates for the APIs
+// the bootstrap enables: the Compute Engine one and, with App Engine, the App Engine one
+func defaultServiceAccounts(cfg *Config, projectNumber string) []string {
+	apis := requestedAPIs(cfg)
+	var emails []string
+	if slices.Contains(apis, "compute.googleapis.com") {
+		emails = append(emails, projectNumber+"-compute@developer.gserviceaccount.com")
+	}
+	if slices.Contains(apis, "appengine.googleapis.com") {
+		emails = append(emails, cfg.ProjectID+"@appspot.gserviceaccount.com")
+	}
+	return emails
+}
+
+// hardenDefaultServiceAccounts removes roles/editor from the default service accounts
+// and optionally disables them, as the CIS benchmark recommends
+func hardenDefaultServiceAccounts(ctx context.Context, cfg *Config) error {
+	dsa := cfg.DefaultServiceAccounts
+	if !dsa.RemoveEditor && !dsa.Disable {
+		logInfo("Skipping default service account hardening as per config.")
+		return nil
+	}
+	number, err := projectNumber(ctx, cfg.ProjectID)
+	if err != nil {
+		return err
+	}
+	emails := defaultServiceAccounts(cfg, number)
+	if len(emails) == 0 {
+		logInfo("No API creating a default service account is enabled; nothing to harden.")
+		return nil
+	}
+
+	if dsa.RemoveEditor {
+		output, err := runCommandGetOutput(ctx, "gcloud", "projects", "get-iam-policy", cfg.ProjectID, "--format=json")
+		if err != nil {
+			return fmt.Errorf("failed to read the IAM policy of '%s': %w", cfg.ProjectID, err)
+		}
+		var policy iamPolicy
+		if err := json.Unmarshal([]byte(output), &policy); err != nil {
+			return fmt.Errorf("failed to parse the IAM policy of '%s': %w", cfg.ProjectID, err)
+		}
+		for _, email := range emails {
+			member := "serviceAccount:" + email
+			granted := false
+			for _, b := range policy.Bindings {
+				if b.Role == defaultSAEditorRole && b.Condition == nil && slices.Contains(b.Members, member) {
+					granted = true
+				}
+			}
+			if !granted {
+				logInfo("Default service account '%s' does not hold %s.", email, defaultSAEditorRole)
+				continue
+			}
+			if err := snapshotProjectIAM(ctx, cfg.ProjectID); err != nil {
+				return err
+			}
+			logInfo("Removing %s from default service account '%s'...", defaultSAEditorRole, email)
+			if err := runCommand(ctx, "gcloud", "projects", "remove-iam-policy-binding", cfg.ProjectID, "--member", member, "--role", defaultSAEditorRole, "--condition=None"); err != nil {
+				return fmt.Errorf("failed to remove %s from '%s': %w", defaultSAEditorRole, email, err)
+			}
+		}
+	}
+
+	if dsa.Disable {
+		for _, email := range emails {
+			disabled, err := runCommandGetOutput(ctx, "gcloud", "iam", "service-accounts", "describe", email, "--format=value(disabled)", "--project", cfg.ProjectID)
+			if err != nil {
+				return fmt.Errorf("failed to describe default service account '%s': %w", email, err)
+			}
+			if strings.EqualFold(strings.TrimSpace(disabled), "true") {
+				logInfo("Default service account '%s' is already disabled.", email)
+				continue
+			}
+			logInfo("Disabling default service account '%s'...", email)
+			if err := runCommand(ctx, "gcloud", "iam", "service-accounts", "disable", email, "--project", cfg.ProjectID); err != nil {
+				return fmt.Errorf("failed to disable '%s': %w", email, err)
+			}
+		}
+	}
+	logInfo("Default service accounts hardened.")
+	return nil
+}
+
+func planDefaultServiceAccounts(cfg *Config) []planAction {
+	dsa := cfg.DefaultServiceAccounts
+	if !dsa.RemoveEditor && !dsa.Disable {
+		return nil
+	}
+	var actions []planAction
+	for _, email := range defaultServiceAccounts(cfg, "<project-number>") {
+		if dsa.RemoveEditor {
+			actions = append(actions, planAction{
+				Description: fmt.Sprintf("Remove %s from default service account '%s' if granted", defaultSAEditorRole, email),
+				Command:     []string{"gcloud", "projects", "remove-iam-policy-binding", cfg.ProjectID, "--member", "serviceAccount:" + email, "--role", defaultSAEditorRole, "--condition=None"},
+			})
+		}
+		if dsa.Disable {
+			actions = append(actions, planAction{
+				Description: fmt.Sprintf("Disable default service account '%s' unless disabled", email),
+				Command:     []string{"gcloud", "iam", "service-accounts", "disable", email, "--project", cfg.ProjectID},
+			})
+		}
+	}
+	return actions
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// destroyOrder lists the resource kinds destroy can delete, in deletion order:
+// dependents first, the project last
+var destroyOrder = []string{
+	"file",
+	"service_account_key",
+	keychainKeyKind,
+	"bucket",
+	"workload_identity_provider",
+	"workload_identity_pool",
+	"service_account",
+	"lien",
+	"project",
+}
+
+// destroyAction is a single resource in the deletion plan
+type destroyAction struct {
+	Kind    string
+	Name    string
+	Adopted bool // found already existing by every recorded run
+}
+
+// runDestroyCommand implements 'destroy': it deletes the resources the run history records
+// for the configured project. Only resources the tool created are deleted unless
+// --include-adopted is given; the exact deletion plan is always printed first.
+func runDestroyCommand(args []string) {
+	fs := flag.NewFlagSet("destroy", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	historyDir := fs.String("history-dir", defaultHistoryDir, "Directory containing the stored run reports used as state")
+	includeAdopted := fs.Bool("include-adopted", false, "Also delete resources that already existed before the tool first ran")
+	assumeYes := fs.Bool("yes", false, "Skip the typed confirmation")
+	credentialsFile := fs.String("credentials-file", "", "Authenticate gcloud with this credential JSON file instead of the active gcloud account")
+	fs.BoolVar(&bucketVersions.purge, "purge-versions", false, "Delete buckets holding noncurrent object versions or soft-deleted objects with their whole history, and without bucket soft delete")
+	fs.BoolVar(&bucketVersions.keep, "keep-versions", false, "Only delete the live objects of buckets holding noncurrent versions or soft-deleted objects, keeping the bucket and its history")
+	restoreBucket := fs.String("restore-bucket", "", "Restore this soft-deleted bucket (e.g. the state bucket of a mistaken destroy) and exit")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap destroy [-config FILE] [-env NAME] [-history-dir DIR] [-include-adopted] [-purge-versions|-keep-versions] [-yes]")
+		fmt.Fprintln(fs.Output(), "       gcp-bootstrap destroy [-config FILE] -restore-bucket NAME")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if bucketVersions.purge && bucketVersions.keep {
+		logError("-purge-versions and -keep-versions are mutually exclusive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+	if err != nil {
+		logError("Failed to load configuration: %v", err)
+	}
+	if err := resolveExistingProjectID(ctx, cfg); err != nil {
+		logError("%v", err)
+	}
+	if *restoreBucket != "" {
+		checkGcloud(ctx, *credentialsFile)
+		if err := restoreSoftDeletedBucket(ctx, cfg, *restoreBucket); err != nil {
+			logError("%v", err)
+		}
+		return
+	}
+	actions, skipped, err := destroyPlan(*historyDir, cfg.ProjectID)
+	if err != nil {
+		logError("%v", err)
+	}
+	if len(actions) == 0 {
+		logNotice("No resources recorded for project '%s' in '%s'; nothing to destroy.", cfg.ProjectID, *historyDir)
+		return
+	}
+
+	var selected []destroyAction
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	fmt.Fprintln(stdout, colorize(colorBold+colorRed, " Deletion Plan"))
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	for _, a := range actions {
+		switch {
+		case !a.Adopted:
+			fmt.Fprintf(stdout, " - delete %-27s %s\n", a.Kind, a.Name)
+			selected = append(selected, a)
+		case *includeAdopted:
+			fmt.Fprintf(stdout, " - delete %-27s %s %s\n", a.Kind, a.Name, colorize(colorYellow, "(adopted)"))
+			selected = append(selected, a)
+		default:
+			fmt.Fprintf(stdout, "   keep   %-27s %s (adopted; pass --include-adopted to delete)\n", a.Kind, a.Name)
+		}
+	}
+	for _, s := range skipped {
+		fmt.Fprintf(stdout, "   keep   %-27s %s (not handled by destroy)\n", s.Kind, s.Name)
+	}
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	if len(selected) == 0 {
+		logNotice("Nothing to delete.")
+		return
+	}
+
+	if *assumeYes {
+		logInfo("Confirmation skipped via --yes.")
+	} else {
+		fmt.Fprintf(stdout, "Type the project ID '%s' to delete %d resource(s): ", cfg.ProjectID, len(selected))
+		input, err := readConfirmation(ctx)
+		if err != nil {
+			exitCancelled(ctx)
+		}
+		if strings.TrimSpace(input) != cfg.ProjectID {
+			logInfo("Aborted by user.")
+			os.Exit(0)
+		}
+	}
+
+	checkGcloud(ctx, *credentialsFile)
+	failed := 0
+	for _, a := range selected {
+		err := destroyResource(ctx, cfg, a)
+		if errors.Is(err, errBucketKept) {
+			continue
+		}
+		if err != nil {
+			logWarning("Failed to delete %s '%s': %v", a.Kind, a.Name, err)
+			failed++
+			continue
+		}
+		logInfo("Deleted %s '%s'.", a.Kind, a.Name)
+	}
+	if failed > 0 {
+		logError("Destroy finished with %d failure(s).", failed)
+	}
+	logNotice("Destroy completed: %d resource(s) deleted.", len(selected))
+}
+
+// destroyPlan merges the resources of all recorded runs for projectID. A resource counts
+// as created if any run created it, and as adopted if every run found it existing.
+// Kinds destroy cannot delete are returned separately.
+func destroyPlan(historyDir, projectID string) (actions, skipped []destroyAction, err error) {
+	paths, err := filepath.Glob(filepath.Join(historyDir, "*.json"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list history in '%s': %w", historyDir, err)
+	}
+	adopted := map[string]bool{}
+	for _, p := range paths {
+		r, err := readReport(p)
+		if err != nil {
+			logWarning("%v", err)
+			continue
+		}
+		if r.ProjectID != projectID {
+			continue
+		}
+		for _, res := range r.Resources {
+			// Runs before keychain_key existed recorded keychain keys as service_account_key
+			if res.Kind == "service_account_key" && strings.HasPrefix(res.Name, keychainService+"/") {
+				res.Kind = keychainKeyKind
+			}
+			key := res.Kind + "\x00" + res.Name
+			wasAdopted, seen := adopted[key]
+			adopted[key] = res.Status == resourceExisted && (!seen || wasAdopted)
+		}
+	}
+
+	rank := map[string]int{}
+	for i, kind := range destroyOrder {
+		rank[kind] = i
+	}
+	for key, a := range adopted {
+		kind, name, _ := strings.Cut(key, "\x00")
+		action := destroyAction{Kind: kind, Name: name, Adopted: a}
+		if _, ok := rank[kind]; ok {
+			actions = append(actions, action)
+		} else {
+			skipped = append(skipped, action)
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].Kind != actions[j].Kind {
+			return rank[actions[i].Kind] < rank[actions[j].Kind]
+		}
+		return actions[i].Name < actions[j].Name
+	})
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Kind+skipped[i].Name < skipped[j].Kind+skipped[j].Name
+	})
+	return actions, skipped, nil
+}
+
+// destroyResource deletes a single resource of the deletion plan
+func destroyResource(ctx context.Context, cfg *Config, a destroyAction) error {
+	switch a.Kind {
+	case "file", "service_account_key":
+		if err := os.Remove(a.Name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	case keychainKeyKind:
+		return keychainDelete(ctx, strings.TrimPrefix(a.Name, keychainService+"/"))
+	case "bucket":
+		return destroyBucket(ctx, cfg, a.Name)
+	case "workload_identity_provider":
+		return runCommand(ctx, "gcloud", "iam", "workload-identity-pools", "providers", "delete", a.Name,
+			"--workload-identity-pool", cfg.WIF.PoolID, "--location", "global", "--project", cfg.seedProject())
+	case "workload_identity_pool":
+		return runCommand(ctx, "gcloud", "iam", "workload-identity-pools", "delete", a.Name, "--location", "global", "--project", cfg.seedProject())
+	case "service_account":
+		return runCommand(ctx, "gcloud", "iam", "service-accounts", "delete", a.Name, "--project", cfg.seedProject())
+	case "lien":
+		return runCommand(ctx, "gcloud", "alpha", "resource-manager", "liens", "delete", a.Name)
+	case "project":
+		return runCommand(ctx, "gcloud", "projects", "delete", a.Name)
+	}
+	return fmt.Errorf("unsupported resource kind '%s'", a.Kind)
+}
+
+// bucketVersions holds the -purge-versions and -keep-versions choice for buckets with history
+var bucketVersions struct {
+	purge, keep bool
+}
+
+// errBucketKept reports that -keep-versions deleted a bucket's live objects but kept the bucket
+var errBucketKept = errors.New("bucket kept with its object history")
+
+// destroyBucket deletes a bucket and its objects. A bucket still holding noncurrent versions
+// or soft-deleted objects (e.g. earlier Terraform states) is only deleted with -purge-versions;
+// -keep-versions deletes just its live objects, so the history stays recoverable.
+func destroyBucket(ctx context.Context, cfg *Config, url string) error {
+	project := cfg.seedProject()
+	noncurrent, softDeleted, err := bucketHistory(ctx, url, project)
+	if err != nil {
+		return err
+	}
+	if noncurrent > 0 || softDeleted > 0 {
+		logWarning("Bucket '%s' holds %d noncurrent object version(s) and %d soft-deleted object(s).", url, noncurrent, softDeleted)
+		switch {
+		case bucketVersions.keep:
+			logInfo("Deleting only the live objects of '%s' (--keep-versions)...", url)
+			if err := runCommand(ctx, "gcloud", "storage", "rm", url+"/**", "--project", project); err != nil && !isNoMatchError(err) {
+				return err
+			}
+			logNotice("Kept bucket '%s' with its object history; restore an object with 'gcloud storage cp %s/OBJECT#GENERATION %s/OBJECT' (list generations with 'gcloud storage ls --all-versions').", url, url, url)
+			return errBucketKept
+		case !bucketVersions.purge:
+			return fmt.Errorf("bucket '%s' still holds object history; pass --purge-versions to delete it for good, or --keep-versions to keep the bucket and its history", url)
+		}
+		// Without soft delete neither the purged objects nor the bucket are retained
+		logInfo("Disabling soft delete on '%s' before purging it (--purge-versions)...", url)
+		if err := runCommand(ctx, "gcloud", "storage", "buckets", "update", url, "--clear-soft-delete", "--project", project); err != nil {
+			return err
+		}
+		return runCommand(ctx, "gcloud", "storage", "rm", "--recursive", "--all-versions", url, "--project", project)
+	}
+
+	retention, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", url, "--format=value(soft_delete_policy.retentionDurationSeconds)", "--project", project)
+	if err != nil {
+		return fmt.Errorf("failed to read the soft delete policy of '%s': %w", url, err)
+	}
+	if err := runCommand(ctx, "gcloud", "storage", "rm", "--recursive", url, "--project", project); err != nil {
+		return err
+	}
+	if seconds, _ := strconv.ParseInt(strings.TrimSpace(retention), 10, 64); seconds > 0 {
+		period := (time.Duration(seconds) * time.Second).String()
+		if seconds%86400 == 0 {
+			period = fmt.Sprintf("%d days", seconds/86400)
+		}
+		logNotice("Bucket '%s' stays soft-deleted for %s; undo with 'gcp-bootstrap destroy -restore-bucket %s'.", url, period, strings.TrimPrefix(url, "gs://"))
+	}
+	return nil
+}
+
+// bucketHistory counts the noncurrent object versions and soft-deleted objects of a bucket
+func bucketHistory(ctx context.Context, url, project string) (noncurrent, softDeleted int, err error) {
+	count := func(flags ...string) (int, error) {
+		args := append([]string{"storage", "ls", url + "/**", "--project", project}, flags...)
+		out, err := runCommandGetOutput(ctx, "gcloud", args...)
+		if err != nil {
+			if isNoMatchError(err) {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("failed to list objects of '%s': %w", url, err)
+		}
+		return len(strings.Fields(out)), nil
+	}
+	live, err := count()
+	if err != nil {
+		return 0, 0, err
+	}
+	all, err := count("--all-versions")
+	if err != nil {
+		return 0, 0, err
+	}
+	if softDeleted, err = count("--soft-deleted"); err != nil {
+		return 0, 0, err
+	}
+	return all - live, softDeleted, nil
+}
+
+// isNoMatchError reports whether a gcloud storage error means the URL matched no objects
+func isNoMatchError(err error) bool {
+	return strings.Contains(err.Error(), "matched no objects")
+}
+
+// restoreSoftDeletedBucket restores the most recently soft-deleted generation of a bucket,
+// with the objects it held when it was deleted
+func restoreSoftDeletedBucket(ctx context.Context, cfg *Config, name string) error {
+	name = strings.TrimPrefix(name, "gs://")
+	out, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "list", "--soft-deleted",
+		"--filter=name="+name, "--format=value(generation)", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to list soft-deleted buckets: %w", err)
+	}
+	var latest int64
+	for _, g := range strings.Fields(out) {
+		if n, err := strconv.ParseInt(g, 10, 64); err == nil && n > latest {
+			latest = n
+		}
+	}
+	if latest == 0 {
+		return fmt.Errorf("no soft-deleted bucket '%s' in project '%s'; buckets without soft delete, or deleted longer ago than its retention, cannot be restored", name, cfg.seedProject())
+	}
+	logInfo("Restoring bucket 'gs://%s' (generation %d)...", name, latest)
+	if err := runCommand(ctx, "gcloud", "storage", "restore", fmt.Sprintf("gs://%s#%d", name, latest)); err != nil {
+		return fmt.Errorf("failed to restore bucket '%s': %w", name, err)
+	}
+	logNotice("Bucket 'gs://%s' restored.", name)
+	return nil
+}
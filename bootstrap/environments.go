@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// environmentsKey is the top-level config key holding the per-environment overrides
+const environmentsKey = "environments"
+
+// allEnvironments is the --env value that bootstraps every environment of the config
+const allEnvironments = "all"
+
+// configEnvironment is the environment selected with --env; loadConfig merges its
+// overrides over the shared settings of configs that define environments
+var configEnvironment string
+
+// environmentNames returns the environments defined in the config document, in file order
+func environmentNames(doc []byte) ([]string, error) {
+	var root struct {
+		Environments yaml.Node `yaml:"environments"`
+	}
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	node := root.Environments
+	if node.Kind == 0 {
+		return nil, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s must be a mapping of environment name to overrides", environmentsKey)
+	}
+	var names []string
+	for i := 0; i < len(node.Content); i += 2 {
+		name := node.Content[i].Value
+		if name == "" || name == allEnvironments {
+			return nil, fmt.Errorf("'%s' is not a valid environment name", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// selectEnvironment returns the config document with the overrides of environment env
+// merged over the shared settings, the same way a config is merged over its catalog
+// template. Configs without environments are returned unchanged and must not get an env.
+func selectEnvironment(doc []byte, env string) ([]byte, error) {
+	names, err := environmentNames(doc)
+	if err != nil {
+		return nil, err
+	}
+	if names == nil {
+		if env != "" {
+			return nil, fmt.Errorf("--env '%s' was given but the config defines no %s", env, environmentsKey)
+		}
+		return doc, nil
+	}
+	switch {
+	case env == "":
+		return nil, fmt.Errorf("the config defines environments (%s); choose one with --env", strings.Join(names, ", "))
+	case env == allEnvironments:
+		return nil, fmt.Errorf("--env %s is only supported for bootstrap runs; choose one of %s", allEnvironments, strings.Join(names, ", "))
+	}
+
+	root := map[string]any{}
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	environments, _ := root[environmentsKey].(map[string]any)
+	if err := checkEnvironmentIsolation(root, environments, names); err != nil {
+		return nil, err
+	}
+	value, ok := environments[env]
+	if !ok {
+		return nil, fmt.Errorf("unknown environment '%s'; the config defines %s", env, strings.Join(names, ", "))
+	}
+	delete(root, environmentsKey)
+	if value == nil {
+		return yaml.Marshal(root) // An environment may use the shared settings as they are
+	}
+	overrides, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s must be a mapping of config overrides", environmentsKey, env)
+	}
+	if _, ok := overrides["extends"]; ok {
+		return nil, fmt.Errorf("%s.%s cannot set extends; set it at the top level", environmentsKey, env)
+	}
+	for key := range overrides {
+		if !isConfigKey(key) {
+			return nil, fmt.Errorf("%s.%s: unknown config key '%s'", environmentsKey, env, key)
+		}
+	}
+	logInfo("Using environment '%s'.", env)
+	return yaml.Marshal(mergeConfigMaps(root, overrides))
+}
+
+// checkEnvironmentIsolation refuses environments that would keep their Terraform state at
+// the same bucket and prefix, e.g. because terraform.state_prefix is set at the top level
+// and inherited by all of them, so one environment's apply cannot clobber another's state
+func checkEnvironmentIsolation(root, environments map[string]any, names []string) error {
+	owners := map[string]string{}
+	for _, name := range names {
+		overrides, _ := environments[name].(map[string]any)
+		location := environmentStateLocation(root, overrides, name)
+		if other, ok := owners[location]; ok {
+			return fmt.Errorf("%s '%s' and '%s' would share the Terraform state at gs://%s; give each its own terraform.state_prefix or tf_state_bucket_name",
+				environmentsKey, other, name, location)
+		}
+		owners[location] = name
+	}
+	return nil
+}
+
+// environmentStateLocation returns the state bucket and prefix an environment resolves to,
+// applying the same defaults as loadConfig
+func environmentStateLocation(root, overrides map[string]any, env string) string {
+	setting := func(path ...string) string {
+		for _, m := range []map[string]any{overrides, root} {
+			var value any = m
+			for _, key := range path {
+				child, _ := value.(map[string]any)
+				value = child[key]
+			}
+			if value != nil {
+				return fmt.Sprint(value)
+			}
+		}
+		return ""
+	}
+	bucket := setting("tf_state_bucket_name")
+	if bucket == "" {
+		// The project number differs whenever the project ID does
+		projectID := setting("project_id")
+		bucket = strings.ReplaceAll(setting("tf_state_bucket_name_template"), projectIDPlaceholder, projectID)
+		bucket = strings.ReplaceAll(bucket, projectNumberPlaceholder, "number-of-"+projectID)
+	}
+	prefix := setting("terraform", "state_prefix")
+	if prefix == "" {
+		prefix = "terraform/" + env + "/state"
+	}
+	return bucket + "/" + strings.Trim(prefix, "/")
+}
+
+// readEnvironmentNames returns the environments of the config file after resolving its
+// catalog template, which may define them too
+func readEnvironmentNames(configPath string) ([]string, error) {
+	doc, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", configPath, err)
+	}
+	if doc, err = resolveExtends(configPath, doc); err != nil {
+		return nil, fmt.Errorf("error resolving extends in %s: %w", configPath, err)
+	}
+	names, err := environmentNames(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %w", environmentsKey, configPath, err)
+	}
+	if names == nil {
+		return nil, fmt.Errorf("--env %s was given but %s defines no %s", allEnvironments, configPath, environmentsKey)
+	}
+	return names, nil
+}
+
+// runAllEnvironments bootstraps every environment in file order, each in a child process
+// of this binary with the same arguments plus --env, and stops at the first failure so a
+// broken dev run never reaches prod
+func runAllEnvironments(ctx context.Context, configPath string) {
+	names, err := readEnvironmentNames(configPath)
+	if err != nil {
+		logError("%v", err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		logError("Failed to locate the gcp-bootstrap executable: %v", err)
+	}
+	logNotice("Bootstrapping %d environments in order: %s.", len(names), strings.Join(names, ", "))
+	for i, name := range names {
+		logNotice("Environment '%s' (%d/%d)...", name, i+1, len(names))
+		// The last --env wins, so the original --env all is overridden
+		args := append(append([]string{}, os.Args[1:]...), "-env", name)
+		cmd := exec.CommandContext(ctx, executable, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = stdout
+		cmd.Stderr = os.Stderr
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		cmd.WaitDelay = daemonChildGracePeriod
+		if err := cmd.Run(); err != nil {
+			remaining := names[i+1:]
+			if len(remaining) > 0 {
+				logError("Environment '%s' failed: %v. Not continuing with %s.", name, err, strings.Join(remaining, ", "))
+			}
+			logError("Environment '%s' failed: %v", name, err)
+		}
+	}
+	logNotice("All %d environments completed successfully.", len(names))
+}
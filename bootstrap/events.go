@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event types emitted on the progress event stream
+const (
+	eventRunStart   = "run_start"
+	eventRunFinish  = "run_finish"
+	eventStepStart  = "step_start"
+	eventStepFinish = "step_finish"
+	eventStepError  = "step_error"
+)
+
+// progressEvent is a single NDJSON record on the event stream
+type progressEvent struct {
+	Time            time.Time `json:"time"`
+	Type            string    `json:"type"`
+	ProjectID       string    `json:"project_id,omitempty"`
+	Step            string    `json:"step,omitempty"`
+	StepName        string    `json:"step_name,omitempty"`
+	Status          string    `json:"status,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// eventEmitter writes progress events as newline-delimited JSON for wrapping tools
+type eventEmitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// events is the process-wide event emitter; it is a no-op until setupEvents is called
+var events = &eventEmitter{}
+
+// setupEvents directs the event stream to an inherited file descriptor or a file
+func setupEvents(fd int, path string) error {
+	var f *os.File
+	switch {
+	case fd > 0 && path != "":
+		return fmt.Errorf("--events-fd and --events-file cannot be used together")
+	case fd > 0:
+		f = os.NewFile(uintptr(fd), fmt.Sprintf("events-fd-%d", fd))
+		if f == nil {
+			return fmt.Errorf("invalid --events-fd %d", fd)
+		}
+	case path != "":
+		var err error
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open events file '%s': %w", path, err)
+		}
+	default:
+		return nil
+	}
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	events.enc = json.NewEncoder(f)
+	events.c = f
+	return nil
+}
+
+// emit writes an event to the stream, if one is configured
+func (e *eventEmitter) emit(ev progressEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.enc == nil {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	if err := e.enc.Encode(ev); err != nil {
+		// Don't fail the bootstrap because the consumer went away
+		e.enc = nil
+		logAt(levelNotice, fmt.Sprintf("Disabling progress event stream after write error: %v", err))
+	}
+}
+
+// close closes the event stream
+func (e *eventEmitter) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.c != nil {
+		e.c.Close()
+	}
+	e.enc, e.c = nil, nil
+}
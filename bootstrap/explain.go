@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// stepExplanation is the human-readable rationale of a bootstrap step, for reviewers
+// such as change advisory boards
+type stepExplanation struct {
+	Purpose  string
+	Security string
+}
+
+// stepExplanations holds the rationale of every bootstrap step, keyed by step ID
+var stepExplanations = map[string]stepExplanation{
+	"folders": {
+		Purpose:  "Creates the configured folder hierarchy below the organization and grants the folder-level roles, so projects land in the right place of the resource hierarchy.",
+		Security: "Folder IAM bindings are inherited by every project below the folder; keep them narrow.",
+	},
+	"project": {
+		Purpose:  "Creates the project that holds the Terraform service account and state bucket, unless it already exists.",
+		Security: "The creator becomes project owner; review and remove that grant once Terraform manages the project.",
+	},
+	"billing": {
+		Purpose:  "Links the project to the billing account (or a subaccount of it, created if needed), which most APIs require before they can be enabled. Failed links are retried with backoff while a new account is provisioned.",
+		Security: "Anyone who can create resources in the project can now incur cost on this billing account.",
+	},
+	"apis": {
+		Purpose:  "Enables the configured APIs so Terraform can manage their resources.",
+		Security: "Only APIs in enable_apis are enabled; unused APIs increase the attack surface.",
+	},
+	"budget": {
+		Purpose:  "Enables the Cloud Billing Budget API and creates a budget scoped to the project on its billing account, with alerts at the configured thresholds and optionally to a Pub/Sub topic, so a bootstrap project never produces a surprise bill.",
+		Security: "Budgets only alert; they never cap spending. Alert emails go to the billing account's admins and users, so add a Pub/Sub topic to reach the team owning the project.",
+	},
+	"billing_export": {
+		Purpose:  "Creates the BigQuery dataset the billing account's cost data is exported to and reports whether Cloud Billing already writes to it. The export itself has no API and is enabled once per billing account in the console; the step warns with the link until the export tables appear.",
+		Security: "The export holds the cost data of every project on the billing account, not just this one; restrict access to the dataset accordingly.",
+	},
+	"service_account": {
+		Purpose:  "Creates the service account Terraform runs as, and keeps its description in sync with tf_service_account_metadata.",
+		Security: "This identity receives broad roles in the next steps; restrict who can impersonate it or create keys for it.",
+	},
+	"iam_roles": {
+		Purpose:  "Grants the Terraform service account its project roles and, if configured, the billing role.",
+		Security: "Review tf_service_account_project_roles for least privilege; roles/owner and roles/editor are rarely needed.",
+	},
+	"org_iam_roles": {
+		Purpose:  "In org-bootstrap mode, grants the Terraform service account its organization roles.",
+		Security: "Organization roles apply to every folder and project; they make the service account a high-value target.",
+	},
+	"wif": {
+		Purpose:  "Creates a workload identity pool and OIDC providers so CI/CD systems can impersonate the Terraform service account without keys.",
+		Security: "The attribute condition decides which repositories or pipelines may impersonate the service account; keep it as narrow as possible.",
+	},
+	"ops_service_account": {
+		Purpose:  "Creates a separate service account for monitoring and logging tooling and grants it the configured roles.",
+		Security: "Keep its roles read-only (e.g. roles/monitoring.viewer) so observability tooling cannot change infrastructure.",
+	},
+	"fleet": {
+		Purpose:  "Registers the project with a GKE Hub fleet and grants the Terraform service account its roles on the fleet host project.",
+		Security: "Roles on the host project reach beyond this project; review tf_sa_host_roles.",
+	},
+	"break_glass": {
+		Purpose:  "Grants the break-glass group its role (roles/resourcemanager.projectIamAdmin by default) on the project with an IAM condition that expires after break_glass.duration, as an escape hatch for incidents on a fresh project.",
+		Security: "Until it expires the group has full control of the project; keep its membership small and audited. Re-runs never extend an existing binding.",
+	},
+	"project_lien": {
+		Purpose:  "Places a resource manager lien restricting resourcemanager.projects.delete on the project, so the foundation cannot be deleted by accident.",
+		Security: "Anyone with resourcemanager.projects.updateLiens can remove the lien; it guards against mistakes, not against a determined administrator.",
+	},
+	"cmdb": {
+		Purpose:  "Registers the project as a configuration item in ServiceNow through the Table API: the CI matching cmdb.lookup_field is updated with the mapped fields, or created, replacing the manual CMDB step after every bootstrap.",
+		Security: "The ServiceNow password or token is read from the environment (password_env, token_env), never from the config. A failure only warns, unless strict is set.",
+	},
+	"audit_logs": {
+		Purpose:  "Adds the Admin Read and Data Access log types listed under audit_logs per service (e.g. DATA_READ for storage.googleapis.com) to the audit configs of the project IAM policy, keeping what is already logged and any exempted members.",
+		Security: "Data Access logs record who read or changed data and are billed as Cloud Logging ingestion; high-volume services such as storage can be costly. Logging configured for allServices counts for every service.",
+	},
+	"essential_contacts": {
+		Purpose:  "Enables the Essential Contacts API and registers the emails listed under essential_contacts for their notification categories (security, billing, technical, ...), so Google's notifications about the project reach the right people from day one.",
+		Security: "Existing contacts are only subscribed to missing categories; contacts and categories are never removed, and contacts inherited from the folder or organization still apply.",
+	},
+	"domain_restricted_sharing": {
+		Purpose:  "Sets the iam.allowedPolicyMemberDomains organization policy on the project, so IAM grants are limited to the configured customers.",
+		Security: "Blocks accidental grants to outside accounts; applied after the bootstrap's own grants so it cannot block them.",
+	},
+	"data_classification": {
+		Purpose:  "Enforces the boolean organization policy constraints listed for the project's data_classification; the classification also picks the project's parent folder and the APIs it may enable.",
+		Security: "Enforced on the project only; constraints inherited from the parent folder stay in place whatever is listed here.",
+	},
+	"org_policies": {
+		Purpose:  "Sets the organization policy constraints listed under org_policies on the project: boolean constraints are enforced, list constraints get their allowed or denied values, so the security baseline is part of the bootstrap.",
+		Security: "Applied after the bootstrap's own grants and API enablement so they cannot block it; constraints inherited from the parent stay in place, and later changes through Terraform or the console override these.",
+	},
+	"default_service_accounts": {
+		Purpose:  "Removes roles/editor from the default service accounts Google creates with the Compute Engine and App Engine APIs, and optionally disables them, as the CIS benchmark recommends.",
+		Security: "Workloads that rely on the default accounts (VMs or GKE node pools created without a service account) lose access; give them dedicated service accounts. The iam.automaticIamGrantsForDefaultServiceAccounts constraint avoids the grant in the first place.",
+	},
+	"firebase": {
+		Purpose:  "Enables the Firebase Management API and adds Firebase to the project (like 'firebase projects:addfirebase'), so mobile backends can use it right away instead of attaching Firebase later.",
+		Security: "Adding Firebase creates the firebase-adminsdk service account and enables Firebase services; it cannot be undone without deleting the project. The caller must have accepted the Firebase terms of service.",
+	},
+	"api_keys": {
+		Purpose:  "Enables the API Keys API and creates the keys listed under api_keys, each restricted to its api_targets and to the allowed referrers, IPs, iOS bundle IDs or Android apps, so teams do not create unrestricted keys by hand.",
+		Security: "API keys are bearer credentials; the key strings are never printed or stored by the tool. Existing keys are left unchanged, so tightening restrictions later is done in the console or with 'gcloud services api-keys update'.",
+	},
+	"bucket": {
+		Purpose:  "Creates the GCS bucket holding the Terraform state, with uniform bucket-level access and public access prevention, and reconciles its labels, Autoclass and soft delete duration.",
+		Security: "The state can contain secrets in plain text; anyone with read access to the bucket can read them.",
+	},
+	"bucket_iam": {
+		Purpose:  "Grants the Terraform service account tf_state_bucket_sa_role (e.g. roles/storage.objectAdmin) on the state bucket only, so it can use the backend without a storage role on the project.",
+		Security: "Drop roles/storage.admin from tf_service_account_project_roles once this is set, unless Terraform manages other buckets.",
+	},
+	"bucket_versioning": {
+		Purpose:  "Enables object versioning so earlier state versions can be restored after a bad apply or accidental deletion.",
+		Security: "Noncurrent versions keep old secrets; prune them with tf_state_bucket_lifecycle.",
+	},
+	"bucket_lifecycle": {
+		Purpose:  "Applies lifecycle rules deleting noncurrent state versions beyond the configured count or age.",
+		Security: "Replaces any lifecycle rules already set on the bucket.",
+	},
+	"bucket_kms": {
+		Purpose:  "Creates a Cloud KMS key and makes it the default encryption key of the state bucket (CMEK).",
+		Security: "Disabling or destroying the key makes the state unreadable; protect the key from deletion.",
+	},
+	"bucket_retention": {
+		Purpose:  "Sets a retention period so state objects cannot be deleted or overwritten before they reach that age, and optionally locks it.",
+		Security: "A locked retention policy is permanent: it can never be shortened or removed, and the bucket cannot be deleted while objects are retained.",
+	},
+	"bucket_logging": {
+		Purpose:  "Creates a log bucket next to the state bucket and enables GCS usage and storage logging into it, and optionally Data Access audit logs for Cloud Storage, so reads of the state can be audited.",
+		Security: "The logs name who accessed the state; restrict access to the log bucket like the state bucket. Data Access logs apply to every bucket in the project.",
+	},
+	"log_sinks": {
+		Purpose:  "Creates the Cloud Logging sinks listed under log_sinks (or updates their destination and filter), exporting matching logs to the state bucket, a dedicated bucket or a BigQuery dataset, and grants each sink's writer identity the role it needs on the destination.",
+		Security: "Exported logs can contain sensitive data and live as long as the destination keeps them. For BigQuery the writer gets roles/bigquery.dataEditor on the dataset's project, as gcloud cannot grant on a dataset. Organization and folder sinks include the logs of every child project.",
+	},
+	"sa_key": {
+		Purpose:  "Creates a JSON key for the Terraform service account, or uploads your public key, for environments that cannot use impersonation or Workload Identity Federation.",
+		Security: "A downloaded key is a long-lived credential with all the service account's roles; it is written with mode 0600 or kept in the OS keychain. Prefer Workload Identity Federation.",
+	},
+	"sa_key_cleanup": {
+		Purpose:  "Deletes user-managed keys of the Terraform service account older than the configured maximum age.",
+		Security: "Limits the lifetime of leaked keys; anything still using a deleted key stops working.",
+	},
+	"github_secrets": {
+		Purpose:  "Uploads the generated key as a GitHub Actions secret and sets the Workload Identity provider and service account as repository variables.",
+		Security: "The key leaves GCP's control once uploaded; the local copy is removed unless keep_local_key is set.",
+	},
+	"terraform_files": {
+		Purpose:  "Writes the Terraform backend and provider configuration pointing at the state bucket and service account, and the Backstage catalog entry if enabled.",
+		Security: "Generated files contain no secrets and are safe to commit.",
+	},
+}
+
+// runExplainCommand implements 'explain': it prints what each given step (default: all)
+// does for the config, why, the exact commands, the required permissions, and security
+// considerations
+func runExplainCommand(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file the commands are rendered for")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap explain [-config FILE] [-env NAME] [STEP_ID ...]")
+		fmt.Fprintf(fs.Output(), "Step IDs: %s\n", strings.Join(stepIDs(), ", "))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	for _, id := range fs.Args() {
+		if !isStepID(id) {
+			logError("Unknown step '%s'; valid steps are: %s", id, strings.Join(stepIDs(), ", "))
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+	if err != nil {
+		logError("Failed to load configuration: %v", err)
+	}
+
+	selected := map[string]bool{}
+	for _, id := range fs.Args() {
+		selected[id] = true
+	}
+	for _, ps := range buildPlan(cfg) {
+		if len(selected) > 0 && !selected[ps.ID] {
+			continue
+		}
+		fmt.Fprintf(stdout, "== %s (%s) ==\n", ps.Name, ps.ID)
+		writeStepExplanation(stdout, cfg, "  ", ps.ID)
+		if ps.Skipped {
+			fmt.Fprintf(stdout, "  Commands:    none, skipped for this config (%s)\n", ps.Reason)
+		} else {
+			fmt.Fprintln(stdout, "  Commands:")
+			for _, a := range ps.Actions {
+				fmt.Fprintf(stdout, "    - %s\n", a.Description)
+				if len(a.Command) > 0 {
+					fmt.Fprintf(stdout, "      $ %s\n", shellJoin(a.Command))
+				}
+			}
+		}
+		fmt.Fprintln(stdout)
+	}
+}
+
+// writeStepExplanation writes the purpose, permissions and security considerations of a step
+func writeStepExplanation(w io.Writer, cfg *Config, indent, id string) {
+	e, ok := stepExplanations[id]
+	if !ok {
+		fmt.Fprintf(w, "%sNo explanation available.\n", indent)
+		return
+	}
+	fmt.Fprintf(w, "%sWhy:         %s\n", indent, e.Purpose)
+	fmt.Fprintf(w, "%sPermissions: %s\n", indent, strings.Join(permissionNames(cfg, id), ", "))
+	fmt.Fprintf(w, "%sSecurity:    %s\n", indent, e.Security)
+}
+
+// permissionNames lists a step's required permissions with the resource they are needed on
+func permissionNames(cfg *Config, id string) []string {
+	var names []string
+	for _, p := range requiredPermissions(cfg, id) {
+		names = append(names, fmt.Sprintf("%s (%s)", p.Permission, p.Scope))
+	}
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+	return names
+}
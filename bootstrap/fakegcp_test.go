@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMainEnv makes the test binary run main() instead of the tests, so end-to-end tests can
+// run the real command line in a child process (main exits the process on errors)
+const runMainEnv = "GCP_BOOTSTRAP_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runBootstrap runs the command line with args in dir and returns its combined output
+func runBootstrap(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), runMainEnv+"=1", "NO_COLOR=1")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+const e2eConfig = `billing_account_id: "012345-6789AB-CDEF01"
+project_id: "e2e-proj-123"
+project_name: "E2E"
+project_region: "europe-west1"
+tf_state_bucket_name: "e2e-tfstate-xyz"
+tf_service_account_name: "terraform-admin"
+environment_class: development
+enable_apis: [iam.googleapis.com, storage.googleapis.com]
+tf_service_account_project_roles: [roles/storage.admin]
+`
+
+// TestFakeGCPEndToEnd bootstraps a project against the fake twice: the first run creates
+// everything, the re-run finds it all existing and changes nothing
+func TestFakeGCPEndToEnd(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(e2eConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(report string) runReport {
+		t.Helper()
+		out, err := runBootstrap(t, dir, "-fake-gcp", "-fake-gcp-state", "state.json", "-yes", "-report-json", report)
+		if err != nil {
+			t.Fatalf("bootstrap failed: %v\n%s", err, out)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, report))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r runReport
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	resources := func(r runReport, status string) []string {
+		var names []string
+		for _, res := range r.Resources {
+			if res.Status == status {
+				names = append(names, res.Kind+" "+res.Name)
+			}
+		}
+		return names
+	}
+
+	first := run("first.json")
+	if first.Status != runStatusSucceeded {
+		t.Fatalf("first run status %q, want %s", first.Status, runStatusSucceeded)
+	}
+	for _, s := range first.Steps {
+		if s.Error != "" {
+			t.Errorf("step %s failed: %s", s.ID, s.Error)
+		}
+	}
+	created := strings.Join(resources(first, resourceCreated), "\n")
+	for _, want := range []string{"project e2e-proj-123", "bucket gs://e2e-tfstate-xyz", "service_account terraform-admin@e2e-proj-123.iam.gserviceaccount.com"} {
+		if !strings.Contains(created, want) {
+			t.Errorf("first run did not create %q; created:\n%s", want, created)
+		}
+	}
+
+	var state fakeGCPState
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := state.Projects["e2e-proj-123"]
+	if !ok {
+		t.Fatal("project missing from the fake's state")
+	}
+	if p.BillingAccount != "012345-6789AB-CDEF01" {
+		t.Errorf("billing account %q, want 012345-6789AB-CDEF01", p.BillingAccount)
+	}
+	if !p.Services["storage.googleapis.com"] {
+		t.Error("storage.googleapis.com not enabled")
+	}
+	if b, ok := state.Buckets["gs://e2e-tfstate-xyz"]; !ok || !b.Versioning || !b.UBLA {
+		t.Errorf("state bucket %+v, want it versioned with uniform bucket-level access", b)
+	}
+	if backend, err := os.ReadFile(filepath.Join(dir, "terraform", "backend.tf")); err != nil {
+		t.Error(err)
+	} else if !strings.Contains(string(backend), `"e2e-tfstate-xyz"`) {
+		t.Errorf("backend.tf does not use the state bucket:\n%s", backend)
+	}
+
+	second := run("second.json")
+	if second.Status != runStatusSucceeded {
+		t.Fatalf("re-run status %q, want %s", second.Status, runStatusSucceeded)
+	}
+	if created := resources(second, resourceCreated); len(created) > 0 {
+		t.Errorf("re-run created resources again: %v", created)
+	}
+}
+
+// TestFakeGCPPlanMakesNoChanges checks that --plan against the fake leaves its state untouched
+func TestFakeGCPPlanMakesNoChanges(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(e2eConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runBootstrap(t, dir, "-fake-gcp", "-fake-gcp-state", "state.json", "-plan")
+	if err != nil {
+		t.Fatalf("plan failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "gcloud projects create e2e-proj-123") {
+		t.Errorf("plan does not create the project:\n%s", out)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "state.json")); err == nil {
+		var state fakeGCPState
+		if err := json.Unmarshal(data, &state); err != nil {
+			t.Fatal(err)
+		}
+		if len(state.Projects) > 0 || len(state.Buckets) > 0 {
+			t.Errorf("plan changed the fake's state: %s", data)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// firebaseAPI is the Firebase Management API endpoint; gcloud has no command adding Firebase
+const firebaseAPI = "https://firebase.googleapis.com/v1beta1"
+
+// firebaseOperationPoll is how often the addFirebase operation is checked
+const firebaseOperationPoll = 5 * time.Second
+
+// firebaseOperation is the long-running operation returned by addFirebase
+type firebaseOperation struct {
+	Name  string `json:"name"`
+	Done  bool   `json:"done"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// enableFirebase adds Firebase to the project (what 'firebase projects:addfirebase' does),
+// so teams building mobile backends do not have to attach it later
+func enableFirebase(ctx context.Context, cfg *Config) error {
+	if !cfg.EnableFirebase {
+		logInfo("Skipping Firebase enablement as per config.")
+		return nil
+	}
+	if err := ensureServicesEnabled(ctx, cfg.ProjectID, []string{"firebase.googleapis.com"}); err != nil {
+		return fmt.Errorf("failed to enable the Firebase Management API: %w", err)
+	}
+	enabled, err := firebaseProjectExists(ctx, cfg.ProjectID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		logInfo("Firebase is already enabled on project '%s'.", cfg.ProjectID)
+		metrics.recordResource("firebase_project", cfg.ProjectID, resourceExisted)
+		return nil
+	}
+	logInfo("Adding Firebase to project '%s'...", cfg.ProjectID)
+	if err := addFirebase(ctx, cfg.ProjectID); err != nil {
+		return err
+	}
+	metrics.recordResource("firebase_project", cfg.ProjectID, resourceCreated)
+	logInfo("Firebase enabled on project '%s'.", cfg.ProjectID)
+	return nil
+}
+
+// firebaseProjectExists reports whether Firebase has been added to projectID
+func firebaseProjectExists(ctx context.Context, projectID string) (bool, error) {
+	if fakeGCP != nil {
+		return fakeGCP.firebaseProjectExists(projectID)
+	}
+	status, data, err := googleAPIRequest(ctx, http.MethodGet, firebaseAPI+"/projects/"+projectID, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check Firebase on '%s': %w", projectID, err)
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return false, nil
+	case status/100 != 2:
+		return false, fmt.Errorf("failed to check Firebase on '%s': %d: %s", projectID, status, data)
+	}
+	return true, nil
+}
+
+// addFirebase calls addFirebase on projectID and waits for the operation to finish
+func addFirebase(ctx context.Context, projectID string) error {
+	if fakeGCP != nil {
+		return fakeGCP.addFirebase(projectID)
+	}
+	logInfo("Executing: POST %s/projects/%s:addFirebase", firebaseAPI, projectID)
+	status, data, err := googleAPIRequest(ctx, http.MethodPost, firebaseAPI+"/projects/"+projectID+":addFirebase", []byte("{}"))
+	if err != nil {
+		return fmt.Errorf("failed to add Firebase: %w", err)
+	}
+	if status/100 != 2 {
+		if status == http.StatusForbidden {
+			return fmt.Errorf("failed to add Firebase: %d: %s (accept the Firebase terms of service once in the Firebase console with this account)", status, data)
+		}
+		return fmt.Errorf("failed to add Firebase: %d: %s", status, data)
+	}
+	for {
+		var op firebaseOperation
+		if err := json.Unmarshal(data, &op); err != nil {
+			return fmt.Errorf("failed to parse the addFirebase operation: %s", data)
+		}
+		if op.Done {
+			if op.Error != nil {
+				return fmt.Errorf("failed to add Firebase: %s", op.Error.Message)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(firebaseOperationPoll):
+		}
+		if status, data, err = googleAPIRequest(ctx, http.MethodGet, firebaseAPI+"/"+op.Name, nil); err != nil || status/100 != 2 {
+			return fmt.Errorf("failed to poll the addFirebase operation '%s': %v %s", op.Name, err, data)
+		}
+	}
+}
+
+func planFirebase(cfg *Config) []planAction {
+	if !cfg.EnableFirebase {
+		return nil
+	}
+	return []planAction{
+		{Description: "Enable the Firebase Management API", Command: []string{"gcloud", "services", "enable", "firebase.googleapis.com", "--project", cfg.ProjectID}},
+		{Description: "Add Firebase to the project unless it is already a Firebase project (Firebase Management API)"},
+	}
+}
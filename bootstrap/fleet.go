@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// registerFleet prepares the project for registration with a GKE Hub fleet hosted in
+// another project: it enables the GKE Hub API, lets the host project's Hub service agent
+// manage memberships for this project, and grants the TF SA the needed roles on the host
+func registerFleet(ctx context.Context, cfg *Config) error {
+	fleet := cfg.Fleet
+	if !fleet.Enabled {
+		logInfo("Skipping fleet registration as per config.")
+		return nil
+	}
+	logInfo("Registering project '%s' with fleet host project '%s'...", cfg.ProjectID, fleet.HostProjectID)
+
+	err := ensureServicesEnabled(ctx, cfg.ProjectID, []string{"gkehub.googleapis.com"})
+	if err != nil {
+		return fmt.Errorf("failed to enable GKE Hub API: %w", err)
+	}
+
+	hostNumber, err := projectNumber(ctx, fleet.HostProjectID)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the host project's GKE Hub service agent exists before binding it
+	err = runCommand(ctx, "gcloud", "beta", "services", "identity", "create",
+		"--service", "gkehub.googleapis.com",
+		"--project", fleet.HostProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to ensure GKE Hub service agent in host project: %w", err)
+	}
+
+	if err := snapshotProjectIAM(ctx, cfg.ProjectID); err != nil {
+		return err
+	}
+	if err := snapshotProjectIAM(ctx, fleet.HostProjectID); err != nil {
+		return err
+	}
+	hubAgent := fmt.Sprintf("serviceAccount:service-%s@gcp-sa-gkehub.iam.gserviceaccount.com", hostNumber)
+	logInfo("Granting cross-project GKE Hub service agent role to the fleet host...")
+	err = runCommand(ctx, "gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID,
+		"--member", hubAgent,
+		"--role", "roles/gkehub.crossProjectServiceAgent",
+		"--condition=None")
+	if err != nil {
+		return fmt.Errorf("failed to grant fleet host service agent access: %w", err)
+	}
+
+	member := fmt.Sprintf("serviceAccount:%s", cfg.TFServiceAccountEmail)
+	for _, role := range fleet.TFSAHostRoles {
+		logInfo("Granting role '%s' on fleet host project to Terraform SA...", role)
+		err := runCommand(ctx, "gcloud", "projects", "add-iam-policy-binding", fleet.HostProjectID,
+			"--member", member,
+			"--role", role,
+			"--condition=None")
+		if err != nil {
+			return fmt.Errorf("failed to grant role %s on fleet host project: %w", role, err)
+		}
+	}
+
+	logInfo("Project prepared for fleet membership in '%s'. Register clusters with Terraform (google_gke_hub_membership).", fleet.HostProjectID)
+	return nil
+}
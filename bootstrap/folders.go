@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxFolderNameLength is the longest display name GCP accepts for a folder
+const maxFolderNameLength = 30
+
+// reconcileFolders creates the configured folder hierarchy under the organization,
+// reusing folders that already exist by display name, and applies per-folder IAM
+func reconcileFolders(ctx context.Context, cfg *Config) error {
+	if len(cfg.Folders) == 0 && cfg.FolderPath == "" {
+		logInfo("Skipping folder hierarchy as per config.")
+		return nil
+	}
+	if err := reconcileFolderLevel(ctx, "organizations/"+cfg.OrganizationID, "", cfg.Folders); err != nil {
+		return err
+	}
+	return resolveFolderPath(ctx, cfg)
+}
+
+// resolveFolderPath finds or creates each folder of folder_path below the organization and
+// makes the leaf the project's parent folder
+func resolveFolderPath(ctx context.Context, cfg *Config) error {
+	if cfg.FolderPath == "" || cfg.FolderID != "" {
+		return nil
+	}
+	parent, path := "organizations/"+cfg.OrganizationID, ""
+	for _, name := range strings.Split(cfg.FolderPath, "/") {
+		path += "/" + name
+		existing, err := listChildFolders(ctx, parent)
+		if err != nil {
+			return err
+		}
+		id, ok := existing[name]
+		if ok {
+			logInfo("Folder '%s' already exists (%s).", path, id)
+			metrics.recordResource("folder", path, resourceExisted)
+		} else if id, err = createFolder(ctx, parent, name, path); err != nil {
+			return err
+		}
+		parent = id
+	}
+	cfg.FolderID = strings.TrimPrefix(parent, "folders/")
+	logInfo("Project parent folder '%s' resolved to %s.", path, parent)
+	return nil
+}
+
+// createFolder creates the folder name below parent; path is its display path
+func createFolder(ctx context.Context, parent, name, path string) (string, error) {
+	logInfo("Creating folder '%s'...", path)
+	id, err := runCommandGetOutput(ctx, "gcloud", append([]string{"resource-manager", "folders", "create",
+		"--display-name", name, "--format=value(name)"}, folderParentFlags(parent)...)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create folder '%s': %w", path, err)
+	}
+	logInfo("Folder '%s' created (%s).", path, id)
+	metrics.recordResource("folder", path, resourceCreated)
+	return id, nil
+}
+
+// reconcileFolderLevel reconciles the folders directly below parent ("organizations/N"
+// or "folders/N"); path is the display path of parent for log messages
+func reconcileFolderLevel(ctx context.Context, parent, path string, folders []FolderConfig) error {
+	existing, err := listChildFolders(ctx, parent)
+	if err != nil {
+		return err
+	}
+	for _, f := range folders {
+		folderPath := path + "/" + f.Name
+		id, ok := existing[f.Name]
+		if ok {
+			logInfo("Folder '%s' already exists (%s).", folderPath, id)
+			metrics.recordResource("folder", folderPath, resourceExisted)
+		} else if id, err = createFolder(ctx, parent, f.Name, folderPath); err != nil {
+			return err
+		}
+		if err := grantFolderIAM(ctx, id, folderPath, f.IAM); err != nil {
+			return err
+		}
+		if len(f.Children) > 0 {
+			if err := reconcileFolderLevel(ctx, id, folderPath, f.Children); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listChildFolders returns the folders directly below parent, keyed by display name
+func listChildFolders(ctx context.Context, parent string) (map[string]string, error) {
+	args := append([]string{"resource-manager", "folders", "list", "--format=value(displayName,name)"}, folderParentFlags(parent)...)
+	output, err := runCommandGetOutput(ctx, "gcloud", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folders under '%s': %w", parent, err)
+	}
+	folders := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		// Display names may contain spaces; the resource name is the last field
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+		folders[strings.TrimSpace(line[:i])] = line[i+1:]
+	}
+	return folders, nil
+}
+
+// folderParentFlags returns the gcloud flags selecting parent as the parent resource
+func folderParentFlags(parent string) []string {
+	if id, ok := strings.CutPrefix(parent, "folders/"); ok {
+		return []string{"--folder", id}
+	}
+	return []string{"--organization", strings.TrimPrefix(parent, "organizations/")}
+}
+
+// grantFolderIAM grants the configured role bindings on the folder
+func grantFolderIAM(ctx context.Context, folder, path string, bindings map[string][]string) error {
+	for _, role := range sortedKeys(bindings) {
+		for _, member := range bindings[role] {
+			logInfo("Granting '%s' on folder '%s' to '%s'...", role, path, member)
+			err := runCommand(ctx, "gcloud", "resource-manager", "folders", "add-iam-policy-binding", strings.TrimPrefix(folder, "folders/"),
+				"--member", member,
+				"--role", role,
+				"--condition=None")
+			if err != nil {
+				return fmt.Errorf("failed to grant '%s' on folder '%s' to '%s': %w", role, path, member, err)
+			}
+		}
+	}
+	return nil
+}
+
+// countFolders returns the number of folders in the tree
+func countFolders(folders []FolderConfig) int {
+	n := len(folders)
+	for _, f := range folders {
+		n += countFolders(f.Children)
+	}
+	return n
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateFolderPath checks that folder_path is a list of valid folder names
+func validateFolderPath(path string) error {
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			return fmt.Errorf("folder_path '%s' has an empty folder name", path)
+		}
+		if len(name) > maxFolderNameLength {
+			return fmt.Errorf("folder_path name '%s' is longer than %d characters", name, maxFolderNameLength)
+		}
+	}
+	return nil
+}
+
+// validateFolders checks the folder tree for empty, overlong and duplicate sibling names
+func validateFolders(folders []FolderConfig, path string) error {
+	seen := map[string]bool{}
+	for _, f := range folders {
+		if f.Name == "" {
+			return fmt.Errorf("folder under '%s/' has no name", path)
+		}
+		if len(f.Name) > maxFolderNameLength {
+			return fmt.Errorf("folder name '%s' is longer than %d characters", f.Name, maxFolderNameLength)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("folder '%s/%s' is defined more than once", path, f.Name)
+		}
+		seen[f.Name] = true
+		for role, members := range f.IAM {
+			if !strings.HasPrefix(role, "roles/") && !strings.HasPrefix(role, "organizations/") {
+				return fmt.Errorf("folder '%s/%s' has invalid role '%s'", path, f.Name, role)
+			}
+			if len(members) == 0 {
+				return fmt.Errorf("folder '%s/%s' role '%s' has no members", path, f.Name, role)
+			}
+		}
+		if err := validateFolders(f.Children, path+"/"+f.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+//go:build !nogcloud
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// This file holds every call to an external tool (gcloud, gh, git and the OS keychain
+// tools); the nogcloud build tag replaces it with stubs that return errNoGcloudBackend
+
+// lookGcloud checks that gcloud is on PATH
+func lookGcloud() error {
+	_, err := exec.LookPath("gcloud")
+	return err
+}
+
+// newCommand builds a command bound to ctx that runs in its own process group, so
+// cancellation terminates gcloud together with any helpers it spawned
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if name == "gcloud" {
+		cmd.Env = append(append(os.Environ(), gcloudQuietEnv...), commandEnv...)
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	return cmd
+}
+
+// execCommand runs a command for runCommand, streaming its output tagged with the step
+func execCommand(ctx context.Context, name string, args ...string) error {
+	cmd := newCommand(ctx, name, args...)
+	var captured bytes.Buffer
+	// Both streams are copied concurrently; write them line by line, tagged with the step
+	step := logStep()
+	var outStream, errStream *stepOutput
+	if quietLogging {
+		// Keep the console clean; the output is replayed if the command fails
+		outStream = newStepOutput(io.MultiWriter(&captured, logFileWriter), step)
+		errStream = newStepOutput(io.MultiWriter(&captured, logFileWriter), step)
+	} else {
+		outStream = newStepOutput(stdout, step)
+		errStream = newStepOutput(stderr, step)
+	}
+	cmd.Stdout, cmd.Stderr = outStream, errStream
+	err := cmd.Run()
+	outStream.Flush()
+	errStream.Flush()
+	if ctx.Err() != nil {
+		return fmt.Errorf("command cancelled: %s %s: %w", name, strings.Join(args, " "), ctx.Err())
+	}
+	if err != nil {
+		if quietLogging {
+			lockedWriter{os.Stderr}.Write(captured.Bytes())
+		}
+		return fmt.Errorf("command failed: %s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// execCommandGetOutput runs a command for runCommandGetOutput and returns its stdout
+func execCommandGetOutput(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := newCommand(ctx, name, args...)
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+	start := time.Now()
+	outputBytes, err := cmd.Output() // Runs command and captures stdout
+	logCapturedCommand(name+" "+strings.Join(args, " "), time.Since(start), cmd.ProcessState, errBuf.String())
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("command cancelled: %s %s: %w", name, strings.Join(args, " "), ctx.Err())
+	}
+	if err != nil {
+		// If there's an error, include stderr as well for better debugging
+		return "", fmt.Errorf("command failed: %s %s: %w\nStderr: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stripGcloudBanners(errBuf.String())))
+	}
+	return strings.TrimSpace(stripGcloudBanners(string(outputBytes))), nil
+}
+
+// execWithInput runs a command with input on stdin and extra environment variables, and
+// returns its stdout and stderr
+func execWithInput(ctx context.Context, env []string, input []byte, name string, args ...string) ([]byte, []byte, error) {
+	cmd := newCommand(ctx, name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+	output, err := cmd.Output()
+	return output, errBuf.Bytes(), err
+}
+
+// gitCheckIgnore reports whether rel is ignored in the git repository at root
+func gitCheckIgnore(ctx context.Context, root, rel string) (bool, error) {
+	// Exit status 0 means ignored, 1 means not ignored
+	err := exec.CommandContext(ctx, "git", "-C", root, "check-ignore", "-q", "--no-index", rel).Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,33 @@
+//go:build nogcloud
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// The nogcloud build tag leaves out every call to an external tool, so the binary needs
+// neither gcloud nor cgo: build it with CGO_ENABLED=0 go build -tags nogcloud. What remains
+// is --fake-gcp, --plan --assume and the offline subcommands.
+
+func lookGcloud() error {
+	return errNoGcloudBackend
+}
+
+func execCommand(ctx context.Context, name string, args ...string) error {
+	return fmt.Errorf("command failed: %s %s: %w", name, strings.Join(args, " "), errNoGcloudBackend)
+}
+
+func execCommandGetOutput(ctx context.Context, name string, args ...string) (string, error) {
+	return "", fmt.Errorf("command failed: %s %s: %w", name, strings.Join(args, " "), errNoGcloudBackend)
+}
+
+func execWithInput(ctx context.Context, env []string, input []byte, name string, args ...string) ([]byte, []byte, error) {
+	return nil, nil, errNoGcloudBackend
+}
+
+func gitCheckIgnore(ctx context.Context, root, rel string) (bool, error) {
+	return false, errNoGcloudBackend
+}
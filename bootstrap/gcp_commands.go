@@ -1,65 +1,243 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-	"time" // Added import
+	"time"
+)
+
+// Propagation delays for eventually consistent GCP operations
+const (
+	billingLinkPropagationDelay    = 10 * time.Second
+	apiPropagationDelay            = 5 * time.Second
+	serviceAccountPropagationDelay = 10 * time.Second
 )
 
 // --- Functions wrapping gcloud commands ---
 
 // projectExists checks if a project exists using gcloud projects list --filter
-func projectExists(projectID string) (bool, error) {
+func projectExists(ctx context.Context, projectID string) (bool, error) {
 	// Use list --filter which relies on list permission the user likely has
 	filterArg := fmt.Sprintf("project_id=%s", projectID)
 	// Use --quiet to suppress interactive prompts if any were possible
-	output, err := runCommandGetOutput("gcloud", "projects", "list", "--filter", filterArg, "--format=value(project_id)", "--quiet")
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "list", "--filter", filterArg, "--format=value(project_id)", "--quiet")
 	if err != nil {
-		// Don't treat command failure as definitive "doesn't exist", could be other issues
-		// Log the error but proceed as if it might not exist, create will fail if it does
-		logWarning("Could not definitively check project existence via 'list --filter': %v", err)
-		return false, nil // Let the create command handle existence check more robustly
+		// A failed list says nothing about existence; callers decide whether to go on
+		return false, fmt.Errorf("failed to check whether project '%s' exists: %w", projectID, err)
 	}
 	// If output is exactly the project ID, it exists
 	return output == projectID, nil
 }
 
-func createProject(cfg *Config) error {
+// projectCreateArgs returns the gcloud arguments creating the project, placed in the folder
+// of its data classification if one is configured
+func projectCreateArgs(cfg *Config) []string {
+	args := []string{"projects", "create", cfg.ProjectID, "--name", cfg.ProjectName}
+	switch {
+	case cfg.parentFolder() != "":
+		args = append(args, "--folder", cfg.parentFolder())
+	case cfg.FolderPath != "":
+		// Resolved by the folders step; only the plan sees it unresolved
+		args = append(args, "--folder", fmt.Sprintf("<ID of folder %s>", cfg.FolderPath))
+	case cfg.OrganizationID != "":
+		args = append(args, "--organization", cfg.OrganizationID)
+	}
+	if labels := cfg.projectLabels(); len(labels) > 0 {
+		args = append(args, "--labels", joinLabels(labels))
+	}
+	return args
+}
+
+// parentFolder returns the folder the project is created in: the data classification's
+// parent_folder, else folder_id
+func (c *Config) parentFolder() string {
+	if dc := c.classification(); dc != nil && dc.ParentFolder != "" {
+		return dc.ParentFolder
+	}
+	return c.FolderID
+}
+
+// checkProjectParent checks that an existing project is under the configured folder or
+// organization; projects are never moved automatically
+func checkProjectParent(ctx context.Context, cfg *Config) error {
+	var want string
+	switch {
+	case cfg.parentFolder() != "":
+		want = "folder\t" + cfg.parentFolder()
+	case cfg.FolderPath != "":
+		return nil // Unresolved because the folders step was skipped
+	case cfg.OrganizationID != "":
+		want = "organization\t" + cfg.OrganizationID
+	default:
+		return nil
+	}
+	parent, err := runCommandGetOutput(ctx, "gcloud", "projects", "describe", cfg.ProjectID, "--format=value(parent.type,parent.id)")
+	if err != nil {
+		logWarning("Could not check the parent of project '%s': %v", cfg.ProjectID, err)
+		return nil
+	}
+	if parent == want {
+		return nil
+	}
+	msg := fmt.Sprintf("project '%s' is in '%s' but config expects '%s'; move it with 'gcloud beta projects move' if intended", cfg.ProjectID, strings.ReplaceAll(parent, "\t", " "), strings.ReplaceAll(want, "\t", " "))
+	if cfg.Strict {
+		return errors.New(msg)
+	}
+	logWarning("Parent mismatch: %s.", msg)
+	return nil
+}
+
+// projectLabels returns project_labels plus the data classification label, if any
+func (c *Config) projectLabels() map[string]string {
+	labels := maps.Clone(c.ProjectLabels)
+	if c.DataClassification != "" {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[dataClassificationLabel] = c.DataClassification
+	}
+	if c.ChangeTicket != "" {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[changeTicketLabel] = strings.ToLower(c.ChangeTicket)
+	}
+	if c.ProjectIDPrefix != "" {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[projectIDPrefixLabel] = c.ProjectIDPrefix
+	}
+	return labels
+}
+
+// joinLabels renders labels as the sorted key=value list gcloud label flags take
+func joinLabels(labels map[string]string) string {
+	var kvs []string
+	for _, key := range sortedKeys(labels) {
+		kvs = append(kvs, key+"="+labels[key])
+	}
+	return strings.Join(kvs, ",")
+}
+
+// labelUpdates returns the key=value pairs of want that are missing or different in current
+func labelUpdates(current, want map[string]string) []string {
+	var updates []string
+	for _, key := range sortedKeys(want) {
+		if value := want[key]; current[key] != value {
+			updates = append(updates, key+"="+value)
+		}
+	}
+	return updates
+}
+
+// ensureProjectLabels adds or updates the configured labels on an existing project. Labels
+// not in config are left alone, like on the state bucket.
+func ensureProjectLabels(ctx context.Context, cfg *Config) error {
+	labels := cfg.projectLabels()
+	if len(labels) == 0 {
+		return nil
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "describe", cfg.ProjectID, "--format=json(labels)")
+	if err != nil {
+		return fmt.Errorf("failed to read labels of project '%s': %w", cfg.ProjectID, err)
+	}
+	var current struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.Unmarshal([]byte(output), &current); err != nil {
+		return fmt.Errorf("failed to parse labels of project '%s': %w", cfg.ProjectID, err)
+	}
+	updates := labelUpdates(current.Labels, labels)
+	if len(updates) == 0 {
+		logInfo("Project labels are up to date.")
+		return nil
+	}
+	logInfo("Setting labels %s on project '%s'...", strings.Join(updates, ", "), cfg.ProjectID)
+	if err := runCommand(ctx, "gcloud", "projects", "update", cfg.ProjectID, "--update-labels", strings.Join(updates, ",")); err != nil {
+		return fmt.Errorf("failed to set labels on project '%s': %w", cfg.ProjectID, err)
+	}
+	return nil
+}
+
+// createProject ensures the project exists and then resolves a state bucket name that
+// depends on its project number
+func createProject(ctx context.Context, cfg *Config) error {
+	if err := resolveProjectIDPrefix(ctx, cfg); err != nil {
+		return err
+	}
+	if err := ensureProject(ctx, cfg); err != nil {
+		return err
+	}
+	return resolveBucketNameTemplate(ctx, cfg)
+}
+
+func ensureProject(ctx context.Context, cfg *Config) error {
 	logInfo("Attempting to create project '%s'...", cfg.ProjectID)
-	exists, err := projectExists(cfg.ProjectID)
+	exists, err := projectExists(ctx, cfg.ProjectID)
 	if err != nil {
-		// Error during check is logged in projectExists, proceed cautiously
-		logWarning("Proceeding with project creation despite check error...")
-		// return err // Optionally stop here if check failure is critical
+		// Creation fails anyway if the project exists, so the check is not critical
+		logWarning("%v; proceeding with project creation...", err)
 	}
 	if exists {
 		logInfo("Project '%s' already exists.", cfg.ProjectID)
-		return nil
+		metrics.recordResource("project", cfg.ProjectID, resourceExisted)
+		if err := checkProjectParent(ctx, cfg); err != nil {
+			return err
+		}
+		return ensureProjectLabels(ctx, cfg)
 	}
 
 	logInfo("Project '%s' does not appear to exist or check failed, attempting creation...", cfg.ProjectID)
-	args := []string{"projects", "create", cfg.ProjectID, "--name", cfg.ProjectName}
-	if cfg.OrganizationID != "" {
-		args = append(args, "--organization", cfg.OrganizationID)
+	// The folders step resolves folder_path, unless it was skipped
+	if err := resolveFolderPath(ctx, cfg); err != nil {
+		return err
 	}
-
-	err = runCommand("gcloud", args...)
+	err = runCommand(ctx, "gcloud", projectCreateArgs(cfg)...)
 	if err != nil {
 		// Check if error is because it already exists (race condition or failed check)
 		if strings.Contains(err.Error(), "already exists") {
+			if cfg.ProjectIDPrefix != "" {
+				// The generated ID is taken by a project the caller cannot see; it is not ours
+				return fmt.Errorf("generated project ID '%s' is already taken by a project outside your view; re-run to generate another ID from project_id_prefix '%s': %w", cfg.ProjectID, cfg.ProjectIDPrefix, err)
+			}
 			logWarning("Project creation failed because project '%s' already exists (likely race condition or failed check). Continuing...", cfg.ProjectID)
-			return nil // Treat as non-fatal if it already exists
+			metrics.recordResource("project", cfg.ProjectID, resourceExisted)
+			return ensureProjectLabels(ctx, cfg) // Treat as non-fatal if it already exists
 		}
 		return fmt.Errorf("failed to create project: %w", err)
 	}
 	logInfo("Project '%s' created.", cfg.ProjectID)
+	metrics.recordResource("project", cfg.ProjectID, resourceCreated)
 	return nil
 }
 
-func isBillingLinked(projectID, billingAccountID string) (bool, error) {
-	output, err := runCommandGetOutput("gcloud", "beta", "billing", "projects", "describe", projectID, "--format=value(billingAccountName)")
+// projectNumber returns the numeric project number for projectID
+func projectNumber(ctx context.Context, projectID string) (string, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "describe", projectID, "--format=value(projectNumber)")
+	if err != nil {
+		return "", fmt.Errorf("failed to look up project number of '%s': %w", projectID, err)
+	}
+	if output == "" {
+		return "", fmt.Errorf("project '%s' has no project number", projectID)
+	}
+	return output, nil
+}
+
+func isBillingLinked(ctx context.Context, projectID, billingAccountID string) (bool, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "beta", "billing", "projects", "describe", projectID, "--format=value(billingAccountName)")
 	if err != nil {
 		// If describe fails, it might not be linked or another issue occurred
 		if strings.Contains(err.Error(), "must be associated with a billing account") {
@@ -80,9 +258,17 @@ func isBillingLinked(projectID, billingAccountID string) (bool, error) {
 	return false, nil
 }
 
-func linkBilling(cfg *Config) error {
+func linkBilling(ctx context.Context, cfg *Config) error {
+	if cfg.BillingLink.Subaccount.Enabled {
+		id, err := resolveBillingSubaccount(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		// Later steps, e.g. the billing role grant, apply to the subaccount
+		cfg.BillingAccountID = id
+	}
 	logInfo("Linking project '%s' to billing account '%s'...", cfg.ProjectID, cfg.BillingAccountID)
-	linked, err := isBillingLinked(cfg.ProjectID, cfg.BillingAccountID)
+	linked, err := isBillingLinked(ctx, cfg.ProjectID, cfg.BillingAccountID)
 	if err != nil {
 		// Error during check is logged in isBillingLinked, proceed cautiously
 		logWarning("Proceeding with billing link despite check error...")
@@ -93,7 +279,7 @@ func linkBilling(cfg *Config) error {
 	}
 
 	logInfo("Billing account not linked or check failed, attempting link...")
-	err = runCommand("gcloud", "beta", "billing", "projects", "link", cfg.ProjectID, "--billing-account", cfg.BillingAccountID)
+	err = linkBillingWithRetry(ctx, cfg, cfg.BillingAccountID)
 	if err != nil {
 		// Check if error is because it's already linked (race condition or failed check)
 		if strings.Contains(err.Error(), "already associated") {
@@ -103,50 +289,142 @@ func linkBilling(cfg *Config) error {
 		return fmt.Errorf("failed to link billing account: %w", err)
 	}
 	logInfo("Billing account linked.")
-	return nil
+	// A freshly linked billing account is not immediately visible to API enablement
+	return waitForPropagation(ctx, "billing link propagation", billingLinkPropagationDelay)
 }
 
-func enableAPIs(cfg *Config) error {
+func enableAPIs(ctx context.Context, cfg *Config) error {
 	logInfo("Enabling essential APIs...")
 	if len(cfg.EnableAPIs) == 0 {
 		logWarning("No APIs specified in config to enable.")
 		return nil
 	}
+	missing := missingServices(ctx, cfg.ProjectID, cfg.EnableAPIs)
+	for _, api := range cfg.EnableAPIs {
+		if !slices.Contains(missing, api) {
+			metrics.recordResource("api", api, resourceExisted)
+		}
+	}
+	if len(missing) == 0 {
+		logInfo("All %d APIs already enabled.", len(cfg.EnableAPIs))
+		return nil
+	}
+	logInfo("%d already enabled, enabling %d: %s", len(cfg.EnableAPIs)-len(missing), len(missing), strings.Join(missing, ", "))
+
 	args := []string{"services", "enable"}
-	args = append(args, cfg.EnableAPIs...)
+	args = append(args, missing...)
 	args = append(args, "--project", cfg.ProjectID)
 
 	// Add --async flag to speed up enablement, as it can take time
 	args = append(args, "--async")
 
-	err := runCommand("gcloud", args...)
+	err := runCommand(ctx, "gcloud", args...)
 	if err != nil {
+		if cfg.Strict {
+			return fmt.Errorf("failed to submit API enablement request: %w", err)
+		}
 		// API enablement can sometimes have transient issues, log warning but continue
 		logWarning("Failed to submit API enablement request (run 'gcloud services list --enabled' later to verify): %v", err)
 		return nil // Continue bootstrap even if API enablement fails async
 	}
-	logInfo("API enablement submitted asynchronously for: %s", strings.Join(cfg.EnableAPIs, ", "))
+	apiEnablementPending = true
+	markServicesEnabled(cfg.ProjectID, missing)
+	for _, api := range missing {
+		metrics.recordResource("api", api, resourceCreated)
+	}
+	logInfo("API enablement submitted asynchronously for: %s", strings.Join(missing, ", "))
 	logInfo("Note: APIs may take a few minutes to become fully active.")
 	return nil
 }
 
-func createServiceAccount(cfg *Config) error {
+// apiEnablementPending is set once enableAPIs has submitted an asynchronous enablement
+var apiEnablementPending bool
+
+// enabledServicesCache holds the services enabled per project, listed once per run
+var enabledServicesCache = map[string]map[string]bool{}
+
+// enabledServices returns the services enabled on projectID, listing them on first use
+func enabledServices(ctx context.Context, projectID string) (map[string]bool, error) {
+	if cached, ok := enabledServicesCache[projectID]; ok {
+		return cached, nil
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "services", "list", "--enabled", "--project", projectID, "--format=value(config.name)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list enabled services of project '%s': %w", projectID, err)
+	}
+	enabled := map[string]bool{}
+	for _, name := range strings.Fields(output) {
+		enabled[name] = true
+	}
+	enabledServicesCache[projectID] = enabled
+	return enabled, nil
+}
+
+// missingServices returns the entries of apis not yet enabled on projectID. If the
+// enabled services cannot be listed, all of apis are returned so they get enabled anyway.
+func missingServices(ctx context.Context, projectID string, apis []string) []string {
+	enabled, err := enabledServices(ctx, projectID)
+	if err != nil {
+		logDebug("%v", err)
+		return apis
+	}
+	var missing []string
+	for _, api := range apis {
+		if !enabled[api] {
+			missing = append(missing, api)
+		}
+	}
+	return missing
+}
+
+// markServicesEnabled records apis as enabled on projectID in the cache
+func markServicesEnabled(projectID string, apis []string) {
+	if enabled, ok := enabledServicesCache[projectID]; ok {
+		for _, api := range apis {
+			enabled[api] = true
+		}
+	}
+}
+
+// ensureServicesEnabled synchronously enables those of apis not yet enabled on projectID
+func ensureServicesEnabled(ctx context.Context, projectID string, apis []string) error {
+	missing := missingServices(ctx, projectID, apis)
+	if len(missing) == 0 {
+		logInfo("APIs already enabled: %s", strings.Join(apis, ", "))
+		return nil
+	}
+	args := append([]string{"services", "enable"}, missing...)
+	if err := runCommand(ctx, "gcloud", append(args, "--project", projectID)...); err != nil {
+		return err
+	}
+	markServicesEnabled(projectID, missing)
+	return nil
+}
+
+func createServiceAccount(ctx context.Context, cfg *Config) error {
 	logInfo("Attempting to create Terraform service account '%s'...", cfg.TFServiceAccountEmail)
 
-	// Add a small delay to allow IAM API propagation after enablement, just in case.
-	// APIs were enabled asynchronously. While usually fast, this adds robustness.
-	logInfo("Waiting a few seconds for API propagation...")
-	time.Sleep(5 * time.Second) // Wait 5 seconds
+	// APIs were enabled asynchronously. While usually fast, give the IAM API time to activate.
+	if apiEnablementPending {
+		if err := waitForPropagation(ctx, "API activation", apiPropagationDelay); err != nil {
+			return err
+		}
+	}
 
 	// Directly attempt creation. gcloud create will fail if it already exists.
-	err := runCommand("gcloud", "iam", "service-accounts", "create", cfg.TFServiceAccountName,
-		"--display-name", "Terraform Admin Service Account",
-		"--project", cfg.ProjectID)
+	err := runCommand(ctx, "gcloud", serviceAccountCreateArgs(cfg)...)
 	if err != nil {
 		// Check if the error is because it already exists.
 		if strings.Contains(err.Error(), "already exists") {
 			logWarning("Service account '%s' already exists. Continuing...", cfg.TFServiceAccountName)
-			// If it already exists, we can proceed without error.
+			metrics.recordResource("service_account", cfg.TFServiceAccountEmail, resourceExisted)
+			// If it already exists, only its metadata may need updating
+			if err := ensureSAMetadata(ctx, cfg); err != nil {
+				if cfg.Strict {
+					return err
+				}
+				logWarning("Could not update service account metadata: %v", err)
+			}
 			return nil
 		}
 		// Otherwise, it's a real error during creation.
@@ -155,43 +433,122 @@ func createServiceAccount(cfg *Config) error {
 
 	// If the command succeeded without error, the SA was created.
 	logInfo("Service account '%s' created.", cfg.TFServiceAccountEmail)
-	return nil
+	metrics.recordResource("service_account", cfg.TFServiceAccountEmail, resourceCreated)
+	// New service accounts can take a few seconds before IAM bindings accept them as members
+	return waitForPropagation(ctx, "service account propagation", serviceAccountPropagationDelay)
 }
 
-func grantIAMRoles(cfg *Config) error {
+// serviceAccountCreateArgs returns the gcloud arguments creating the TF SA with its metadata
+func serviceAccountCreateArgs(cfg *Config) []string {
+	args := []string{"iam", "service-accounts", "create", cfg.TFServiceAccountName, "--display-name", "Terraform Admin Service Account"}
+	if description := cfg.TFServiceAccountMetadata.description(); description != "" {
+		args = append(args, "--description", description)
+	}
+	return append(args, "--project", cfg.seedProject())
+}
+
+func grantIAMRoles(ctx context.Context, cfg *Config) error {
 	logInfo("Granting IAM roles to '%s'...", cfg.TFServiceAccountEmail)
 	member := fmt.Sprintf("serviceAccount:%s", cfg.TFServiceAccountEmail)
+	failed := 0
+
+	if len(cfg.TFServiceAccountProjectRoles) > 0 {
+		if err := snapshotProjectIAM(ctx, cfg.ProjectID); err != nil {
+			return err
+		}
+	}
 
 	// Grant project roles
 	for _, role := range cfg.TFServiceAccountProjectRoles {
 		logInfo("Granting project role '%s'...", role)
-		err := runCommand("gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID,
+		err := runCommand(ctx, "gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID,
 			"--member", member,
 			"--role", role,
 			"--condition=None") // Explicitly set no condition
 		// Don't fail immediately, just log warning, maybe role was already granted
 		if err != nil {
 			logWarning("Failed to grant project role %s (may already exist or permissions issue): %v", role, err)
+			failed++
 		}
 	}
 
 	// Grant billing role
 	if cfg.TFServiceAccountBillingRole != "" {
 		logInfo("Granting billing role '%s'...", cfg.TFServiceAccountBillingRole)
-		err := runCommand("gcloud", "beta", "billing", "accounts", "add-iam-policy-binding", cfg.BillingAccountID,
+		err := runCommand(ctx, "gcloud", "beta", "billing", "accounts", "add-iam-policy-binding", cfg.BillingAccountID,
 			"--member", member,
 			"--role", cfg.TFServiceAccountBillingRole)
 		if err != nil {
 			logWarning("Failed to grant billing role %s (may already exist or permissions issue): %v", cfg.TFServiceAccountBillingRole, err)
+			failed++
 		}
 	}
 
+	if failed > 0 && cfg.Strict {
+		return fmt.Errorf("%d IAM role binding(s) failed", failed)
+	}
 	logInfo("IAM role granting process completed (check warnings above).")
 	return nil // Return nil even if some bindings failed, as they might already exist
 }
 
-func bucketExists(bucketName, projectID string) (bool, error) {
-	_, err := runCommandGetOutput("gcloud", "storage", "buckets", "describe", fmt.Sprintf("gs://%s", bucketName), "--project", projectID)
+// setupOpsServiceAccount creates the optional read-only observability SA, grants its
+// project roles and allows the configured WIF principal to impersonate it
+func setupOpsServiceAccount(ctx context.Context, cfg *Config) error {
+	ops := cfg.OpsServiceAccount
+	if !ops.Enabled {
+		logInfo("Skipping ops service account setup as per config.")
+		return nil
+	}
+	logInfo("Attempting to create ops service account '%s'...", ops.Email)
+	err := runCommand(ctx, "gcloud", "iam", "service-accounts", "create", ops.Name,
+		"--display-name", "Ops Observability Service Account",
+		"--project", cfg.ProjectID)
+	if err != nil {
+		if !strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("failed to create ops service account: %w", err)
+		}
+		logWarning("Service account '%s' already exists. Continuing...", ops.Name)
+		metrics.recordResource("service_account", ops.Email, resourceExisted)
+	} else {
+		logInfo("Service account '%s' created.", ops.Email)
+		metrics.recordResource("service_account", ops.Email, resourceCreated)
+		if err := waitForPropagation(ctx, "ops service account propagation", serviceAccountPropagationDelay); err != nil {
+			return err
+		}
+	}
+
+	if err := snapshotProjectIAM(ctx, cfg.ProjectID); err != nil {
+		return err
+	}
+	member := fmt.Sprintf("serviceAccount:%s", ops.Email)
+	for _, role := range ops.Roles {
+		logInfo("Granting project role '%s' to ops service account...", role)
+		err := runCommand(ctx, "gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID,
+			"--member", member,
+			"--role", role,
+			"--condition=None")
+		if err != nil {
+			logWarning("Failed to grant project role %s (may already exist or permissions issue): %v", role, err)
+		}
+	}
+
+	if ops.WIFPrincipal != "" {
+		logInfo("Allowing '%s' to impersonate the ops service account...", ops.WIFPrincipal)
+		err := runCommand(ctx, "gcloud", "iam", "service-accounts", "add-iam-policy-binding", ops.Email,
+			"--member", ops.WIFPrincipal,
+			"--role", "roles/iam.workloadIdentityUser",
+			"--project", cfg.ProjectID)
+		if err != nil {
+			return fmt.Errorf("failed to bind WIF principal to ops service account: %w", err)
+		}
+	}
+
+	logInfo("Ops service account setup completed.")
+	return nil
+}
+
+func bucketExists(ctx context.Context, bucketName, projectID string) (bool, error) {
+	_, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", fmt.Sprintf("gs://%s", bucketName), "--project", projectID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			return false, nil
@@ -201,45 +558,290 @@ func bucketExists(bucketName, projectID string) (bool, error) {
 	return true, nil
 }
 
-func createBucket(cfg *Config) error {
+// bucketLocation returns the location of an existing bucket, or "" if it does not exist
+func bucketLocation(ctx context.Context, bucketName, projectID string) (string, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", fmt.Sprintf("gs://%s", bucketName), "--format=value(location)", "--project", projectID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to describe bucket location: %w", err)
+	}
+	return output, nil
+}
+
+func createBucket(ctx context.Context, cfg *Config) error {
+	if cfg.bucketNamePending() {
+		return fmt.Errorf("the state bucket name '%s' needs the project number, which is resolved by the project step", cfg.TFStateBucketName)
+	}
 	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
 	logInfo("Attempting to create GCS bucket '%s'...", bucketURL)
-	exists, err := bucketExists(cfg.TFStateBucketName, cfg.ProjectID)
+	exists, err := bucketExists(ctx, cfg.TFStateBucketName, cfg.seedProject())
 	if err != nil {
 		return err
 	}
 	if exists {
 		logInfo("GCS bucket '%s' already exists.", bucketURL)
-		return nil
+		metrics.recordResource("bucket", bucketURL, resourceExisted)
+		checkBucketStorageClass(ctx, cfg)
+		if err := ensureUniformBucketLevelAccess(ctx, cfg); err != nil {
+			return err
+		}
+		if err := ensurePublicAccessPrevention(ctx, cfg); err != nil {
+			return err
+		}
+		if err := ensureBucketSoftDelete(ctx, cfg); err != nil {
+			return err
+		}
+		if err := ensureBucketAutoclass(ctx, cfg); err != nil {
+			return err
+		}
+		return ensureBucketLabels(ctx, cfg)
 	}
 
-	err = runCommand("gcloud", "storage", "buckets", "create", bucketURL,
-		"--project", cfg.ProjectID,
-		"--location", cfg.ProjectRegion,
-		"--uniform-bucket-level-access")
+	err = runCommand(ctx, "gcloud", bucketCreateArgs(cfg)...)
 	if err != nil {
 		if strings.Contains(err.Error(), "already exists") {
 			logWarning("Bucket creation failed because bucket '%s' already exists (likely race condition or failed check). Continuing...", bucketURL)
+			metrics.recordResource("bucket", bucketURL, resourceExisted)
 			return nil // Treat as non-fatal
 		}
 		return fmt.Errorf("failed to create GCS bucket: %w", err)
 	}
 	logInfo("GCS bucket '%s' created.", bucketURL)
+	metrics.recordResource("bucket", bucketURL, resourceCreated)
+	return ensureBucketLabels(ctx, cfg)
+}
+
+// bucketCreateArgs returns the gcloud arguments creating the state bucket
+func bucketCreateArgs(cfg *Config) []string {
+	args := []string{"storage", "buckets", "create", fmt.Sprintf("gs://%s", cfg.TFStateBucketName),
+		"--project", cfg.seedProject(),
+		"--location", cfg.TFStateBucketLocation,
+		"--uniform-bucket-level-access",
+		"--public-access-prevention"}
+	if cfg.TFStateBucketStorageClass != "" {
+		args = append(args, "--default-storage-class", cfg.TFStateBucketStorageClass)
+	}
+	if cfg.TFStateBucketAutoclass {
+		args = append(args, "--enable-autoclass")
+	}
+	if cfg.TFStateBucketSoftDelete != "" {
+		args = append(args, "--soft-delete-duration", softDeleteArg(cfg.TFStateBucketSoftDelete))
+	}
+	return args
+}
+
+// checkBucketStorageClass warns if an existing state bucket's default storage class differs
+// from config. Only new objects would pick up a changed class, so it is not updated.
+func checkBucketStorageClass(ctx context.Context, cfg *Config) {
+	if cfg.TFStateBucketStorageClass == "" || cfg.TFStateBucketAutoclass {
+		return
+	}
+	class, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", fmt.Sprintf("gs://%s", cfg.TFStateBucketName), "--format=value(default_storage_class)", "--project", cfg.seedProject())
+	if err != nil {
+		logWarning("Could not check the storage class of 'gs://%s': %v", cfg.TFStateBucketName, err)
+		return
+	}
+	if !strings.EqualFold(class, cfg.TFStateBucketStorageClass) {
+		logWarning("Bucket 'gs://%s' has default storage class '%s' but config expects '%s'; change it with 'gcloud storage buckets update --default-storage-class' if intended.", cfg.TFStateBucketName, class, cfg.TFStateBucketStorageClass)
+	}
+}
+
+// ensurePublicAccessPrevention enforces public access prevention on an existing state bucket.
+// If it cannot be enforced, e.g. because an organization policy or missing permission
+// blocks the update, a warning is logged instead (an error with strict).
+func ensurePublicAccessPrevention(ctx context.Context, cfg *Config) error {
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	pap, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", bucketURL, "--format=value(public_access_prevention)", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to read public access prevention of '%s': %w", bucketURL, err)
+	}
+	if pap == "enforced" {
+		logInfo("Public access prevention is enforced on '%s'.", bucketURL)
+		return nil
+	}
+	logInfo("Public access prevention on '%s' is '%s', enforcing it...", bucketURL, pap)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL, "--public-access-prevention", "--project", cfg.seedProject())
+	if err != nil {
+		err = fmt.Errorf("could not enforce public access prevention on '%s' (check organization policies and storage.buckets.update): %w", bucketURL, err)
+		if cfg.Strict {
+			return err
+		}
+		logWarning("%v", err)
+		return nil
+	}
+	logInfo("Public access prevention enforced.")
 	return nil
 }
 
-func isVersioningEnabled(bucketName, projectID string) (bool, error) {
-	output, err := runCommandGetOutput("gcloud", "storage", "buckets", "describe", fmt.Sprintf("gs://%s", bucketName), "--format=value(versioning.enabled)", "--project", projectID)
+// ensureBucketAutoclass enables Autoclass on an existing state bucket if configured. A bucket
+// with Autoclass is left as is when the option is off, so it is never disabled implicitly.
+func ensureBucketAutoclass(ctx context.Context, cfg *Config) error {
+	if !cfg.TFStateBucketAutoclass {
+		return nil
+	}
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	enabled, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", bucketURL, "--format=value(autoclass.enabled)", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to read Autoclass of '%s': %w", bucketURL, err)
+	}
+	if strings.EqualFold(enabled, "true") {
+		logInfo("Autoclass is enabled on '%s'.", bucketURL)
+		return nil
+	}
+	logInfo("Enabling Autoclass on '%s'...", bucketURL)
+	if err := runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL, "--enable-autoclass", "--project", cfg.seedProject()); err != nil {
+		return fmt.Errorf("failed to enable Autoclass: %w", err)
+	}
+	return nil
+}
+
+// softDeletePattern matches gcloud durations such as 7d, 2w or 1w3d
+var softDeletePattern = regexp.MustCompile(`^([0-9]+[wdhms])+$`)
+
+// Bounds GCS enforces on a non-zero soft delete duration
+const (
+	minSoftDeleteSeconds = 7 * 24 * 3600
+	maxSoftDeleteSeconds = 90 * 24 * 3600
+)
+
+// softDeleteSeconds parses a soft delete duration; "0" disables soft delete
+func softDeleteSeconds(d string) (int64, error) {
+	if d == "0" {
+		return 0, nil
+	}
+	if !softDeletePattern.MatchString(d) {
+		return 0, fmt.Errorf("must be a duration like 7d or 2w, or 0 to disable, got '%s'", d)
+	}
+	units := map[byte]int64{'w': 7 * 24 * 3600, 'd': 24 * 3600, 'h': 3600, 'm': 60, 's': 1}
+	var total, n int64
+	for i := 0; i < len(d); i++ {
+		if c := d[i]; c >= '0' && c <= '9' {
+			n = n*10 + int64(c-'0')
+		} else {
+			total, n = total+n*units[c], 0
+		}
+	}
+	if total != 0 && (total < minSoftDeleteSeconds || total > maxSoftDeleteSeconds) {
+		return 0, fmt.Errorf("must be 0 or between 7d and 90d, got '%s'", d)
+	}
+	return total, nil
+}
+
+// softDeleteArg renders the configured duration for --soft-delete-duration
+func softDeleteArg(d string) string {
+	if d == "0" {
+		return "0s"
+	}
+	return d
+}
+
+// ensureBucketSoftDelete sets the configured soft delete duration on an existing state
+// bucket, clearing the policy when it is 0
+func ensureBucketSoftDelete(ctx context.Context, cfg *Config) error {
+	if cfg.TFStateBucketSoftDelete == "" {
+		return nil
+	}
+	want, err := softDeleteSeconds(cfg.TFStateBucketSoftDelete)
+	if err != nil {
+		return err
+	}
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", bucketURL, "--format=value(soft_delete_policy.retentionDurationSeconds)", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to read the soft delete policy of '%s': %w", bucketURL, err)
+	}
+	current := int64(0)
+	if output != "" {
+		if current, err = strconv.ParseInt(output, 10, 64); err != nil {
+			return fmt.Errorf("unexpected soft delete duration '%s' on '%s'", output, bucketURL)
+		}
+	}
+	if current == want {
+		logInfo("Soft delete duration of '%s' is up to date.", bucketURL)
+		return nil
+	}
+	args := []string{"storage", "buckets", "update", bucketURL, "--soft-delete-duration", cfg.TFStateBucketSoftDelete, "--project", cfg.seedProject()}
+	if want == 0 {
+		logInfo("Disabling soft delete on '%s' (was %ds)...", bucketURL, current)
+		args = []string{"storage", "buckets", "update", bucketURL, "--clear-soft-delete", "--project", cfg.seedProject()}
+	} else {
+		logInfo("Setting soft delete duration of '%s' to %s (was %ds)...", bucketURL, cfg.TFStateBucketSoftDelete, current)
+	}
+	if err := runCommand(ctx, "gcloud", args...); err != nil {
+		return fmt.Errorf("failed to update the soft delete policy: %w", err)
+	}
+	return nil
+}
+
+// ensureBucketLabels adds or updates the configured labels on the state bucket. Labels not
+// in config are left alone, so labels managed elsewhere survive re-runs.
+func ensureBucketLabels(ctx context.Context, cfg *Config) error {
+	if len(cfg.TFStateBucketLabels) == 0 {
+		return nil
+	}
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", bucketURL, "--format=json(labels)", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to read labels of '%s': %w", bucketURL, err)
+	}
+	var current struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.Unmarshal([]byte(output), &current); err != nil {
+		return fmt.Errorf("failed to parse labels of '%s': %w", bucketURL, err)
+	}
+	updates := labelUpdates(current.Labels, cfg.TFStateBucketLabels)
+	if len(updates) == 0 {
+		logInfo("Bucket labels are up to date.")
+		return nil
+	}
+	logInfo("Setting labels %s on '%s'...", strings.Join(updates, ", "), bucketURL)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL,
+		"--update-labels", strings.Join(updates, ","),
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to set labels on '%s': %w", bucketURL, err)
+	}
+	return nil
+}
+
+// grantBucketRole grants the Terraform SA tf_state_bucket_sa_role on the state bucket, so it
+// can use the backend without a storage role on the whole project
+func grantBucketRole(ctx context.Context, cfg *Config) error {
+	if cfg.TFStateBucketSARole == "" {
+		logInfo("Skipping state bucket IAM grant as per config.")
+		return nil
+	}
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	logInfo("Granting '%s' on '%s' to the Terraform service account...", cfg.TFStateBucketSARole, bucketURL)
+	err := runCommand(ctx, "gcloud", "storage", "buckets", "add-iam-policy-binding", bucketURL,
+		"--member", "serviceAccount:"+cfg.TFServiceAccountEmail,
+		"--role", cfg.TFStateBucketSARole,
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to grant '%s' on the state bucket: %w", cfg.TFStateBucketSARole, err)
+	}
+	logInfo("Granted '%s' on the state bucket.", cfg.TFStateBucketSARole)
+	if slices.Contains(cfg.TFServiceAccountProjectRoles, "roles/storage.admin") {
+		logNotice("tf_service_account_project_roles still grants roles/storage.admin on the whole project; remove it if Terraform only needs the state bucket.")
+	}
+	return nil
+}
+
+func isVersioningEnabled(ctx context.Context, bucketName, projectID string) (bool, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", fmt.Sprintf("gs://%s", bucketName), "--format=value(versioning.enabled)", "--project", projectID)
 	if err != nil {
 		return false, fmt.Errorf("failed to check bucket versioning: %w", err)
 	}
 	return strings.ToLower(output) == "true", nil
 }
 
-func enableBucketVersioning(cfg *Config) error {
+func enableBucketVersioning(ctx context.Context, cfg *Config) error {
 	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
 	logInfo("Enabling versioning on GCS bucket '%s'...", bucketURL)
-	enabled, err := isVersioningEnabled(cfg.TFStateBucketName, cfg.ProjectID)
+	enabled, err := isVersioningEnabled(ctx, cfg.TFStateBucketName, cfg.seedProject())
 	if err != nil {
 		return err
 	}
@@ -248,7 +850,7 @@ func enableBucketVersioning(cfg *Config) error {
 		return nil
 	}
 
-	err = runCommand("gcloud", "storage", "buckets", "update", bucketURL, "--versioning", "--project", cfg.ProjectID)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL, "--versioning", "--project", cfg.seedProject())
 	if err != nil {
 		return fmt.Errorf("failed to enable versioning: %w", err)
 	}
@@ -256,11 +858,17 @@ func enableBucketVersioning(cfg *Config) error {
 	return nil
 }
 
-func generateSAKey(cfg *Config) error {
+func generateSAKey(ctx context.Context, cfg *Config) error {
 	if !cfg.GenerateTFSAKey {
 		logInfo("Skipping service account key generation as per config.")
 		return nil
 	}
+	if cfg.SAKeyMode == saKeyModeUpload {
+		return uploadSAKey(ctx, cfg)
+	}
+	if cfg.keyInKeychain() {
+		return generateSAKeyToKeychain(ctx, cfg)
+	}
 	logInfo("Generating service account key...")
 	// Ensure the target directory exists if TFSAKeyPath includes directories
 	keyDir := filepath.Dir(cfg.TFSAKeyPath)
@@ -268,14 +876,86 @@ func generateSAKey(cfg *Config) error {
 		return fmt.Errorf("failed to create directory for SA key '%s': %w", keyDir, err)
 	}
 
-	err := runCommand("gcloud", "iam", "service-accounts", "keys", "create", cfg.TFSAKeyPath,
+	// Make the file owner-only before gcloud writes the key into it, so it is never readable by others
+	_, statErr := os.Stat(cfg.TFSAKeyPath)
+	created := os.IsNotExist(statErr)
+	if created {
+		f, err := os.OpenFile(cfg.TFSAKeyPath, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create SA key file '%s': %w", cfg.TFSAKeyPath, err)
+		}
+		f.Close()
+	} else if err := os.Chmod(cfg.TFSAKeyPath, 0600); err != nil {
+		return fmt.Errorf("failed to restrict permissions of SA key '%s': %w", cfg.TFSAKeyPath, err)
+	}
+	err := runCommand(ctx, "gcloud", "iam", "service-accounts", "keys", "create", cfg.TFSAKeyPath,
 		"--iam-account", cfg.TFServiceAccountEmail,
-		"--project", cfg.ProjectID)
+		"--project", cfg.seedProject())
 	if err != nil {
+		if created {
+			os.Remove(cfg.TFSAKeyPath)
+		}
 		return fmt.Errorf("failed to generate service account key: %w", err)
 	}
+	// gcloud may replace the file rather than write into it, so enforce the mode again
+	if err := os.Chmod(cfg.TFSAKeyPath, 0600); err != nil {
+		return fmt.Errorf("failed to restrict permissions of SA key '%s': %w", cfg.TFSAKeyPath, err)
+	}
+	metrics.recordResource("service_account_key", cfg.TFSAKeyPath, resourceCreated)
 	logWarning("Service account key saved to '%s'. HANDLE THIS FILE SECURELY!", cfg.TFSAKeyPath)
-	logWarning("Consider adding it to .gitignore if not already done.")
+	if err := ensureGitIgnored(ctx, cfg, cfg.TFSAKeyPath); err != nil {
+		return err
+	}
 	logWarning("Using Workload Identity Federation is recommended over keys for CI/CD.")
 	return nil
 }
+
+// uploadSAKey uploads the user's public key to the TF SA, for organizations that forbid
+// Google-generated private keys. The private key never leaves the user's machine.
+func uploadSAKey(ctx context.Context, cfg *Config) error {
+	logInfo("Uploading public key '%s' to service account...", cfg.TFSAPublicKey)
+	if _, err := os.Stat(cfg.TFSAPublicKey); err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "iam", "service-accounts", "keys", "upload", cfg.TFSAPublicKey,
+		"--iam-account", cfg.TFServiceAccountEmail,
+		"--project", cfg.seedProject(),
+		"--format=value(name)")
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "ALREADY_EXISTS") {
+			logInfo("Public key '%s' is already uploaded.", cfg.TFSAPublicKey)
+			metrics.recordResource("service_account_key_upload", cfg.TFSAPublicKey, resourceExisted)
+			return nil
+		}
+		return fmt.Errorf("failed to upload service account key: %w", err)
+	}
+	logInfo("Uploaded key '%s'.", filepath.Base(output))
+	metrics.recordResource("service_account_key_upload", cfg.TFSAPublicKey, resourceCreated)
+	return nil
+}
+
+// googleAPIRequest sends a request to a Google API that gcloud has no command for,
+// authenticated with gcloud's access token, and returns the status and body of the response
+func googleAPIRequest(ctx context.Context, method, endpoint string, body []byte) (int, []byte, error) {
+	token, err := runCommandGetOutput(ctx, "gcloud", "auth", "print-access-token")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get an access token: %w", err)
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, bytes.TrimSpace(data), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Names of the GitHub Actions secrets and variables written by the github_secrets step
+const (
+	githubSecretCredentials = "GOOGLE_CREDENTIALS"
+	githubVariableProvider  = "GCP_WORKLOAD_IDENTITY_PROVIDER"
+	githubVariableSA        = "GCP_SERVICE_ACCOUNT"
+)
+
+// pushGitHubSecrets uploads the SA key as a repository secret and the WIF provider and SA
+// email as repository variables using the gh CLI, then removes the local key file
+func pushGitHubSecrets(ctx context.Context, cfg *Config) error {
+	gs := cfg.GitHubSecrets
+	if !gs.Enabled {
+		logInfo("Skipping GitHub secrets upload as per config.")
+		return nil
+	}
+	if cfg.writesPrivateKey() {
+		key, err := readSAKey(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if err := runGitHubCLI(ctx, gs.Token, key, "secret", "set", githubSecretCredentials, "--repo", gs.Repository); err != nil {
+			return fmt.Errorf("failed to set secret %s on '%s': %w", githubSecretCredentials, gs.Repository, err)
+		}
+		metrics.recordResource("github_secret", gs.Repository+"/"+githubSecretCredentials, resourceCreated)
+		if !gs.KeepLocalKey && !cfg.keyInKeychain() {
+			if err := os.Remove(cfg.TFSAKeyPath); err != nil {
+				return fmt.Errorf("failed to remove local SA key '%s': %w", cfg.TFSAKeyPath, err)
+			}
+			logInfo("Removed local SA key '%s' after uploading it.", cfg.TFSAKeyPath)
+		}
+	}
+	if cfg.WIF.GitHub.Enabled {
+		number, err := projectNumber(ctx, cfg.seedProject())
+		if err != nil {
+			return err
+		}
+		provider := workloadIdentityProviderName(cfg, number, cfg.WIF.GitHub.ProviderID)
+		if err := setGitHubVariable(ctx, gs, githubVariableProvider, provider); err != nil {
+			return err
+		}
+	}
+	return setGitHubVariable(ctx, gs, githubVariableSA, cfg.TFServiceAccountEmail)
+}
+
+// setGitHubVariable sets a GitHub Actions repository variable
+func setGitHubVariable(ctx context.Context, gs GitHubSecretsConfig, name, value string) error {
+	if err := runGitHubCLI(ctx, gs.Token, []byte(value), "variable", "set", name, "--repo", gs.Repository); err != nil {
+		return fmt.Errorf("failed to set variable %s on '%s': %w", name, gs.Repository, err)
+	}
+	metrics.recordResource("github_variable", gs.Repository+"/"+name, resourceCreated)
+	return nil
+}
+
+// runGitHubCLI runs gh with input on stdin, so secret values never appear on the
+// command line or in logs. A non-empty token is passed as GH_TOKEN.
+func runGitHubCLI(ctx context.Context, token string, input []byte, args ...string) error {
+	commandLine := "gh " + strings.Join(args, " ")
+	logAt(slog.LevelInfo, "Executing: "+commandLine, slog.String("command", commandLine))
+	var env []string
+	if token != "" {
+		env = []string{"GH_TOKEN=" + token}
+	}
+	output, errOutput, err := execWithInput(ctx, env, input, "gh", args...)
+	if ctx.Err() != nil {
+		return fmt.Errorf("command cancelled: %s: %w", commandLine, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("command failed: %s: %w\nOutput: %s", commandLine, err, strings.TrimSpace(string(output)+string(errOutput)))
+	}
+	logInfo("Command finished successfully.")
+	return nil
+}
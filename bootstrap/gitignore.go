@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findGitRoot returns the nearest directory at or above dir containing .git, or "" if none
+func findGitRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ensureGitIgnored makes sure path is covered by the .gitignore of the git repository
+// containing it, appending an entry after confirmation (or directly with --yes)
+func ensureGitIgnored(ctx context.Context, cfg *Config, path string) error {
+	root := findGitRoot(filepath.Dir(path))
+	if root == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+
+	ignored, err := gitCheckIgnore(ctx, root, rel)
+	if err != nil {
+		logWarning("Could not check whether '%s' is git-ignored (%v); make sure it is never committed.", rel, err)
+		return nil
+	}
+	if ignored {
+		logInfo("'%s' is covered by .gitignore.", rel)
+		return nil
+	}
+
+	gitignore := filepath.Join(root, ".gitignore")
+	if !cfg.AssumeYes {
+		fmt.Fprintf(stdout, "The key '%s' is not covered by '%s'. Add it? (yes/no): ", rel, gitignore)
+		input, err := readConfirmation(ctx)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(strings.ToLower(input)) != "yes" {
+			logWarning("Not adding '%s' to .gitignore. Make sure the key is never committed!", rel)
+			return nil
+		}
+	}
+	entry := "/" + rel + "\n"
+	if data, err := os.ReadFile(gitignore); err == nil && len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		entry = "\n" + entry
+	}
+	f, err := os.OpenFile(gitignore, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", gitignore, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(entry); err != nil {
+		return fmt.Errorf("failed to update '%s': %w", gitignore, err)
+	}
+	logInfo("Added '/%s' to '%s'.", rel, gitignore)
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runIDFormat derives run IDs from the run start time
+const runIDFormat = "20060102-150405"
+
+// defaultHistoryDir is where per-run reports are stored unless --history-dir says otherwise
+const defaultHistoryDir = ".gcp-bootstrap/history"
+
+// saveRunHistory stores the run report in dir as <run-id>.json
+func saveRunHistory(dir string, r *runReport) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory '%s': %w", dir, err)
+	}
+	path := filepath.Join(dir, r.RunID+".json")
+	// Parallel runs ('bulk -parallel') can start in the same second; claim the file so they
+	// don't overwrite each other, falling back to a run ID with the project appended
+	if f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err == nil {
+		f.Close()
+	} else if errors.Is(err, os.ErrExist) {
+		r.RunID += "-" + r.ProjectID
+		path = filepath.Join(dir, r.RunID+".json")
+	}
+	if err := writeReport(path, r); err != nil {
+		return err
+	}
+	logInfo("Run '%s' recorded in history.", r.RunID)
+	return nil
+}
+
+// runHistoryCommand implements 'history list' and 'history diff <run1> <run2>'
+func runHistoryCommand(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	historyDir := fs.String("history-dir", defaultHistoryDir, "Directory containing stored run reports")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap history [-history-dir DIR] list")
+		fmt.Fprintln(fs.Output(), "       gcp-bootstrap history [-history-dir DIR] diff <run1> <run2>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	switch fs.Arg(0) {
+	case "list":
+		if err := listHistory(*historyDir); err != nil {
+			logError("%v", err)
+		}
+	case "diff":
+		if fs.NArg() != 3 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		a, err := loadHistoryRun(*historyDir, fs.Arg(1))
+		if err != nil {
+			logError("%v", err)
+		}
+		b, err := loadHistoryRun(*historyDir, fs.Arg(2))
+		if err != nil {
+			logError("%v", err)
+		}
+		diffRuns(a, b)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// loadHistoryRun loads a stored run by ID (or by path to a report file)
+func loadHistoryRun(dir, id string) (*runReport, error) {
+	if strings.HasSuffix(id, ".json") {
+		return readReport(id)
+	}
+	return readReport(filepath.Join(dir, id+".json"))
+}
+
+// listHistory prints the stored runs, oldest first
+func listHistory(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list history in '%s': %w", dir, err)
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(stdout, "No runs recorded in '%s'.\n", dir)
+		return nil
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		r, err := readReport(p)
+		if err != nil {
+			logWarning("%v", err)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s  %-11s  %-30s  %8s  config %s\n", r.RunID, r.Status, r.ProjectID, formatSeconds(r.DurationSeconds), shortHash(r.ConfigHash))
+	}
+	return nil
+}
+
+// diffRuns prints what changed between two runs: outcome, config, resources, steps and warnings
+func diffRuns(a, b *runReport) {
+	fmt.Fprintf(stdout, "Comparing run %s -> %s\n", a.RunID, b.RunID)
+	diffField("Status", a.Status, b.Status)
+	diffField("Project", a.ProjectID, b.ProjectID)
+	diffField("Config hash", shortHash(a.ConfigHash), shortHash(b.ConfigHash))
+	fmt.Fprintf(stdout, "  Duration:    %s -> %s (%s)\n", formatSeconds(a.DurationSeconds), formatSeconds(b.DurationSeconds), formatDelta(b.DurationSeconds-a.DurationSeconds))
+
+	fmt.Fprintln(stdout, "Resources:")
+	before := map[string]string{}
+	for _, res := range a.Resources {
+		before[res.Kind+" "+res.Name] = res.Status
+	}
+	after := map[string]string{}
+	for _, res := range b.Resources {
+		after[res.Kind+" "+res.Name] = res.Status
+	}
+	changed := false
+	for _, key := range sortedUnion(before, after) {
+		x, inA := before[key]
+		y, inB := after[key]
+		switch {
+		case !inA:
+			fmt.Fprintf(stdout, "  + %s (%s)\n", key, y)
+		case !inB:
+			fmt.Fprintf(stdout, "  - %s (%s)\n", key, x)
+		case x != y:
+			fmt.Fprintf(stdout, "  ~ %s: %s -> %s\n", key, x, y)
+		default:
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		fmt.Fprintln(stdout, "  (no changes)")
+	}
+
+	fmt.Fprintln(stdout, "Steps:")
+	stepsA := map[string]reportStep{}
+	for _, s := range a.Steps {
+		stepsA[s.ID] = s
+	}
+	seen := map[string]bool{}
+	for _, s := range b.Steps {
+		seen[s.ID] = true
+		prev, ok := stepsA[s.ID]
+		if !ok {
+			fmt.Fprintf(stdout, "  + %-28s %-9s %s\n", s.ID, s.Status, formatSeconds(s.DurationSeconds))
+			continue
+		}
+		status := s.Status
+		if prev.Status != s.Status {
+			status = prev.Status + " -> " + s.Status
+		}
+		fmt.Fprintf(stdout, "    %-28s %-21s %s -> %s (%s)\n", s.ID, status, formatSeconds(prev.DurationSeconds), formatSeconds(s.DurationSeconds), formatDelta(s.DurationSeconds-prev.DurationSeconds))
+	}
+	for _, s := range a.Steps {
+		if !seen[s.ID] {
+			fmt.Fprintf(stdout, "  - %-28s %-9s (not run)\n", s.ID, s.Status)
+		}
+	}
+
+	fmt.Fprintf(stdout, "Warnings: %d -> %d\n", len(a.Warnings), len(b.Warnings))
+}
+
+// diffField prints a field, highlighting it when it changed between runs
+func diffField(label, a, b string) {
+	if a == b {
+		fmt.Fprintf(stdout, "  %-12s %s (unchanged)\n", label+":", a)
+		return
+	}
+	fmt.Fprintf(stdout, "  %-12s %s -> %s\n", label+":", a, colorize(colorYellow, b))
+}
+
+// sortedUnion returns the sorted keys present in either map
+func sortedUnion(a, b map[string]string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatSeconds renders a duration in seconds for display
+func formatSeconds(s float64) string {
+	return (time.Duration(s * float64(time.Second))).Round(100 * time.Millisecond).String()
+}
+
+// formatDelta renders a signed duration difference in seconds
+func formatDelta(s float64) string {
+	if s < 0 && formatSeconds(-s) != formatSeconds(0) {
+		return "-" + formatSeconds(-s)
+	}
+	return "+" + formatSeconds(max(s, 0))
+}
+
+// shortHash abbreviates a config hash for display
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
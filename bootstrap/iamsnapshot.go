@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultIAMSnapshotDir is where project IAM policies are saved before the bootstrap changes them
+const defaultIAMSnapshotDir = ".gcp-bootstrap/iam-snapshots"
+
+// iamSnapshotDir is set from --iam-snapshot-dir; empty disables snapshots
+var iamSnapshotDir = defaultIAMSnapshotDir
+
+// iamSnapshotted records the projects whose policy was already saved during this run, so only
+// the policy from before the run's first change is kept
+var iamSnapshotted = map[string]bool{}
+
+// grants returns the policy's bindings as "role member" strings, with the condition title if any
+func (p iamPolicy) grants() []string {
+	var grants []string
+	for _, b := range p.Bindings {
+		for _, m := range b.Members {
+			g := b.Role + " " + m
+			if b.Condition != nil {
+				g += fmt.Sprintf(" (condition '%s')", b.Condition.Title)
+			}
+			grants = append(grants, g)
+		}
+	}
+	slices.Sort(grants)
+	return grants
+}
+
+// snapshotProjectIAM saves the current IAM policy of projectID before the run first changes it
+func snapshotProjectIAM(ctx context.Context, projectID string) error {
+	if iamSnapshotDir == "" || iamSnapshotted[projectID] {
+		return nil
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "get-iam-policy", projectID, "--format=json")
+	if err != nil {
+		return fmt.Errorf("failed to snapshot the IAM policy of '%s': %w", projectID, err)
+	}
+	dir := filepath.Join(iamSnapshotDir, projectID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create IAM snapshot directory '%s': %w", dir, err)
+	}
+	path := filepath.Join(dir, time.Now().UTC().Format(runIDFormat)+".json")
+	if err := os.WriteFile(path, []byte(output+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write IAM snapshot: %w", err)
+	}
+	iamSnapshotted[projectID] = true
+	logInfo("IAM policy of '%s' saved to '%s' ('gcp-bootstrap iam rollback' restores it).", projectID, path)
+	return nil
+}
+
+// runIAMCommand implements 'iam list' and 'iam rollback [SNAPSHOT]', which restores a project
+// IAM policy saved before a bootstrap run changed it
+func runIAMCommand(args []string) {
+	fs := flag.NewFlagSet("iam", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	projectID := fs.String("project", "", "Project whose policy to restore (defaults to project_id from the config, e.g. set it to the fleet host project)")
+	snapshotDir := fs.String("snapshot-dir", defaultIAMSnapshotDir, "Directory containing the IAM policy snapshots")
+	assumeYes := fs.Bool("yes", false, "Skip the typed confirmation")
+	credentialsFile := fs.String("credentials-file", "", "Authenticate gcloud with this credential JSON file instead of the active gcloud account")
+	fakeGCPFlag := fs.Bool("fake-gcp", false, "Run against the in-process fake of GCP (for demos and tests)")
+	fakeGCPState := fs.String("fake-gcp-state", "", "With -fake-gcp, load and save the fake's state in this JSON file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap iam [-config FILE] [-env NAME] [-project ID] [-snapshot-dir DIR] list")
+		fmt.Fprintln(fs.Output(), "       gcp-bootstrap iam [-config FILE] [-project ID] [-snapshot-dir DIR] [-yes] rollback [SNAPSHOT]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *projectID == "" {
+		cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+		if err != nil {
+			logError("Failed to load configuration: %v", err)
+		}
+		if err := resolveExistingProjectID(ctx, cfg); err != nil {
+			logError("%v", err)
+		}
+		*projectID = cfg.ProjectID
+	}
+	dir := filepath.Join(*snapshotDir, *projectID)
+	snapshots, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		logError("Failed to list IAM snapshots in '%s': %v", dir, err)
+	}
+	slices.Sort(snapshots)
+
+	switch fs.Arg(0) {
+	case "list":
+		if len(snapshots) == 0 {
+			logNotice("No IAM snapshots of '%s' in '%s'.", *projectID, dir)
+			return
+		}
+		for _, s := range snapshots {
+			fmt.Fprintln(stdout, strings.TrimSuffix(filepath.Base(s), ".json"))
+		}
+	case "rollback":
+		if fs.NArg() > 2 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		if len(snapshots) == 0 {
+			logError("No IAM snapshots of '%s' in '%s'.", *projectID, dir)
+		}
+		path := snapshots[len(snapshots)-1]
+		if id := fs.Arg(1); id != "" {
+			path = filepath.Join(dir, id+".json")
+		}
+		if *fakeGCPFlag {
+			fake, err := newFakeGCP(*fakeGCPState)
+			if err != nil {
+				logError("%v", err)
+			}
+			fakeGCP = fake
+		}
+		checkGcloud(ctx, *credentialsFile)
+		if err := rollbackProjectIAM(ctx, *projectID, path, *assumeYes); err != nil {
+			logError("%v", err)
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// rollbackProjectIAM shows how the saved policy differs from the current one and, once
+// confirmed, replaces the project's policy with it. The current policy is saved first so
+// the rollback itself can be undone.
+func rollbackProjectIAM(ctx context.Context, projectID, path string, assumeYes bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read IAM snapshot: %w", err)
+	}
+	var saved map[string]any
+	var savedPolicy iamPolicy
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse IAM snapshot '%s': %w", path, err)
+	}
+	if err := json.Unmarshal(data, &savedPolicy); err != nil {
+		return fmt.Errorf("failed to parse IAM snapshot '%s': %w", path, err)
+	}
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "get-iam-policy", projectID, "--format=json")
+	if err != nil {
+		return fmt.Errorf("failed to read the IAM policy of '%s': %w", projectID, err)
+	}
+	var currentPolicy iamPolicy
+	if err := json.Unmarshal([]byte(output), &currentPolicy); err != nil {
+		return fmt.Errorf("failed to parse the IAM policy of '%s': %w", projectID, err)
+	}
+
+	current, want := currentPolicy.grants(), savedPolicy.grants()
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	fmt.Fprintf(stdout, " IAM rollback of '%s' to %s\n", projectID, strings.TrimSuffix(filepath.Base(path), ".json"))
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	changes := 0
+	for _, g := range current {
+		if !slices.Contains(want, g) {
+			fmt.Fprintln(stdout, colorize(colorRed, " - remove "+g))
+			changes++
+		}
+	}
+	for _, g := range want {
+		if !slices.Contains(current, g) {
+			fmt.Fprintln(stdout, colorize(colorGreen, " + restore "+g))
+			changes++
+		}
+	}
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	if changes == 0 {
+		fmt.Fprintln(stdout, " Bindings match the snapshot; audit logging settings are restored as saved.")
+	}
+
+	if assumeYes {
+		logInfo("Confirmation skipped via --yes.")
+	} else {
+		fmt.Fprintf(stdout, "Type the project ID '%s' to replace its IAM policy: ", projectID)
+		input, err := readConfirmation(ctx)
+		if err != nil {
+			exitCancelled(ctx)
+		}
+		if strings.TrimSpace(input) != projectID {
+			logInfo("Aborted by user.")
+			return nil
+		}
+	}
+
+	if err := snapshotProjectIAM(ctx, projectID); err != nil {
+		return err
+	}
+	// Without the snapshot's stale etag, the saved policy replaces whatever is current
+	delete(saved, "etag")
+	policy, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to render the IAM policy: %w", err)
+	}
+	tmp, err := os.CreateTemp("", "iam-policy-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create IAM policy file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(policy)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write IAM policy file: %w", err)
+	}
+	if err := runCommand(ctx, "gcloud", "projects", "set-iam-policy", projectID, tmp.Name(), "--format=none"); err != nil {
+		return fmt.Errorf("failed to restore the IAM policy of '%s': %w", projectID, err)
+	}
+	logNotice("IAM policy of '%s' restored from '%s' (%d binding change(s)).", projectID, path, changes)
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// keychainService is the service name keys are stored under in the OS keychain; the
+// account is the TF SA email
+const keychainService = "gcp-bootstrap"
+
+// keychainKeyKind is the resource kind of a key in the keychain, named <service>/<account>
+const keychainKeyKind = "keychain_key"
+
+// generateSAKeyToKeychain creates a key for the TF SA and stores it in the OS keychain.
+// gcloud can only write the key to a file, so it briefly lives in an owner-only temp file.
+func generateSAKeyToKeychain(ctx context.Context, cfg *Config) error {
+	logInfo("Generating service account key into the OS keychain...")
+	tmp, err := os.CreateTemp("", ".sa-key-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary key file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	err = runCommand(ctx, "gcloud", "iam", "service-accounts", "keys", "create", tmp.Name(),
+		"--iam-account", cfg.TFServiceAccountEmail,
+		"--project", cfg.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to generate service account key: %w", err)
+	}
+	key, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read new key: %w", err)
+	}
+	if err := keychainStore(ctx, cfg.TFServiceAccountEmail, key); err != nil {
+		return err
+	}
+	metrics.recordResource(keychainKeyKind, keychainService+"/"+cfg.TFServiceAccountEmail, resourceCreated)
+	logNotice("Service account key stored in the OS keychain; retrieve it with 'gcp-bootstrap key export'.")
+	logWarning("Using Workload Identity Federation is recommended over keys for CI/CD.")
+	return nil
+}
+
+// readSAKey returns the generated TF SA key from the configured storage
+func readSAKey(ctx context.Context, cfg *Config) ([]byte, error) {
+	if cfg.keyInKeychain() {
+		return keychainLoad(ctx, cfg.TFServiceAccountEmail)
+	}
+	key, err := os.ReadFile(cfg.TFSAKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SA key '%s': %w", cfg.TFSAKeyPath, err)
+	}
+	return key, nil
+}
+
+// runKeyCommand implements 'key': 'key export' prints the TF SA key kept in the OS keychain,
+// e.g. for export GOOGLE_CREDENTIALS="$(gcp-bootstrap key export)"
+func runKeyCommand(args []string) {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, "Usage: gcp-bootstrap key export [-config FILE] [-env NAME] [-out FILE]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("key export", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	out := fs.String("out", "", "Write the key to this file (mode 0600) instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap key export [-config FILE] [-env NAME] [-out FILE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+	if err != nil {
+		logError("Failed to load configuration: %v", err)
+	}
+	if err := resolveExistingProjectID(ctx, cfg); err != nil {
+		logError("%v", err)
+	}
+	if !cfg.keyInKeychain() {
+		logError("key export reads keys from the OS keychain; set generate_tf_sa_key: true and tf_sa_key_storage: %s in %s.", saKeyStorageKeychain, *configPath)
+	}
+	key, err := keychainLoad(ctx, cfg.TFServiceAccountEmail)
+	if err != nil {
+		logError("%v", err)
+	}
+	if !json.Valid(key) {
+		logError("The keychain entry for '%s' is not a JSON key.", cfg.TFServiceAccountEmail)
+	}
+	if *out == "" {
+		os.Stdout.Write(key)
+		return
+	}
+	if err := os.WriteFile(*out, key, 0600); err != nil {
+		logError("Failed to write the key: %v", err)
+	}
+	if err := ensureGitIgnored(ctx, cfg, *out); err != nil {
+		logWarning("%v", err)
+	}
+	logWarning("Service account key written to '%s'. HANDLE THIS FILE SECURELY!", *out)
+}
+
+// runWithInput runs a command with input on stdin, so secrets never appear on the command
+// line or in logs, and returns its trimmed stdout
+func runWithInput(ctx context.Context, input []byte, name string, args ...string) (string, error) {
+	commandLine := name + " " + strings.Join(args, " ")
+	logAt(slog.LevelDebug, "Executing (captured): "+commandLine, slog.String("command", commandLine))
+	output, errOutput, err := execWithInput(ctx, nil, input, name, args...)
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("command cancelled: %s: %w", commandLine, ctx.Err())
+	}
+	if err != nil {
+		return "", fmt.Errorf("command failed: %s: %w\nStderr: %s", commandLine, err, strings.TrimSpace(string(errOutput)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// keychainStore adds or replaces the key in the login keychain. The key is base64 encoded,
+// since 'security' prints multi-line passwords as hex, and passed on stdin via 'security -i'
+// so it never appears in the process list.
+func keychainStore(ctx context.Context, account string, key []byte) error {
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", keychainService, account, base64.StdEncoding.EncodeToString(key))
+	if _, err := runWithInput(ctx, []byte(command), "security", "-i"); err != nil {
+		return fmt.Errorf("failed to store the key in the keychain: %w", err)
+	}
+	return nil
+}
+
+// keychainLoad reads the key stored by keychainStore
+func keychainLoad(ctx context.Context, account string) ([]byte, error) {
+	output, err := runCommandGetOutput(ctx, "security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+	if err != nil {
+		if strings.Contains(err.Error(), "could not be found") {
+			return nil, fmt.Errorf("no key for '%s' in the keychain", account)
+		}
+		return nil, fmt.Errorf("failed to read the key from the keychain: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the keychain entry for '%s': %w", account, err)
+	}
+	return key, nil
+}
+
+// keychainDelete removes the key stored by keychainStore; a missing entry is not an error
+func keychainDelete(ctx context.Context, account string) error {
+	_, err := runCommandGetOutput(ctx, "security", "delete-generic-password", "-s", keychainService, "-a", account)
+	if err != nil && !strings.Contains(err.Error(), "could not be found") {
+		return fmt.Errorf("failed to delete the key from the keychain: %w", err)
+	}
+	return nil
+}
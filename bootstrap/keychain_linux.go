@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// keychainStore adds or replaces the key in the Secret Service keyring (GNOME Keyring,
+// KWallet) with secret-tool, which reads the secret from stdin
+func keychainStore(ctx context.Context, account string, key []byte) error {
+	_, err := runWithInput(ctx, []byte(base64.StdEncoding.EncodeToString(key)), "secret-tool", "store",
+		"--label", "gcp-bootstrap key for "+account,
+		"service", keychainService, "account", account)
+	if err != nil {
+		return fmt.Errorf("failed to store the key in the keyring (is secret-tool installed and a keyring unlocked?): %w", err)
+	}
+	return nil
+}
+
+// keychainLoad reads the key stored by keychainStore
+func keychainLoad(ctx context.Context, account string) ([]byte, error) {
+	// secret-tool exits 1 without output when nothing matches
+	output, err := runWithInput(ctx, nil, "secret-tool", "lookup", "service", keychainService, "account", account)
+	if err == nil && output == "" {
+		return nil, fmt.Errorf("no key for '%s' in the keyring", account)
+	}
+	if err != nil && strings.Contains(err.Error(), "exit status 1") {
+		return nil, fmt.Errorf("no key for '%s' in the keyring: %w", account, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key from the keyring: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the keyring entry for '%s': %w", account, err)
+	}
+	return key, nil
+}
+
+// keychainDelete removes the key stored by keychainStore; secret-tool succeeds when nothing matches
+func keychainDelete(ctx context.Context, account string) error {
+	if _, err := runWithInput(ctx, nil, "secret-tool", "clear", "service", keychainService, "account", account); err != nil {
+		return fmt.Errorf("failed to delete the key from the keyring: %w", err)
+	}
+	return nil
+}
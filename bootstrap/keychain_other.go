@@ -0,0 +1,26 @@
+//go:build !darwin && !linux && !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errNoKeychain is returned on platforms without a supported keychain
+var errNoKeychain = errors.New("tf_sa_key_storage 'keychain' is not supported on this platform")
+
+// keychainStore is unsupported on this platform
+func keychainStore(ctx context.Context, account string, key []byte) error {
+	return errNoKeychain
+}
+
+// keychainLoad is unsupported on this platform
+func keychainLoad(ctx context.Context, account string) ([]byte, error) {
+	return nil, errNoKeychain
+}
+
+// keychainDelete is unsupported on this platform
+func keychainDelete(ctx context.Context, account string) error {
+	return errNoKeychain
+}
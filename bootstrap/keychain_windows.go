@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Credential Manager constants from wincred.h
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	credMaxBlobSize         = 5 * 512
+	errorNotFound           = syscall.Errno(1168)
+)
+
+var (
+	advapi32      = syscall.NewLazyDLL("advapi32.dll")
+	procCredWrite = advapi32.NewProc("CredWriteW")
+	procCredRead  = advapi32.NewProc("CredReadW")
+	procCredFree  = advapi32.NewProc("CredFree")
+	procCredDel   = advapi32.NewProc("CredDeleteW")
+)
+
+// credential mirrors the CREDENTIALW struct
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// keychainTarget is the Credential Manager target name of an account's key
+func keychainTarget(account string) string {
+	return keychainService + ":" + account
+}
+
+// keychainStore adds or replaces the key as a generic credential in the Windows
+// Credential Manager. The key is stored as is, since blobs are limited to 2560 bytes.
+func keychainStore(ctx context.Context, account string, key []byte) error {
+	if len(key) > credMaxBlobSize {
+		return fmt.Errorf("the key is %d bytes, more than the %d the Credential Manager can store", len(key), credMaxBlobSize)
+	}
+	target, err := syscall.UTF16PtrFromString(keychainTarget(account))
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(key)),
+		CredentialBlob:     &key[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if ret, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0); ret == 0 {
+		return fmt.Errorf("failed to store the key in the Credential Manager: %w", err)
+	}
+	return nil
+}
+
+// keychainLoad reads the key stored by keychainStore
+func keychainLoad(ctx context.Context, account string) ([]byte, error) {
+	target, err := syscall.UTF16PtrFromString(keychainTarget(account))
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	if ret, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); ret == 0 {
+		if err == errorNotFound {
+			return nil, fmt.Errorf("no key for '%s' in the Credential Manager", account)
+		}
+		return nil, fmt.Errorf("failed to read the key from the Credential Manager: %w", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return append([]byte(nil), unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)...), nil
+}
+
+// keychainDelete removes the key stored by keychainStore; a missing credential is not an error
+func keychainDelete(ctx context.Context, account string) error {
+	target, err := syscall.UTF16PtrFromString(keychainTarget(account))
+	if err != nil {
+		return err
+	}
+	if ret, _, err := procCredDel.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ret == 0 && err != errorNotFound {
+		return fmt.Errorf("failed to delete the key from the Credential Manager: %w", err)
+	}
+	return nil
+}
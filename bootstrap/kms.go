@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KMS defaults for the state bucket's customer-managed encryption key
+const (
+	defaultKMSKeyRing        = "tfstate"
+	defaultKMSKey            = "tfstate"
+	defaultKMSRotationPeriod = 90 * 24 * time.Hour
+)
+
+// kmsLocation returns the Cloud KMS location for keys encrypting the state bucket, which
+// must match the bucket's location
+func kmsLocation(cfg *Config) string {
+	if loc, ok := multiRegionKMSLocations[cfg.TFStateBucketLocation]; ok {
+		return loc
+	}
+	if loc, ok := dualRegionKMSLocations[cfg.TFStateBucketLocation]; ok {
+		return loc
+	}
+	return cfg.TFStateBucketLocation
+}
+
+// kmsKeyName returns the full resource name of the state bucket's KMS key
+func kmsKeyName(cfg *Config) string {
+	return fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s", cfg.seedProject(), kmsLocation(cfg), cfg.KMS.KeyRing, cfg.KMS.Key)
+}
+
+// setupBucketKMS creates a key ring and key in the bucket's location, lets the Cloud
+// Storage service agent use the key and makes it the bucket's default encryption key
+func setupBucketKMS(ctx context.Context, cfg *Config) error {
+	kms := cfg.KMS
+	if !kms.Enabled {
+		logInfo("Skipping state bucket CMEK setup as per config.")
+		return nil
+	}
+	if err := ensureServicesEnabled(ctx, cfg.seedProject(), []string{"cloudkms.googleapis.com"}); err != nil {
+		return fmt.Errorf("failed to enable the Cloud KMS API: %w", err)
+	}
+
+	logInfo("Ensuring KMS key ring '%s' in '%s'...", kms.KeyRing, kmsLocation(cfg))
+	err := runCommand(ctx, "gcloud", "kms", "keyrings", "create", kms.KeyRing,
+		"--location", kmsLocation(cfg),
+		"--project", cfg.seedProject())
+	if err := recordKMSResource(err, "kms_key_ring", kms.KeyRing); err != nil {
+		return fmt.Errorf("failed to create key ring '%s': %w", kms.KeyRing, err)
+	}
+
+	logInfo("Ensuring KMS key '%s'...", kms.Key)
+	err = runCommand(ctx, "gcloud", "kms", "keys", "create", kms.Key,
+		"--keyring", kms.KeyRing,
+		"--location", kmsLocation(cfg),
+		"--purpose", "encryption",
+		"--rotation-period", fmt.Sprintf("%ds", int(kms.RotationPeriod.Seconds())),
+		"--next-rotation-time", time.Now().Add(kms.RotationPeriod).UTC().Format(time.RFC3339),
+		"--project", cfg.seedProject())
+	if err := recordKMSResource(err, "kms_key", kmsKeyName(cfg)); err != nil {
+		return fmt.Errorf("failed to create key '%s': %w", kms.Key, err)
+	}
+
+	agent, err := runCommandGetOutput(ctx, "gcloud", "storage", "service-agent", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to look up the Cloud Storage service agent: %w", err)
+	}
+	logInfo("Granting the Cloud Storage service agent '%s' use of the key...", agent)
+	err = runCommand(ctx, "gcloud", "kms", "keys", "add-iam-policy-binding", kms.Key,
+		"--keyring", kms.KeyRing,
+		"--location", kmsLocation(cfg),
+		"--member", "serviceAccount:"+agent,
+		"--role", "roles/cloudkms.cryptoKeyEncrypterDecrypter",
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to grant the Cloud Storage service agent access to the key: %w", err)
+	}
+
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	logInfo("Setting the default encryption key of '%s'...", bucketURL)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL,
+		"--default-encryption-key", kmsKeyName(cfg),
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to set the default encryption key: %w", err)
+	}
+	logInfo("State bucket encrypted with '%s' by default.", kmsKeyName(cfg))
+	return nil
+}
+
+// recordKMSResource records a KMS create result, treating "already exists" as success
+func recordKMSResource(err error, kind, name string) error {
+	if err == nil {
+		metrics.recordResource(kind, name, resourceCreated)
+		return nil
+	}
+	if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "ALREADY_EXISTS") {
+		logInfo("%s '%s' already exists.", kind, name)
+		metrics.recordResource(kind, name, resourceExisted)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Liens placed by the tool carry this origin so re-runs recognize them
+const (
+	lienOrigin        = "gcp-bootstrap"
+	lienRestriction   = "resourcemanager.projects.delete"
+	defaultLienReason = "Foundation project bootstrapped by gcp-bootstrap; remove this lien before deleting the project"
+)
+
+// projectLien is an entry of 'gcloud alpha resource-manager liens list'
+type projectLien struct {
+	Name         string   `json:"name"`
+	Origin       string   `json:"origin"`
+	Reason       string   `json:"reason"`
+	Restrictions []string `json:"restrictions"`
+}
+
+// placeProjectLien places a lien against deleting the project, so it cannot be deleted
+// until the lien is removed. An existing lien from an earlier run is kept; liens cannot be
+// updated, so a changed reason only applies once the old lien is deleted.
+func placeProjectLien(ctx context.Context, cfg *Config) error {
+	if !cfg.ProjectLien.Enabled {
+		logInfo("Skipping project lien as per config.")
+		return nil
+	}
+	lien, err := findProjectLien(ctx, cfg.ProjectID)
+	if err != nil {
+		return err
+	}
+	if lien != nil {
+		logInfo("Project '%s' already has lien '%s'.", cfg.ProjectID, lien.Name)
+		if lien.Reason != cfg.ProjectLien.Reason {
+			logWarning("Lien '%s' has reason '%s' instead of the configured '%s'; liens cannot be updated, delete it to apply the new reason.", lien.Name, lien.Reason, cfg.ProjectLien.Reason)
+		}
+		metrics.recordResource("lien", lien.Name, resourceExisted)
+		return nil
+	}
+
+	logInfo("Placing a lien against deleting project '%s'...", cfg.ProjectID)
+	if err := runCommand(ctx, "gcloud", "alpha", "resource-manager", "liens", "create",
+		"--project", cfg.ProjectID,
+		"--restrictions", lienRestriction,
+		"--reason", cfg.ProjectLien.Reason,
+		"--origin", lienOrigin); err != nil {
+		return fmt.Errorf("failed to create project lien: %w", err)
+	}
+	// The lien's name is only known from the listing; destroy needs it to remove the lien
+	if lien, err = findProjectLien(ctx, cfg.ProjectID); err != nil {
+		return err
+	}
+	if lien == nil {
+		return fmt.Errorf("lien on project '%s' was created but is not listed", cfg.ProjectID)
+	}
+	metrics.recordResource("lien", lien.Name, resourceCreated)
+	logNotice("Project '%s' is protected by lien '%s'; delete it with 'gcloud alpha resource-manager liens delete %s' before deleting the project.", cfg.ProjectID, lien.Name, lien.Name)
+	return nil
+}
+
+// findProjectLien returns the tool's lien against deleting projectID, or nil
+func findProjectLien(ctx context.Context, projectID string) (*projectLien, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "alpha", "resource-manager", "liens", "list", "--project", projectID, "--format=json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list liens of project '%s': %w", projectID, err)
+	}
+	var liens []projectLien
+	if err := json.Unmarshal([]byte(output), &liens); err != nil {
+		return nil, fmt.Errorf("failed to parse liens of project '%s': %w", projectID, err)
+	}
+	for _, l := range liens {
+		if l.Origin == lienOrigin && slices.Contains(l.Restrictions, lienRestriction) {
+			return &l, nil
+		}
+	}
+	return nil, nil
+}
+
+func planProjectLien(cfg *Config) []planAction {
+	if !cfg.ProjectLien.Enabled {
+		return nil
+	}
+	return []planAction{{
+		Description: "Place a lien against deleting the project, unless one from an earlier run exists",
+		Command:     []string{"gcloud", "alpha", "resource-manager", "liens", "create", "--project", cfg.ProjectID, "--restrictions", lienRestriction, "--reason", cfg.ProjectLien.Reason, "--origin", lienOrigin},
+	}}
+}
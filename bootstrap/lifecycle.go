@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// enabled reports whether any pruning rule is configured
+func (l StateBucketLifecycleConfig) enabled() bool {
+	return l.MaxNoncurrentVersions > 0 || l.NoncurrentAgeDays > 0
+}
+
+// lifecycleRule is a GCS lifecycle rule in the JSON format accepted by --lifecycle-file
+type lifecycleRule struct {
+	Action    map[string]string `json:"action"`
+	Condition map[string]any    `json:"condition"`
+}
+
+// policy renders the lifecycle policy deleting noncurrent versions
+func (l StateBucketLifecycleConfig) policy() ([]byte, error) {
+	var rules []lifecycleRule
+	if l.MaxNoncurrentVersions > 0 {
+		rules = append(rules, lifecycleRule{
+			Action:    map[string]string{"type": "Delete"},
+			Condition: map[string]any{"isLive": false, "numNewerVersions": l.MaxNoncurrentVersions},
+		})
+	}
+	if l.NoncurrentAgeDays > 0 {
+		rules = append(rules, lifecycleRule{
+			Action:    map[string]string{"type": "Delete"},
+			Condition: map[string]any{"isLive": false, "daysSinceNoncurrentTime": l.NoncurrentAgeDays},
+		})
+	}
+	return json.MarshalIndent(map[string]any{"rule": rules}, "", "  ")
+}
+
+// applyBucketLifecycle sets the lifecycle policy pruning noncurrent state versions.
+// The policy replaces any lifecycle rules already set on the bucket.
+func applyBucketLifecycle(ctx context.Context, cfg *Config) error {
+	lc := cfg.TFStateBucketLifecycle
+	if !lc.enabled() {
+		logInfo("Skipping state bucket lifecycle rules as per config.")
+		return nil
+	}
+	policy, err := lc.policy()
+	if err != nil {
+		return fmt.Errorf("failed to render lifecycle policy: %w", err)
+	}
+	tmp, err := os.CreateTemp("", "lifecycle-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create lifecycle policy file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(policy)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write lifecycle policy file: %w", err)
+	}
+
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	logInfo("Applying lifecycle rules to '%s'...", bucketURL)
+	logDebug("Lifecycle policy:\n%s", policy)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL,
+		"--lifecycle-file", tmp.Name(),
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to apply lifecycle rules: %w", err)
+	}
+	logInfo("Lifecycle rules applied.")
+	return nil
+}
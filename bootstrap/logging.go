@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Supported values for --log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// levelNotice is used for step results and the final summary, which remain visible
+// in --quiet mode. It is rendered as INFO.
+const levelNotice = slog.LevelInfo + 2
+
+// stdout and stderr receive all console output, including streamed gcloud output.
+// setupLogFile tees them into a log file, which is also exposed as logFileWriter.
+var (
+	stdout        io.Writer = os.Stdout
+	stderr        io.Writer = os.Stderr
+	logFileWriter io.Writer = io.Discard
+)
+
+// logLevel is the minimum level emitted by the logger
+var logLevel = new(slog.LevelVar)
+
+// quietLogging records whether --quiet is active, so streamed gcloud output and
+// countdowns are suppressed on the console
+var quietLogging bool
+
+// logger is the process-wide structured logger. It defaults to the human-readable
+// text format and is reconfigured by setupLogging once flags are parsed.
+var logger = slog.New(newTextHandler(os.Stderr))
+
+// jsonLogging records whether logs are emitted as JSON, so interactive output
+// (like countdowns) can be suppressed
+var jsonLogging bool
+
+// currentStep is the ID of the bootstrap step being executed, attached to log records
+var currentStep struct {
+	sync.Mutex
+	id string
+}
+
+// setupLogging configures the logger for the requested format and minimum level
+func setupLogging(format string, level slog.Level) error {
+	logLevel.Set(level)
+	quietLogging = level > slog.LevelInfo
+	switch format {
+	case logFormatText:
+		logger = slog.New(newTextHandler(stderr))
+	case logFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(lockedWriter{w: stderr}, &slog.HandlerOptions{
+			Level: logLevel,
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey && a.Value.Any() == levelNotice {
+					a.Value = slog.StringValue(slog.LevelInfo.String())
+				}
+				return a
+			},
+		}))
+		jsonLogging = true
+	default:
+		return fmt.Errorf("unsupported log format '%s' (use '%s' or '%s')", format, logFormatText, logFormatJSON)
+	}
+	return nil
+}
+
+// setupLogFile tees all console output into the file at path. If path is an existing
+// directory, a timestamped file name is generated inside it. setupLogging must be
+// called afterwards so the logger picks up the tee.
+func setupLogFile(path string) (*os.File, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, fmt.Sprintf("gcp-bootstrap-%s.log", time.Now().Format("20060102-150405")))
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file '%s': %w", path, err)
+	}
+	logFileWriter = ansiStripWriter{w: f}
+	stdout = io.MultiWriter(os.Stdout, logFileWriter)
+	stderr = io.MultiWriter(os.Stderr, logFileWriter)
+	return f, nil
+}
+
+// setLogStep sets the step ID attached to subsequent log records; empty clears it
+func setLogStep(id string) {
+	currentStep.Lock()
+	defer currentStep.Unlock()
+	currentStep.id = id
+}
+
+// logStep returns the ID of the step being executed, empty outside of steps
+func logStep() string {
+	currentStep.Lock()
+	defer currentStep.Unlock()
+	return currentStep.id
+}
+
+// logAt emits a log record at the given level, tagged with the current step
+func logAt(level slog.Level, msg string, attrs ...slog.Attr) {
+	if step := logStep(); step != "" {
+		attrs = append(attrs, slog.String("step", step))
+	}
+	logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// logDebug prints a debug message, shown only with --verbose
+func logDebug(format string, v ...interface{}) {
+	logAt(slog.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+// logNotice prints a step result or summary message, shown even with --quiet
+func logNotice(format string, v ...interface{}) {
+	logAt(levelNotice, fmt.Sprintf(format, v...))
+}
+
+// logInfo prints an informational message
+func logInfo(format string, v ...interface{}) {
+	logAt(slog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+// logWarning prints a warning message and records it for the run report
+func logWarning(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	metrics.recordWarning(msg)
+	logAt(slog.LevelWarn, msg)
+}
+
+// logError prints an error message and exits
+func logError(format string, v ...interface{}) {
+	logAt(slog.LevelError, fmt.Sprintf(format, v...))
+	exitProcess(1)
+}
+
+// textHandler renders records in the classic "2006/01/02 15:04:05 [INFO] message" format,
+// coloring the level tag when colors are enabled.
+// Attributes are omitted since the message text already carries the same information.
+type textHandler struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func newTextHandler(w io.Writer) *textHandler {
+	return &textHandler{mu: &outputMu, w: w}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	level := r.Level
+	if level == levelNotice {
+		level = slog.LevelInfo
+	}
+	tag := colorize(levelColor(level), "["+level.String()+"]")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintf(h.w, "%s %s %s\n", r.Time.Format("2006/01/02 15:04:05"), tag, r.Message)
+	return err
+}
+
+func (h *textHandler) WithAttrs(_ []slog.Attr) slog.Handler { return h }
+
+func (h *textHandler) WithGroup(_ string) slog.Handler { return h }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Log sink scopes; organization and folder sinks are aggregated over all child projects
+const (
+	logSinkScopeProject      = "project"
+	logSinkScopeOrganization = "organization"
+	logSinkScopeFolder       = "folder"
+)
+
+// logSinkNamePattern matches the sink names Cloud Logging accepts
+var logSinkNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+
+// resolvedLogSink is a configured sink with its destination and parent worked out
+type resolvedLogSink struct {
+	LogSinkConfig
+	SinkDestination string   // e.g. storage.googleapis.com/BUCKET
+	ParentFlags     []string // e.g. --project P or --organization O
+	GrantCommand    []string // gcloud arguments granting the writer identity access; WRITER is replaced
+}
+
+// validateLogSinks checks the names, scopes and destinations of log_sinks
+func validateLogSinks(cfg *Config) error {
+	seen := map[string]bool{}
+	for i := range cfg.LogSinks {
+		s := &cfg.LogSinks[i]
+		if !logSinkNamePattern.MatchString(s.Name) {
+			return fmt.Errorf("log_sinks name '%s' may only contain letters, digits, '_', '-' and '.' (at most 100)", s.Name)
+		}
+		if s.Scope == "" {
+			s.Scope = logSinkScopeProject
+		}
+		if seen[s.Scope+"/"+s.Name] {
+			return fmt.Errorf("log_sinks name '%s' is listed twice", s.Name)
+		}
+		seen[s.Scope+"/"+s.Name] = true
+		switch s.Scope {
+		case logSinkScopeProject:
+		case logSinkScopeOrganization:
+			if cfg.OrganizationID == "" {
+				return fmt.Errorf("log_sinks '%s' has scope organization but organization_id is not set", s.Name)
+			}
+		case logSinkScopeFolder:
+			if cfg.FolderID == "" {
+				return fmt.Errorf("log_sinks '%s' has scope folder but folder_id is not set", s.Name)
+			}
+		default:
+			return fmt.Errorf("log_sinks '%s' has invalid scope '%s' (valid: project, organization, folder)", s.Name, s.Scope)
+		}
+		if _, err := resolveLogSink(cfg, *s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveLogSink works out the sink destination, its parent and the grant its writer
+// identity needs on the destination
+func resolveLogSink(cfg *Config, s LogSinkConfig) (resolvedLogSink, error) {
+	r := resolvedLogSink{LogSinkConfig: s}
+	switch s.Scope {
+	case logSinkScopeOrganization:
+		r.ParentFlags = []string{"--organization", cfg.OrganizationID}
+	case logSinkScopeFolder:
+		r.ParentFlags = []string{"--folder", cfg.FolderID}
+	default:
+		r.ParentFlags = []string{"--project", cfg.ProjectID}
+	}
+	kind, target, _ := strings.Cut(s.Destination, ":")
+	switch {
+	case s.Destination == "state_bucket":
+		r.SinkDestination = "storage.googleapis.com/" + cfg.TFStateBucketName
+		r.GrantCommand = []string{"storage", "buckets", "add-iam-policy-binding", "gs://" + cfg.TFStateBucketName, "--member", "WRITER", "--role", "roles/storage.objectCreator", "--project", cfg.seedProject()}
+	case kind == "bucket" && target != "":
+		r.SinkDestination = "storage.googleapis.com/" + target
+		r.GrantCommand = []string{"storage", "buckets", "add-iam-policy-binding", "gs://" + target, "--member", "WRITER", "--role", "roles/storage.objectCreator"}
+	case kind == "bigquery" && target != "":
+		project, dataset, ok := strings.Cut(target, ".")
+		if !ok {
+			project, dataset = cfg.ProjectID, target
+		}
+		r.SinkDestination = fmt.Sprintf("bigquery.googleapis.com/projects/%s/datasets/%s", project, dataset)
+		// gcloud cannot grant on a dataset, so the writer gets Data Editor on its project
+		r.GrantCommand = []string{"projects", "add-iam-policy-binding", project, "--member", "WRITER", "--role", "roles/bigquery.dataEditor", "--condition=None"}
+	case strings.Contains(s.Destination, ".googleapis.com/"):
+		r.SinkDestination = s.Destination
+	default:
+		return r, fmt.Errorf("log_sinks '%s' destination '%s' must be state_bucket, bucket:NAME, bigquery:[PROJECT.]DATASET or a full sink destination", s.Name, s.Destination)
+	}
+	return r, nil
+}
+
+// createArgs returns the gcloud arguments creating the sink
+func (r resolvedLogSink) createArgs() []string {
+	args := append([]string{"logging", "sinks", "create", r.Name, r.SinkDestination}, r.ParentFlags...)
+	if r.Filter != "" {
+		args = append(args, "--log-filter", r.Filter)
+	}
+	if r.Scope != logSinkScopeProject {
+		args = append(args, "--include-children")
+	}
+	if r.UsePartitionedTables {
+		args = append(args, "--use-partitioned-tables")
+	}
+	return args
+}
+
+// grantArgs returns the grant command for writer, or nil if the destination needs a manual grant
+func (r resolvedLogSink) grantArgs(writer string) []string {
+	if r.GrantCommand == nil {
+		return nil
+	}
+	args := make([]string, len(r.GrantCommand))
+	for i, a := range r.GrantCommand {
+		if a == "WRITER" {
+			a = writer
+		}
+		args[i] = a
+	}
+	return args
+}
+
+// setupLogSinks creates or updates the sinks listed under log_sinks and grants each sink's
+// writer identity the role it needs on its destination
+func setupLogSinks(ctx context.Context, cfg *Config) error {
+	if len(cfg.LogSinks) == 0 {
+		logInfo("Skipping log sinks as per config.")
+		return nil
+	}
+	for _, s := range cfg.LogSinks {
+		r, err := resolveLogSink(cfg, s)
+		if err != nil {
+			return err
+		}
+		describe := append([]string{"logging", "sinks", "describe", r.Name, "--format=value(writerIdentity)"}, r.ParentFlags...)
+		writer, err := runCommandGetOutput(ctx, "gcloud", describe...)
+		switch {
+		case err != nil && strings.Contains(err.Error(), "NOT_FOUND"):
+			logInfo("Creating %s log sink '%s' to '%s'...", r.Scope, r.Name, r.SinkDestination)
+			if err := runCommand(ctx, "gcloud", r.createArgs()...); err != nil {
+				return fmt.Errorf("failed to create log sink '%s': %w", r.Name, err)
+			}
+			metrics.recordResource("log_sink", r.Scope+"/"+r.Name, resourceCreated)
+			if writer, err = runCommandGetOutput(ctx, "gcloud", describe...); err != nil {
+				return fmt.Errorf("failed to read the writer identity of log sink '%s': %w", r.Name, err)
+			}
+		case err != nil:
+			return fmt.Errorf("failed to check log sink '%s': %w", r.Name, err)
+		default:
+			logInfo("Log sink '%s' exists; updating its destination and filter...", r.Name)
+			update := append([]string{"logging", "sinks", "update", r.Name, r.SinkDestination, "--log-filter=" + r.Filter}, r.ParentFlags...)
+			if err := runCommand(ctx, "gcloud", update...); err != nil {
+				return fmt.Errorf("failed to update log sink '%s': %w", r.Name, err)
+			}
+			metrics.recordResource("log_sink", r.Scope+"/"+r.Name, resourceExisted)
+		}
+
+		writer = strings.TrimSpace(writer)
+		grant := r.grantArgs(writer)
+		if grant == nil {
+			logWarning("Grant the writer identity '%s' of log sink '%s' access to '%s' yourself.", writer, r.Name, r.SinkDestination)
+			continue
+		}
+		if grant[0] == "projects" {
+			if err := snapshotProjectIAM(ctx, grant[2]); err != nil {
+				return err
+			}
+		}
+		logInfo("Allowing log sink writer '%s' to write to '%s'...", writer, r.SinkDestination)
+		if err := runCommand(ctx, "gcloud", grant...); err != nil {
+			return fmt.Errorf("failed to grant the writer identity of log sink '%s': %w", r.Name, err)
+		}
+	}
+	logInfo("Log sinks configured.")
+	return nil
+}
+
+func planLogSinks(cfg *Config) []planAction {
+	var actions []planAction
+	for _, s := range cfg.LogSinks {
+		r, err := resolveLogSink(cfg, s)
+		if err != nil {
+			continue
+		}
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Create %s log sink '%s' to '%s', or update its destination and filter", r.Scope, r.Name, r.SinkDestination),
+			Command:     append([]string{"gcloud"}, r.createArgs()...),
+		})
+		if grant := r.grantArgs("<writer-identity>"); grant != nil {
+			actions = append(actions, planAction{
+				Description: fmt.Sprintf("Allow the writer identity of '%s' to write to the destination", r.Name),
+				Command:     append([]string{"gcloud"}, grant...),
+			})
+		}
+	}
+	return actions
+}
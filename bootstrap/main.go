@@ -1,18 +1,166 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
 const defaultConfigFilename = "config.yaml"
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		setupColor(false)
+		runHistoryCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		setupColor(false)
+		runAuditCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rotate-key" {
+		setupColor(false)
+		runRotateKeyCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "key" {
+		setupColor(false)
+		runKeyCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		setupColor(false)
+		runExplainCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "permissions" {
+		setupColor(false)
+		runPermissionsCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "destroy" {
+		setupColor(false)
+		runDestroyCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		setupColor(false)
+		runDaemonCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "iam" {
+		setupColor(false)
+		runIAMCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-bucket" {
+		setupColor(false)
+		runMigrateBucketCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bulk" {
+		setupColor(false)
+		runBulkCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "roles" {
+		setupColor(false)
+		runRolesCommand(os.Args[2:])
+		return
+	}
+	args := os.Args[1:]
+	mode := bootstrapModeProject
+	if len(args) > 0 && args[0] == "org-bootstrap" {
+		mode = bootstrapModeOrg
+		args = args[1:]
+	}
+
 	// Allow specifying config file path via flag
 	configPath := flag.String("config", defaultConfigFilename, "Path to the configuration YAML file")
-	flag.Parse()
+	timeout := flag.Duration("timeout", 0, "Abort the bootstrap if it runs longer than this (e.g. 30m); 0 disables the limit")
+	changeTicket := flag.String("change-ticket", "", "Change-management ticket (e.g. JIRA-123) recorded in the project labels, TF SA description, run report and history")
+	assumeYes := flag.Bool("yes", false, "Skip the confirmation prompt (production configs also require --production-ack)")
+	productionAck := flag.Bool("production-ack", false, "Acknowledge a production config so --yes can skip the typed confirmation")
+	logFormat := flag.String("log-format", logFormatText, "Log output format: 'text' or 'json'")
+	logFile := flag.String("log-file", "", "Also write all output (including gcloud output) to this file; a directory gets a timestamped file")
+	credentialsFile := flag.String("credentials-file", "", "Authenticate gcloud with this service account/credential JSON file instead of the active gcloud account")
+	verbose := flag.Bool("verbose", false, "Show debug output, including stderr of read-only gcloud commands")
+	quiet := flag.Bool("quiet", false, "Only print step results, warnings, errors and the final summary")
+	summaryOnly := flag.Bool("summary-only", false, "Print nothing but errors and one final line (a JSON object with --log-format json) with the status, duration and key outputs; requires --yes")
+	reportPath := flag.String("report-json", "", "Write a machine-readable JSON report of the run to this file")
+	metricsTextfile := flag.String("metrics-textfile", "", "Write Prometheus metrics of the run to this file (e.g. for the node_exporter textfile collector)")
+	metricsPushgateway := flag.String("metrics-pushgateway", "", "Push Prometheus metrics of the run to this Pushgateway URL")
+	historyDir := flag.String("history-dir", defaultHistoryDir, "Directory where a report of every run is stored for 'history diff'; empty disables it")
+	iamSnapshots := flag.String("iam-snapshot-dir", defaultIAMSnapshotDir, "Directory where project IAM policies are saved before they are changed, for 'iam rollback'; empty disables it")
+	eventsFD := flag.Int("events-fd", 0, "Write NDJSON progress events (step start/finish/error) to this inherited file descriptor")
+	eventsFile := flag.String("events-file", "", "Write NDJSON progress events (step start/finish/error) to this file")
+	skipSteps := flag.String("skip", "", "Comma-separated step IDs to skip for this run (in addition to steps.disabled in config)")
+	planOnly := flag.Bool("plan", false, "Print the planned actions and exit without making changes")
+	planFormat := flag.String("format", planFormatText, "Plan output format for --plan: 'text' or 'github' (Markdown for pull requests)")
+	assume := flag.String("assume", "", "With --plan, declare resource states instead of reading GCP, e.g. project=exists,billing=linked; no gcloud read calls are made")
+	explain := flag.Bool("explain", false, "With --plan, explain each step: why it runs, the permissions it needs and security considerations")
+	noColor := flag.Bool("no-color", false, "Disable colored output (also honors the NO_COLOR environment variable)")
+	fakeGCPFlag := flag.Bool("fake-gcp", false, "Run against an in-process fake of GCP instead of calling gcloud (for demos, tutorials and tests)")
+	fakeGCPState := flag.String("fake-gcp-state", "", "With --fake-gcp, load and save the fake's state in this JSON file so re-runs see earlier resources")
+	flag.StringVar(&configProjectEntry, "project-entry", "", "project_id of the config's 'projects' list entry to bootstrap (see 'bulk' to bootstrap all of them)")
+	flag.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to bootstrap, or 'all' to run each in file order")
+	addVariableFlags(flag.CommandLine)
+	var overrides []configOverride
+	flag.Var(&overrideFlag{name: "set", overrides: &overrides, parse: parseScalarOverride}, "set", "Override a config value, e.g. wif.github.branch=release (repeatable)")
+	flag.Var(&overrideFlag{name: "set-json", overrides: &overrides, parse: parseJSONOverride}, "set-json", "Override a config value with JSON, e.g. 'enable_apis=[\"run.googleapis.com\"]' (repeatable)")
+	flag.Var(&overrideFlag{name: "set-file", overrides: &overrides, parse: parseFileOverride}, "set-file", "Override a config value with the contents of a YAML/JSON file, e.g. folders=folders.yaml (repeatable)")
+	flag.CommandLine.Parse(args)
+
+	setupColor(*noColor || *logFormat == logFormatJSON)
+
+	var teeFile *os.File
+	if *logFile != "" {
+		f, err := setupLogFile(*logFile)
+		if err != nil {
+			logError("%v", err)
+		}
+		defer f.Close()
+		teeFile = f
+	}
+	level := slog.LevelInfo
+	switch {
+	case *verbose && *quiet:
+		logError("--verbose and --quiet cannot be used together")
+	case *summaryOnly && (*verbose || *quiet):
+		logError("--summary-only cannot be combined with --verbose or --quiet")
+	case *summaryOnly && (*planOnly || !*assumeYes):
+		logError("--summary-only is for unattended runs; use it with --yes and without --plan")
+	case *verbose:
+		level = slog.LevelDebug
+	case *quiet:
+		level = levelNotice
+	case *summaryOnly:
+		level = slog.LevelError
+	}
+	if err := setupLogging(*logFormat, level); err != nil {
+		logError("%v", err)
+	}
+	// The configuration summary and next steps only go to the log file, if any
+	summaryOut := stdout
+	if *summaryOnly {
+		stdout = logFileWriter
+	}
+	if err := setupEvents(*eventsFD, *eventsFile); err != nil {
+		logError("%v", err)
+	}
+	defer events.close()
+	if teeFile != nil {
+		logInfo("Writing full output to log file '%s'.", teeFile.Name())
+	}
 
 	// Determine absolute path if relative path is given
 	if !filepath.IsAbs(*configPath) {
@@ -23,66 +171,210 @@ func main() {
 		*configPath = filepath.Join(cwd, *configPath)
 	}
 
+	// Cancel the run on Ctrl+C / SIGTERM. A second signal falls back to the default behaviour.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if configEnvironment == allEnvironments {
+		// Per-run outputs would be overwritten by every environment
+		if *reportPath != "" || *eventsFile != "" || *eventsFD != 0 || *metricsTextfile != "" || *metricsPushgateway != "" {
+			logError("--env all cannot be combined with --report-json, --events-file, --events-fd or metrics outputs; run each environment with its own --env instead")
+		}
+		runAllEnvironments(ctx, *configPath)
+		return
+	}
+
+	iamSnapshotDir = *iamSnapshots
+	if *fakeGCPFlag {
+		fake, err := newFakeGCP(*fakeGCPState)
+		if err != nil {
+			logError("%v", err)
+		}
+		fakeGCP = fake
+	}
+
+	var assumptions map[string]string
+	if *assume != "" {
+		if !*planOnly {
+			logError("--assume can only be used with --plan")
+		}
+		parsed, err := parseAssumptions(*assume)
+		if err != nil {
+			logError("Invalid --assume: %v", err)
+		}
+		assumptions = parsed
+		configOffline = true
+	}
+
 	// --- Prerequisites ---
-	checkGcloud() // Check gcloud exists and is authenticated
+	if assumptions == nil {
+		checkGcloud(ctx, *credentialsFile) // Check gcloud exists and is authenticated
+	}
 
 	// --- Load Config ---
-	cfg, err := loadConfig(*configPath)
+	if *summaryOnly {
+		// Prompts would go to the log file and block on stdin; fail on missing variables instead
+		variablePrompts = false
+	}
+	cfg, err := loadConfig(ctx, *configPath, mode, overrides)
 	if err != nil {
 		logError("Failed to load configuration: %v", err)
 	}
 
+	cfg.AssumeYes = *assumeYes
+	if *changeTicket != "" {
+		if err := cfg.setChangeTicket(*changeTicket); err != nil {
+			logError("Invalid --change-ticket: %v", err)
+		}
+	}
+	if !*planOnly {
+		noteCloudShell(cfg)
+	}
+	cfg.Assumptions = assumptions
+
+	if *skipSteps != "" {
+		if err := cfg.disableSteps(strings.Split(*skipSteps, ",")); err != nil {
+			logError("Invalid --skip: %v", err)
+		}
+	}
+
+	// Locking the retention policy always asks for typed confirmation, even with --yes
+	if *summaryOnly && cfg.TFStateBucketRetentionLock && !cfg.isStepDisabled("bucket_retention") {
+		logError("--summary-only cannot confirm the retention policy lock (tf_state_bucket_retention_lock); run without --summary-only, or pass --skip bucket_retention once the lock is in place")
+	}
+
+	// --- Preflight Checks ---
+	if err := checkLocations(ctx, cfg); err != nil {
+		logError("Location check failed: %v", err)
+	}
+	if err := checkSeedProject(ctx, cfg); err != nil {
+		logError("Seed project check failed: %v", err)
+	}
+	if assumptions != nil {
+		logNotice("Planning from --assume without reading GCP; the existing bucket location and configured IAM roles are not checked.")
+	} else if err := checkRoles(ctx, cfg); err != nil {
+		logError("Role check failed: %v", err)
+	}
+
+	if *planOnly {
+		if err := renderPlan(stdout, cfg, *planFormat, *explain); err != nil {
+			logError("%v", err)
+		}
+		return
+	}
+
 	// --- Confirm ---
-	confirmExecution(cfg) // Show summary and ask user to proceed
+	confirmExecution(ctx, cfg, *assumeYes, *productionAck) // Show summary and ask user to proceed
+
+	// Write the report and history entry for failed or interrupted runs too
+	registerExitHook(func(code int) {
+		writeRunOutputs(cfg, runStatusForExitCode(code), *reportPath, *historyDir)
+		exportRunMetrics(cfg, runStatusForExitCode(code), *metricsTextfile, *metricsPushgateway)
+	})
+
+	registerExitHook(func(code int) {
+		events.emit(progressEvent{Type: eventRunFinish, ProjectID: cfg.ProjectID, Status: runStatusForExitCode(code)})
+		events.close()
+	})
+	if *summaryOnly {
+		registerExitHook(func(code int) {
+			writeRunSummary(summaryOut, cfg, runStatusForExitCode(code), *reportPath)
+		})
+	}
 
 	// --- Execute Bootstrap Steps ---
 	logInfo("Starting GCP bootstrap...")
+	events.emit(progressEvent{Type: eventRunStart, ProjectID: cfg.ProjectID})
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		logInfo("Global timeout set to %s.", *timeout)
+	}
 
-	// Set project context for subsequent gcloud commands
-	err = runCommand("gcloud", "config", "set", "project", cfg.ProjectID)
-	if err != nil {
-		logError("Failed to set gcloud project context: %v", err)
+	// Set project context for subsequent gcloud commands; a generated ID is only known
+	// once the project step has resolved it, which sets the context itself
+	if !cfg.projectIDPending() {
+		err = runCommand(ctx, "gcloud", "config", "set", "project", cfg.ProjectID)
+		if err != nil {
+			logError("Failed to set gcloud project context: %v", err)
+		}
 	}
 
 	// Execute steps sequentially
-	if err := createProject(cfg); err != nil {
-		logError("Bootstrap failed during project creation: %v", err)
-	}
-	if err := linkBilling(cfg); err != nil {
-		logError("Bootstrap failed during billing linking: %v", err)
+	runSteps(ctx, cfg, bootstrapSteps)
+
+	// --- Completion Message ---
+	logNotice("GCP bootstrap process completed successfully!")
+	events.emit(progressEvent{Type: eventRunFinish, ProjectID: cfg.ProjectID, Status: runStatusSucceeded, DurationSeconds: time.Since(metrics.start).Seconds()})
+	metrics.logSummary()
+	writeRunOutputs(cfg, runStatusSucceeded, *reportPath, *historyDir)
+	exportRunMetrics(cfg, runStatusSucceeded, *metricsTextfile, *metricsPushgateway)
+	printNextSteps(cfg)
+	if *summaryOnly {
+		writeRunSummary(summaryOut, cfg, runStatusSucceeded, *reportPath)
 	}
-	if err := enableAPIs(cfg); err != nil {
-		logError("Bootstrap failed during API enablement: %v", err)
+}
+
+// printNextSteps tells the user how to start using the bootstrapped project with Terraform
+func printNextSteps(cfg *Config) {
+	generated := !cfg.isStepDisabled("terraform_files")
+	terragrunt := generated && cfg.Terraform.Format == terraformFormatTerragrunt
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	fmt.Fprintln(stdout, " Next Steps:")
+	switch {
+	case terragrunt:
+		fmt.Fprintf(stdout, " 1. Your Terragrunt remote state and provider are configured in '%s' (bucket: %s); include it from your units.\n", filepath.Join(cfg.Terraform.OutputDir, "terragrunt.hcl"), cfg.TFStateBucketName)
+	case generated:
+		fmt.Fprintf(stdout, " 1. Your Terraform backend is configured in '%s' (bucket: %s).\n", filepath.Join(cfg.Terraform.OutputDir, "backend.tf"), cfg.TFStateBucketName)
+	default:
+		fmt.Fprintf(stdout, " 1. Configure your Terraform backend ('backend \"gcs\" {}') using bucket: %s\n", cfg.TFStateBucketName)
 	}
-	if err := createServiceAccount(cfg); err != nil {
-		logError("Bootstrap failed during service account creation: %v", err)
+	fmt.Fprintln(stdout, " 2. Configure Terraform GCP provider authentication:")
+	if generated {
+		file := "provider.tf"
+		if terragrunt {
+			file = "terragrunt.hcl"
+		}
+		fmt.Fprintf(stdout, "    - Using the generated '%s' (keyless): it impersonates the Terraform SA; your user needs roles/iam.serviceAccountTokenCreator on it.\n", filepath.Join(cfg.Terraform.OutputDir, file))
 	}
-	if err := grantIAMRoles(cfg); err != nil {
-		// Log error but don't necessarily exit, roles might exist
-		logWarning("Potential issue during IAM role granting: %v", err)
+	switch {
+	case cfg.keyInKeychain():
+		fmt.Fprintln(stdout, "    - Using generated key (OS keychain): export GOOGLE_CREDENTIALS=\"$(gcp-bootstrap key export)\" (add -config if not config.yaml)")
+	case cfg.writesPrivateKey() && cfg.GitHubSecrets.Enabled && !cfg.GitHubSecrets.KeepLocalKey:
+		fmt.Fprintf(stdout, "    - Using generated key (CI/CD): it was uploaded to '%s' as secret %s and removed locally.\n", cfg.GitHubSecrets.Repository, githubSecretCredentials)
+	case cfg.GenerateTFSAKey && cfg.SAKeyMode == saKeyModeUpload:
+		fmt.Fprintf(stdout, "    - Using your uploaded key: build a service account key file from the private key matching '%s' and export GOOGLE_APPLICATION_CREDENTIALS.\n", cfg.TFSAPublicKey)
+	case cfg.writesPrivateKey():
+		fmt.Fprintf(stdout, "    - Using generated key: export GOOGLE_APPLICATION_CREDENTIALS=\"%s\"\n", cfg.TFSAKeyPath)
 	}
-	if err := createBucket(cfg); err != nil {
-		logError("Bootstrap failed during GCS bucket creation: %v", err)
+	if inCloudShell() {
+		// Cloud Shell serves the user's credentials as ADC, so there is nothing to log in to
+		fmt.Fprintln(stdout, "    - Using your user credentials (Cloud Shell): nothing to set up, Cloud Shell provides them as Application Default Credentials.")
+		fmt.Fprintf(stdout, "    - Using impersonation (Cloud Shell): export GOOGLE_IMPERSONATE_SERVICE_ACCOUNT=%s\n", cfg.TFServiceAccountEmail)
+	} else {
+		fmt.Fprintln(stdout, "    - Using your user credentials (for local dev): 'gcloud auth application-default login'")
+		fmt.Fprintf(stdout, "    - Using impersonation (local dev): 'gcloud auth application-default login --impersonate-service-account=%s'\n", cfg.TFServiceAccountEmail)
 	}
-	if err := enableBucketVersioning(cfg); err != nil {
-		logError("Bootstrap failed during bucket versioning enablement: %v", err)
+	switch {
+	case cfg.WIF.GitHub.Enabled && generated && !terragrunt:
+		fmt.Fprintf(stdout, "    - Using Workload Identity Federation (CI/CD): commit the generated GitHub Actions workflow '%s'.\n", cfg.WIF.GitHub.WorkflowPath)
+	case cfg.WIF.GitLab.Enabled && generated && !terragrunt:
+		fmt.Fprintf(stdout, "    - Using Workload Identity Federation (CI/CD): commit the generated GitLab CI pipeline '%s'.\n", cfg.WIF.GitLab.PipelinePath)
+	default:
+		fmt.Fprintln(stdout, "    - Using Workload Identity Federation (Recommended for CI/CD): Configure WIF pool/provider and use 'google-github-actions/auth'.")
 	}
-	if err := generateSAKey(cfg); err != nil {
-		logError("Bootstrap failed during service account key generation: %v", err)
+	switch {
+	case terragrunt:
+		fmt.Fprintf(stdout, " 3. Run 'terragrunt run-all plan' and then 'terragrunt run-all apply' below '%s' to deploy your infrastructure.\n", cfg.Terraform.OutputDir)
+	case generated:
+		fmt.Fprintf(stdout, " 3. Run 'terraform init' and then 'terraform apply' in '%s' to deploy your infrastructure.\n", cfg.Terraform.OutputDir)
+	default:
+		fmt.Fprintln(stdout, " 3. Run 'terraform init' and then 'terraform apply' to deploy your infrastructure.")
 	}
-
-	// --- Completion Message ---
-	logInfo("GCP bootstrap process completed successfully!")
-	fmt.Println("-----------------------------------------------------")
-	fmt.Println(" Next Steps:")
-	fmt.Printf(" 1. Configure your Terraform backend ('backend \"gcs\" {}') using bucket: %s\n", cfg.TFStateBucketName)
-	fmt.Println(" 2. Configure Terraform GCP provider authentication:")
-	if cfg.GenerateTFSAKey {
-		fmt.Printf("    - Using generated key: export GOOGLE_APPLICATION_CREDENTIALS=\"%s\"\n", cfg.TFSAKeyPath)
-	}
-	fmt.Println("    - Using your user credentials (for local dev): 'gcloud auth application-default login'")
-	fmt.Printf("    - Using impersonation (local dev): 'gcloud auth application-default login --impersonate-service-account=%s'\n", cfg.TFServiceAccountEmail)
-	fmt.Println("    - Using Workload Identity Federation (Recommended for CI/CD): Configure WIF pool/provider and use 'google-github-actions/auth'.")
-	fmt.Println(" 3. Run 'terraform init' and then 'terraform apply' to deploy your infrastructure.")
-	fmt.Println("-----------------------------------------------------")
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
 }
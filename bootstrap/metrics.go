@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Step outcomes recorded in metrics
+const (
+	stepStatusCompleted = "completed"
+	stepStatusWarning   = "warning"
+	stepStatusFailed    = "failed"
+	stepStatusSkipped   = "skipped"
+)
+
+// Resource outcomes recorded in metrics
+const (
+	resourceCreated = "created"
+	resourceExisted = "existing"
+)
+
+// waitRecord captures a single eventual-consistency wait performed during the run
+type waitRecord struct {
+	Name     string
+	Duration time.Duration
+}
+
+// stepRecord captures the outcome and duration of a bootstrap step
+type stepRecord struct {
+	ID       string
+	Name     string
+	Status   string
+	Duration time.Duration
+	Error    string
+}
+
+// resourceRecord captures a GCP resource the run created or found already existing
+type resourceRecord struct {
+	Kind   string
+	Name   string
+	Status string
+}
+
+// runMetrics collects timing information and outcomes of the bootstrap run
+type runMetrics struct {
+	mu        sync.Mutex
+	start     time.Time
+	waits     []waitRecord
+	steps     []stepRecord
+	resources []resourceRecord
+	warnings  []string
+}
+
+// metrics is the process-wide metrics collector
+var metrics = &runMetrics{start: time.Now()}
+
+// recordWait stores the duration of a named propagation wait
+func (m *runMetrics) recordWait(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waits = append(m.waits, waitRecord{Name: name, Duration: d})
+}
+
+// recordStep stores the outcome of a bootstrap step
+func (m *runMetrics) recordStep(r stepRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.steps = append(m.steps, r)
+}
+
+// recordResource stores a resource that was created or found to exist
+func (m *runMetrics) recordResource(kind, name, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resources = append(m.resources, resourceRecord{Kind: kind, Name: name, Status: status})
+}
+
+// recordWarning stores a warning message emitted during the run
+func (m *runMetrics) recordWarning(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.warnings = append(m.warnings, msg)
+}
+
+// logSummary prints the total time spent waiting and a breakdown per wait
+func (m *runMetrics) logSummary() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.waits) == 0 {
+		return
+	}
+	var total time.Duration
+	parts := make([]string, 0, len(m.waits))
+	for _, w := range m.waits {
+		total += w.Duration
+		parts = append(parts, w.Name+": "+w.Duration.Round(time.Second).String())
+	}
+	logInfo("Time spent waiting for propagation: %s (%s)", total.Round(time.Second), strings.Join(parts, ", "))
+}
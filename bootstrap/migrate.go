@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// runMigrateBucketCommand implements 'migrate-bucket': it moves the state to a new bucket in
+// tf_state_bucket_location, since bucket locations cannot be changed. It never prompts;
+// deleting the old bucket needs an explicit -delete-old.
+func runMigrateBucketCommand(args []string) {
+	fs := flag.NewFlagSet("migrate-bucket", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	to := fs.String("to", "", "Name of the new state bucket, created in tf_state_bucket_location (required)")
+	deleteOld := fs.Bool("delete-old", false, "Delete the old bucket and all its object versions after a verified copy")
+	credentialsFile := fs.String("credentials-file", "", "Authenticate gcloud with this credential JSON file instead of the active gcloud account")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap migrate-bucket -to NEW_BUCKET [-config FILE] [-env NAME] [-delete-old]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *to == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	checkGcloud(ctx, *credentialsFile)
+	cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+	if err != nil {
+		logError("Failed to load configuration: %v", err)
+	}
+	if err := resolveExistingProjectID(ctx, cfg); err != nil {
+		logError("%v", err)
+	}
+	oldBucket := cfg.TFStateBucketName
+	if *to == oldBucket {
+		logError("-to must differ from the current bucket '%s'; bucket names cannot be reused while the old bucket exists.", oldBucket)
+	}
+	location, err := bucketLocation(ctx, oldBucket, cfg.seedProject())
+	if err != nil {
+		logError("%v", err)
+	}
+	if location == "" {
+		logError("State bucket 'gs://%s' does not exist; nothing to migrate.", oldBucket)
+	}
+	if strings.EqualFold(location, cfg.TFStateBucketLocation) {
+		logNotice("State bucket 'gs://%s' is already in '%s'; nothing to migrate.", oldBucket, cfg.TFStateBucketLocation)
+		return
+	}
+	logInfo("Migrating state from 'gs://%s' (%s) to 'gs://%s' (%s)...", oldBucket, location, *to, cfg.TFStateBucketLocation)
+
+	if err := migrateStateBucket(ctx, cfg, *to); err != nil {
+		logError("Bucket migration failed: %v", err)
+	}
+	updated, err := updateBackendBucket(cfg, oldBucket, *to)
+	if err != nil {
+		logError("State copied to 'gs://%s', but updating the Terraform files failed: %v", *to, err)
+	}
+	for _, path := range updated {
+		logInfo("Pointed '%s' at 'gs://%s'.", path, *to)
+	}
+
+	if *deleteOld {
+		logWarning("Deleting 'gs://%s' and all its object versions.", oldBucket)
+		if err := runCommand(ctx, "gcloud", "storage", "rm", "--recursive", "gs://"+oldBucket, "--project", cfg.seedProject()); err != nil {
+			logError("Failed to delete the old bucket: %v", err)
+		}
+	}
+	logNotice("State migrated to 'gs://%s'. Set tf_state_bucket_name: \"%s\" in %s and run 'terraform init -reconfigure'.", *to, *to, *configPath)
+}
+
+// migrateStateBucket creates the new bucket with versioning and copies every object
+// version into it, verifying the object counts match
+func migrateStateBucket(ctx context.Context, cfg *Config, newBucket string) error {
+	target := *cfg
+	target.TFStateBucketName = newBucket
+	location, err := bucketLocation(ctx, newBucket, cfg.seedProject())
+	if err != nil {
+		return err
+	}
+	if location != "" && !strings.EqualFold(location, cfg.TFStateBucketLocation) {
+		return fmt.Errorf("target bucket 'gs://%s' already exists in '%s', not '%s'", newBucket, location, cfg.TFStateBucketLocation)
+	}
+	if err := createBucket(ctx, &target); err != nil {
+		return err
+	}
+	if err := enableBucketVersioning(ctx, &target); err != nil {
+		return err
+	}
+
+	oldCount, err := countObjectVersions(ctx, cfg.seedProject(), cfg.TFStateBucketName)
+	if err != nil {
+		return err
+	}
+	if oldCount == 0 {
+		logInfo("'gs://%s' holds no objects; nothing to copy.", cfg.TFStateBucketName)
+		return nil
+	}
+	logInfo("Copying %d object version(s)...", oldCount)
+	// Versions are copied oldest first, so the live version stays live in the new bucket
+	err = runCommand(ctx, "gcloud", "storage", "cp", "--recursive", "--all-versions",
+		fmt.Sprintf("gs://%s/*", cfg.TFStateBucketName), fmt.Sprintf("gs://%s/", newBucket),
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to copy state objects: %w", err)
+	}
+	newCount, err := countObjectVersions(ctx, cfg.seedProject(), newBucket)
+	if err != nil {
+		return err
+	}
+	if newCount < oldCount {
+		return fmt.Errorf("copy incomplete: 'gs://%s' has %d object version(s), 'gs://%s' only %d", cfg.TFStateBucketName, oldCount, newBucket, newCount)
+	}
+	logInfo("Copied %d object version(s).", newCount)
+	return nil
+}
+
+// countObjectVersions returns the number of object versions, live and noncurrent, in a bucket
+func countObjectVersions(ctx context.Context, projectID, bucketName string) (int, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "ls", "--all-versions", fmt.Sprintf("gs://%s/**", bucketName), "--project", projectID)
+	if err != nil {
+		if strings.Contains(err.Error(), "matched no objects") || strings.Contains(err.Error(), "One or more URLs matched no objects") {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to list objects in 'gs://%s': %w", bucketName, err)
+	}
+	if output == "" {
+		return 0, nil
+	}
+	return len(strings.Split(output, "\n")), nil
+}
+
+// updateBackendBucket replaces the quoted old bucket name in the existing generated
+// Terraform files, keeping any other edits, and returns the files it changed
+func updateBackendBucket(cfg *Config, oldBucket, newBucket string) ([]string, error) {
+	var updated []string
+	for _, f := range terraformFiles(cfg, "") {
+		data, err := os.ReadFile(f.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return updated, fmt.Errorf("failed to read '%s': %w", f.Path, err)
+		}
+		content := strings.ReplaceAll(string(data), fmt.Sprintf("%q", oldBucket), fmt.Sprintf("%q", newBucket))
+		if content == string(data) {
+			continue
+		}
+		if err := os.WriteFile(f.Path, []byte(content), 0644); err != nil {
+			return updated, fmt.Errorf("failed to write '%s': %w", f.Path, err)
+		}
+		updated = append(updated, f.Path)
+	}
+	return updated, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Bootstrap modes; org mode seeds an organization-level landing zone
+const (
+	bootstrapModeProject = "project"
+	bootstrapModeOrg     = "org"
+)
+
+// defaultTFSAOrgRoles lets the org-wide TF SA create the projects of the landing zone
+var defaultTFSAOrgRoles = []string{"roles/resourcemanager.projectCreator"}
+
+// defaultOrgBillingRole lets the org-wide TF SA link new projects to billing
+const defaultOrgBillingRole = "roles/billing.user"
+
+// enableOrgMode switches cfg to org-bootstrap mode: the project becomes the seed project
+// and the TF SA is granted organization-level roles
+func (c *Config) enableOrgMode() error {
+	if c.OrganizationID == "" {
+		return fmt.Errorf("organization_id is required for org-bootstrap")
+	}
+	c.Mode = bootstrapModeOrg
+	if len(c.OrgBootstrap.TFSAOrgRoles) == 0 {
+		c.OrgBootstrap.TFSAOrgRoles = defaultTFSAOrgRoles
+	}
+	if c.TFServiceAccountBillingRole == "" {
+		c.TFServiceAccountBillingRole = defaultOrgBillingRole
+	}
+	return nil
+}
+
+// grantOrgRoles grants the TF SA its organization-level roles in org-bootstrap mode
+func grantOrgRoles(ctx context.Context, cfg *Config) error {
+	if cfg.Mode != bootstrapModeOrg {
+		logInfo("Skipping organization role granting (not in org-bootstrap mode).")
+		return nil
+	}
+	logInfo("Granting organization roles to '%s' on organization '%s'...", cfg.TFServiceAccountEmail, cfg.OrganizationID)
+	failed := 0
+	for _, role := range cfg.OrgBootstrap.TFSAOrgRoles {
+		logInfo("Granting organization role '%s'...", role)
+		err := runCommand(ctx, "gcloud", "organizations", "add-iam-policy-binding", cfg.OrganizationID,
+			"--member", "serviceAccount:"+cfg.TFServiceAccountEmail,
+			"--role", role,
+			"--condition=None")
+		if err != nil {
+			logWarning("Failed to grant organization role %s (may already exist or permissions issue): %v", role, err)
+			failed++
+		}
+	}
+	if failed > 0 && cfg.Strict {
+		return fmt.Errorf("%d organization role binding(s) failed", failed)
+	}
+	logInfo("Organization role granting process completed (check warnings above).")
+	return nil
+}
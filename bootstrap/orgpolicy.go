@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// allowedPolicyMemberDomainsConstraint restricts IAM members to the listed directory customers
+const allowedPolicyMemberDomainsConstraint = "constraints/iam.allowedPolicyMemberDomains"
+
+// applyDomainRestrictedSharing sets the allowedPolicyMemberDomains constraint on the
+// project so only identities from the configured customers can ever be granted access
+func applyDomainRestrictedSharing(ctx context.Context, cfg *Config) error {
+	drs := cfg.DomainRestrictedSharing
+	if !drs.Enabled {
+		logInfo("Skipping domain restricted sharing policy as per config.")
+		return nil
+	}
+	logInfo("Restricting IAM members on project '%s' to customers: %s", cfg.ProjectID, strings.Join(drs.CustomerIDs, ", "))
+	args := []string{"resource-manager", "org-policies", "allow", allowedPolicyMemberDomainsConstraint}
+	args = append(args, drs.CustomerIDs...)
+	args = append(args, "--project", cfg.ProjectID)
+	if err := runCommand(ctx, "gcloud", args...); err != nil {
+		return fmt.Errorf("failed to set %s: %w", allowedPolicyMemberDomainsConstraint, err)
+	}
+	logInfo("Domain restricted sharing policy applied.")
+	return nil
+}
+
+// Constraints org_policies must not set because they break other parts of the config
+const (
+	disableSAKeyCreationConstraint       = "constraints/iam.disableServiceAccountKeyCreation"
+	skipDefaultNetworkCreationConstraint = "constraints/compute.skipDefaultNetworkCreation"
+)
+
+// orgPolicyAction is one gcloud org-policies command setting a constraint on the project
+type orgPolicyAction struct {
+	Verb       string // enable-enforce, allow or deny
+	Constraint string
+	Values     []string
+}
+
+// normalizeConstraint adds the constraints/ prefix where it was left out
+func normalizeConstraint(name string) string {
+	if strings.HasPrefix(name, "constraints/") {
+		return name
+	}
+	return "constraints/" + name
+}
+
+// validateOrgPolicies normalizes the constraint names of org_policies and rejects
+// constraints that would break other steps of the config
+func validateOrgPolicies(cfg *Config) error {
+	op := &cfg.OrgPolicies
+	for i, name := range op.Enforce {
+		op.Enforce[i] = normalizeConstraint(name)
+	}
+	for _, lists := range []*map[string][]string{&op.Allow, &op.Deny} {
+		normalized := map[string][]string{}
+		for name, values := range *lists {
+			if len(values) == 0 {
+				return fmt.Errorf("org_policies constraint '%s' lists no values", name)
+			}
+			normalized[normalizeConstraint(name)] = values
+		}
+		*lists = normalized
+	}
+	if slices.Contains(op.Enforce, disableSAKeyCreationConstraint) && cfg.GenerateTFSAKey {
+		return fmt.Errorf("org_policies enforces %s, which makes generate_tf_sa_key fail; use Workload Identity Federation or impersonation instead", disableSAKeyCreationConstraint)
+	}
+	if _, ok := op.Allow[allowedPolicyMemberDomainsConstraint]; ok && cfg.DomainRestrictedSharing.Enabled {
+		return fmt.Errorf("org_policies and domain_restricted_sharing both set %s; keep one", allowedPolicyMemberDomainsConstraint)
+	}
+	if slices.Contains(op.Enforce, skipDefaultNetworkCreationConstraint) && slices.Contains(requestedAPIs(cfg), "compute.googleapis.com") {
+		logWarning("%s is applied after the APIs are enabled; enabling compute.googleapis.com creates the default network before it takes effect.", skipDefaultNetworkCreationConstraint)
+	}
+	return nil
+}
+
+// actions returns the org-policies commands of the configured constraints in a stable order
+func (op OrgPoliciesConfig) actions() []orgPolicyAction {
+	var actions []orgPolicyAction
+	for _, constraint := range op.Enforce {
+		actions = append(actions, orgPolicyAction{Verb: "enable-enforce", Constraint: constraint})
+	}
+	for _, constraint := range sortedKeys(op.Allow) {
+		actions = append(actions, orgPolicyAction{Verb: "allow", Constraint: constraint, Values: op.Allow[constraint]})
+	}
+	for _, constraint := range sortedKeys(op.Deny) {
+		actions = append(actions, orgPolicyAction{Verb: "deny", Constraint: constraint, Values: op.Deny[constraint]})
+	}
+	return actions
+}
+
+// args returns the gcloud arguments applying the action to projectID
+func (a orgPolicyAction) args(projectID string) []string {
+	args := append([]string{"resource-manager", "org-policies", a.Verb, a.Constraint}, a.Values...)
+	return append(args, "--project", projectID)
+}
+
+// applyOrgPolicies sets the organization policy constraints listed under org_policies on
+// the project, after the bootstrap's own IAM grants and API enablement
+func applyOrgPolicies(ctx context.Context, cfg *Config) error {
+	actions := cfg.OrgPolicies.actions()
+	if len(actions) == 0 {
+		logInfo("Skipping organization policy constraints as per config.")
+		return nil
+	}
+	logInfo("Setting %d organization policy constraint(s) on project '%s'...", len(actions), cfg.ProjectID)
+	for _, a := range actions {
+		if err := runCommand(ctx, "gcloud", a.args(cfg.ProjectID)...); err != nil {
+			return fmt.Errorf("failed to set %s: %w", a.Constraint, err)
+		}
+	}
+	logInfo("Organization policy constraints applied.")
+	return nil
+}
+
+func planOrgPolicies(cfg *Config) []planAction {
+	var actions []planAction
+	for _, a := range cfg.OrgPolicies.actions() {
+		description := fmt.Sprintf("Enforce %s", a.Constraint)
+		if a.Verb != "enable-enforce" {
+			description = fmt.Sprintf("%s %s for %s", strings.ToUpper(a.Verb[:1])+a.Verb[1:], strings.Join(a.Values, ", "), a.Constraint)
+		}
+		actions = append(actions, planAction{Description: description, Command: append([]string{"gcloud"}, a.args(cfg.ProjectID)...)})
+	}
+	return actions
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// outputMu serializes every write of log records and streamed command output, so
+// concurrent writers (the stdout and stderr copiers of a command, log records and the
+// countdown) never interleave within a line, on the console or in the log file. Steps run
+// one at a time: the step tag comes from the global set by setLogStep.
+var outputMu sync.Mutex
+
+// lockedWriter writes to w while holding outputMu; used for handlers that emit one
+// complete record per Write
+type lockedWriter struct {
+	w io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return l.w.Write(p)
+}
+
+// stepOutput buffers a stream until a full line is available and writes each line to w
+// in a single call while holding outputMu, prefixed with the tag of the step that produced
+// it. A stepOutput belongs to one stream; create one per stream and Flush it when done.
+type stepOutput struct {
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+// newStepOutput returns a line-atomic writer to w tagging lines with step; an empty step
+// (output outside of a step) is written untagged
+func newStepOutput(w io.Writer, step string) *stepOutput {
+	o := &stepOutput{w: w}
+	if step != "" {
+		o.prefix = []byte(colorize(colorGray, "["+step+"]") + " ")
+	}
+	return o
+}
+
+func (o *stepOutput) Write(p []byte) (int, error) {
+	o.buf = append(o.buf, p...)
+	for {
+		i := bytes.IndexByte(o.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := o.writeLine(o.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		o.buf = o.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing partial line, terminated with a newline
+func (o *stepOutput) Flush() error {
+	if len(o.buf) == 0 {
+		return nil
+	}
+	line := append(o.buf, '\n')
+	o.buf = nil
+	return o.writeLine(line)
+}
+
+func (o *stepOutput) writeLine(line []byte) error {
+	out := make([]byte, 0, len(o.prefix)+len(line))
+	out = append(append(out, o.prefix...), line...)
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	_, err := o.w.Write(out)
+	return err
+}
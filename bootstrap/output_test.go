@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name, rewriting the file with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n--- got ---\n%s--- want ---\n%s", path, got, want)
+	}
+}
+
+// TestStepOutputPartialWrites interleaves partial lines of two streams deterministically:
+// each line must come out whole, tagged with its step, once its newline arrives
+func TestStepOutputPartialWrites(t *testing.T) {
+	var out bytes.Buffer
+	apis := newStepOutput(&out, "apis")
+	bucket := newStepOutput(&out, "bucket")
+	untagged := newStepOutput(&out, "")
+
+	apis.Write([]byte("Enabling ser"))
+	bucket.Write([]byte("Creating gs://"))
+	apis.Write([]byte("vices...\nOperation "))
+	untagged.Write([]byte("no step\n"))
+	bucket.Write([]byte("demo-tfstate...\n"))
+	apis.Write([]byte("finished successfully."))
+	bucket.Write([]byte("\n\n"))
+	apis.Flush()
+	bucket.Flush()
+
+	checkGolden(t, "step_output_partial.golden", out.Bytes())
+}
+
+// TestStepOutputConcurrentWriters writes from many goroutines at once in odd-sized chunks
+// and checks that no line is torn, tagged with the wrong writer's tag or lost
+func TestStepOutputConcurrentWriters(t *testing.T) {
+	const steps, lines = 8, 200
+	var out bytes.Buffer
+	var wg sync.WaitGroup
+	for s := 0; s < steps; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			w := newStepOutput(&out, fmt.Sprintf("step%d", s))
+			var stream []byte
+			for l := 0; l < lines; l++ {
+				stream = append(stream, fmt.Sprintf("step%d line %03d %s\n", s, l, strings.Repeat("x", l%17))...)
+			}
+			// Chunk sizes that split lines at varying offsets
+			for i, size := 0, 1; i < len(stream); i, size = i+size, size%13+1 {
+				w.Write(stream[i:min(i+size, len(stream))])
+			}
+			w.Flush()
+		}(s)
+	}
+	wg.Wait()
+
+	got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(got) != steps*lines {
+		t.Fatalf("got %d lines, want %d", len(got), steps*lines)
+	}
+	seen := map[string]bool{}
+	counts := map[string]int{}
+	for _, line := range got {
+		tag, text, ok := strings.Cut(line, " ")
+		fields := strings.Fields(text)
+		if !ok || len(fields) < 3 || tag != "["+fields[0]+"]" {
+			t.Fatalf("torn or mistagged line %q", line)
+		}
+		var s, l int
+		if _, err := fmt.Sscanf(text, "step%d line %d", &s, &l); err != nil {
+			t.Fatalf("torn line %q: %v", line, err)
+		}
+		if want := fmt.Sprintf("step%d line %03d %s", s, l, strings.Repeat("x", l%17)); text != want {
+			t.Fatalf("torn line %q, want %q", text, want)
+		}
+		if seen[text] {
+			t.Fatalf("duplicate line %q", line)
+		}
+		seen[text] = true
+		counts[tag]++
+	}
+	for s := 0; s < steps; s++ {
+		if tag := fmt.Sprintf("[step%d]", s); counts[tag] != lines {
+			t.Errorf("%s wrote %d lines, want %d", tag, counts[tag], lines)
+		}
+	}
+}
+
+// TestLockedWriterSerializesWithStepOutput checks that lockedWriter records (log lines, the
+// countdown, replayed quiet output) never land inside a step's line
+func TestLockedWriterSerializesWithStepOutput(t *testing.T) {
+	var out bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		w := newStepOutput(&out, "apis")
+		for i := 0; i < 500; i++ {
+			w.Write([]byte("gcloud "))
+			w.Write([]byte("output\n"))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			lockedWriter{&out}.Write([]byte("[INFO] record\n"))
+		}
+	}()
+	wg.Wait()
+
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if line != "[apis] gcloud output" && line != "[INFO] record" {
+			t.Fatalf("interleaved line %q", line)
+		}
+	}
+}
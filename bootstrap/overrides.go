@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configOverride replaces the value at a dotted config path (e.g. wif.github.branch)
+type configOverride struct {
+	Path  []string
+	Value any
+	Flag  string // Original flag and argument, for error messages and the config hash
+}
+
+// overrideFlag is a repeatable flag.Value that parses key=value arguments into overrides.
+// All override flags share one list so they apply in command-line order.
+type overrideFlag struct {
+	name      string
+	overrides *[]configOverride
+	parse     func(raw string) (any, error)
+}
+
+func (f *overrideFlag) String() string { return "" }
+
+func (f *overrideFlag) Set(arg string) error {
+	key, raw, ok := strings.Cut(arg, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got '%s'", arg)
+	}
+	value, err := f.parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid value for '%s': %w", key, err)
+	}
+	*f.overrides = append(*f.overrides, configOverride{Path: strings.Split(key, "."), Value: value, Flag: "--" + f.name + " " + arg})
+	return nil
+}
+
+// parseScalarOverride parses a --set value as a YAML scalar, so true and 42 keep their
+// types; anything that is not a scalar is kept as the literal string
+func parseScalarOverride(raw string) (any, error) {
+	var v any
+	if err := yaml.Unmarshal([]byte(raw), &v); err != nil {
+		return raw, nil
+	}
+	switch v.(type) {
+	case map[string]any, []any:
+		return raw, nil
+	}
+	return v, nil
+}
+
+// parseJSONOverride parses a --set-json value
+func parseJSONOverride(raw string) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// parseFileOverride reads a --set-file value as a YAML (or JSON) document
+func parseFileOverride(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+	return v, nil
+}
+
+// applyConfigOverrides returns the config document with the overrides applied in order
+func applyConfigOverrides(doc []byte, overrides []configOverride) ([]byte, error) {
+	root := map[string]any{}
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		if !isConfigKey(o.Path[0]) {
+			return nil, fmt.Errorf("%s: unknown config key '%s'", o.Flag, o.Path[0])
+		}
+		m := root
+		for _, key := range o.Path[:len(o.Path)-1] {
+			next, ok := m[key].(map[string]any)
+			if !ok {
+				if _, exists := m[key]; exists && m[key] != nil {
+					return nil, fmt.Errorf("%s: '%s' is not a mapping", o.Flag, key)
+				}
+				next = map[string]any{}
+				m[key] = next
+			}
+			m = next
+		}
+		m[o.Path[len(o.Path)-1]] = o.Value
+	}
+	return yaml.Marshal(root)
+}
+
+// isConfigKey reports whether key is a top-level YAML key of Config
+func isConfigKey(key string) bool {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == key && name != "-" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// requiredPermission is an IAM permission the identity running the bootstrap needs on a
+// resource, with the narrowest predefined role granting it
+type requiredPermission struct {
+	Scope      string // Resource the permission is needed on, e.g. "project 'my-proj'"
+	Permission string
+	Role       string
+}
+
+// requiredPermissions returns the permissions a step needs for cfg
+func requiredPermissions(cfg *Config, stepID string) []requiredPermission {
+	org := fmt.Sprintf("organization '%s'", cfg.OrganizationID)
+	project := fmt.Sprintf("project '%s'", cfg.ProjectID)
+	seed := fmt.Sprintf("project '%s'", cfg.seedProject())
+	billing := fmt.Sprintf("billing account '%s'", cfg.BillingAccountID)
+	perms := func(scope, role string, names ...string) []requiredPermission {
+		var ps []requiredPermission
+		for _, name := range names {
+			ps = append(ps, requiredPermission{Scope: scope, Permission: name, Role: role})
+		}
+		return ps
+	}
+
+	switch stepID {
+	case "folders":
+		return append(perms(org, "roles/resourcemanager.folderCreator", "resourcemanager.folders.list", "resourcemanager.folders.create"),
+			perms(org, "roles/resourcemanager.folderIamAdmin", "resourcemanager.folders.setIamPolicy")...)
+	case "project":
+		parent := org
+		if folder := cfg.parentFolder(); folder != "" {
+			parent = fmt.Sprintf("folder '%s'", folder)
+		} else if cfg.FolderPath != "" {
+			parent = fmt.Sprintf("folder '%s'", cfg.FolderPath)
+		} else if cfg.OrganizationID == "" {
+			parent = "your account (projects without an organization)"
+		}
+		ps := append(perms(parent, "roles/resourcemanager.projectCreator", "resourcemanager.projects.create"),
+			perms(project, "roles/browser", "resourcemanager.projects.get")...)
+		if len(cfg.projectLabels()) > 0 {
+			ps = append(ps, perms(project, "roles/resourcemanager.projectMover", "resourcemanager.projects.update (to reconcile labels)")...)
+		}
+		return ps
+	case "billing":
+		ps := append(perms(billing, "roles/billing.user", "billing.resourceAssociations.create"),
+			perms(project, "roles/billing.projectManager", "resourcemanager.projects.createBillingAssignment")...)
+		if cfg.BillingLink.Subaccount.Enabled {
+			ps = append(ps, perms(billing, "roles/billing.admin", "billing.accounts.update (to create subaccounts)")...)
+		}
+		return ps
+	case "apis":
+		return perms(project, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.list", "serviceusage.services.enable")
+	case "budget":
+		return append(perms(project, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(billing, "roles/billing.costsManager", "billing.budgets.list", "billing.budgets.create")...)
+	case "billing_export":
+		exportProject := fmt.Sprintf("project '%s'", cfg.BillingExport.project(cfg))
+		return slices.Concat(
+			perms(exportProject, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(exportProject, "roles/bigquery.admin", "bigquery.datasets.get", "bigquery.datasets.create", "bigquery.tables.list"),
+			perms(billing, "roles/billing.admin", "billing.accounts.update (to enable the export in the console)"),
+		)
+	case "service_account":
+		return perms(seed, "roles/iam.serviceAccountAdmin", "iam.serviceAccounts.get", "iam.serviceAccounts.create", "iam.serviceAccounts.update")
+	case "iam_roles":
+		ps := perms(project, "roles/resourcemanager.projectIamAdmin", "resourcemanager.projects.getIamPolicy", "resourcemanager.projects.setIamPolicy")
+		if cfg.TFServiceAccountBillingRole != "" {
+			ps = append(ps, perms(billing, "roles/billing.admin", "billing.accounts.getIamPolicy", "billing.accounts.setIamPolicy")...)
+		}
+		return ps
+	case "org_iam_roles":
+		return perms(org, "roles/resourcemanager.organizationAdmin", "resourcemanager.organizations.getIamPolicy", "resourcemanager.organizations.setIamPolicy")
+	case "wif":
+		return slices.Concat(
+			perms(seed, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(seed, "roles/iam.workloadIdentityPoolAdmin", "iam.workloadIdentityPools.create", "iam.workloadIdentityPoolProviders.create", "iam.workloadIdentityPoolProviders.update"),
+			perms(fmt.Sprintf("service account '%s'", cfg.TFServiceAccountEmail), "roles/iam.serviceAccountAdmin", "iam.serviceAccounts.setIamPolicy"),
+		)
+	case "ops_service_account":
+		return append(perms(project, "roles/iam.serviceAccountAdmin", "iam.serviceAccounts.create"),
+			perms(project, "roles/resourcemanager.projectIamAdmin", "resourcemanager.projects.setIamPolicy")...)
+	case "break_glass", "audit_logs":
+		return perms(project, "roles/resourcemanager.projectIamAdmin", "resourcemanager.projects.getIamPolicy", "resourcemanager.projects.setIamPolicy")
+	case "default_service_accounts":
+		ps := perms(project, "roles/resourcemanager.projectIamAdmin", "resourcemanager.projects.getIamPolicy", "resourcemanager.projects.setIamPolicy")
+		if cfg.DefaultServiceAccounts.Disable {
+			ps = append(ps, perms(project, "roles/iam.serviceAccountAdmin", "iam.serviceAccounts.get", "iam.serviceAccounts.disable")...)
+		}
+		return ps
+	case "essential_contacts":
+		return append(perms(project, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(project, "roles/essentialcontacts.admin", "essentialcontacts.contacts.list", "essentialcontacts.contacts.create", "essentialcontacts.contacts.update")...)
+	case "firebase":
+		return append(perms(project, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(project, "roles/firebase.admin", "firebase.projects.get", "firebase.projects.update")...)
+	case "api_keys":
+		return append(perms(project, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(project, "roles/serviceusage.apiKeysAdmin", "apikeys.keys.list", "apikeys.keys.create")...)
+	case "project_lien":
+		return perms(project, "roles/resourcemanager.lienModifier", "resourcemanager.projects.get", "resourcemanager.projects.updateLiens")
+	case "fleet":
+		host := fmt.Sprintf("project '%s'", cfg.Fleet.HostProjectID)
+		return slices.Concat(
+			perms(project, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(host, "roles/gkehub.admin", "gkehub.memberships.create"),
+			perms(host, "roles/resourcemanager.projectIamAdmin", "resourcemanager.projects.setIamPolicy"),
+		)
+	case "domain_restricted_sharing", "data_classification", "org_policies":
+		// The Organization Policy Administrator role can only be granted on the organization
+		return perms(org, "roles/orgpolicy.policyAdmin", "orgpolicy.policy.set")
+	case "bucket":
+		ps := perms(seed, "roles/storage.admin", "storage.buckets.get", "storage.buckets.create", "storage.buckets.update")
+		if cfg.TFStateBucketRepairUBLA {
+			ps = append(ps, perms(seed, "roles/storage.admin", "storage.buckets.setIamPolicy (to migrate a legacy ACL)")...)
+		}
+		return ps
+	case "bucket_iam":
+		return perms(seed, "roles/storage.admin", "storage.buckets.getIamPolicy", "storage.buckets.setIamPolicy")
+	case "bucket_versioning", "bucket_lifecycle", "bucket_retention":
+		return perms(seed, "roles/storage.admin", "storage.buckets.update")
+	case "bucket_kms":
+		return slices.Concat(
+			perms(seed, "roles/serviceusage.serviceUsageAdmin", "serviceusage.services.enable"),
+			perms(seed, "roles/cloudkms.admin", "cloudkms.keyRings.create", "cloudkms.cryptoKeys.create", "cloudkms.cryptoKeys.setIamPolicy"),
+			perms(seed, "roles/storage.admin", "storage.buckets.update"),
+		)
+	case "bucket_logging":
+		ps := perms(seed, "roles/storage.admin", "storage.buckets.get", "storage.buckets.create", "storage.buckets.update", "storage.buckets.setIamPolicy")
+		if cfg.TFStateBucketAccessLogging.DataAccessLogs {
+			ps = append(ps, perms(seed, "roles/resourcemanager.projectIamAdmin", "resourcemanager.projects.getIamPolicy", "resourcemanager.projects.setIamPolicy")...)
+		}
+		return ps
+	case "log_sinks":
+		ps := perms(project, "roles/logging.configWriter", "logging.sinks.get", "logging.sinks.create", "logging.sinks.update")
+		for _, s := range cfg.LogSinks {
+			switch s.Scope {
+			case logSinkScopeOrganization:
+				ps = append(ps, perms(org, "roles/logging.configWriter", "logging.sinks.create (aggregated sink '"+s.Name+"')")...)
+			case logSinkScopeFolder:
+				ps = append(ps, perms(fmt.Sprintf("folder '%s'", cfg.FolderID), "roles/logging.configWriter", "logging.sinks.create (aggregated sink '"+s.Name+"')")...)
+			}
+			if r, err := resolveLogSink(cfg, s); err == nil && r.GrantCommand != nil {
+				if r.GrantCommand[0] == "projects" {
+					ps = append(ps, perms(fmt.Sprintf("project '%s'", r.GrantCommand[2]), "roles/resourcemanager.projectIamAdmin", "resourcemanager.projects.setIamPolicy")...)
+				} else {
+					ps = append(ps, perms(fmt.Sprintf("bucket '%s'", r.GrantCommand[3]), "roles/storage.admin", "storage.buckets.setIamPolicy")...)
+				}
+			}
+		}
+		return ps
+	case "sa_key":
+		return perms(seed, "roles/iam.serviceAccountKeyAdmin", "iam.serviceAccountKeys.create")
+	case "sa_key_cleanup":
+		return perms(seed, "roles/iam.serviceAccountKeyAdmin", "iam.serviceAccountKeys.list", "iam.serviceAccountKeys.delete")
+	case "github_secrets":
+		repo := fmt.Sprintf("GitHub repository '%s'", cfg.GitHubSecrets.Repository)
+		ps := perms(repo, "token with Secrets and Variables read/write", "secrets: write", "variables: write")
+		if cfg.WIF.GitHub.Enabled {
+			ps = append(ps, perms(project, "roles/browser", "resourcemanager.projects.get")...)
+		}
+		return ps
+	case "terraform_files":
+		if cfg.WIF.GitHub.Enabled || cfg.WIF.GitLab.Enabled {
+			return perms(project, "roles/browser", "resourcemanager.projects.get")
+		}
+	case "cmdb":
+		table := fmt.Sprintf("ServiceNow table '%s'", cfg.CMDB.Table)
+		return append(perms(project, "roles/browser", "resourcemanager.projects.get"),
+			perms(table, "ServiceNow user with read, create and write on the table", "GET, POST, PATCH /api/now/table")...)
+	}
+	return nil
+}
+
+// runPermissionsCommand implements 'permissions': it prints the IAM permissions, and the
+// predefined roles granting them, that running the config needs, so access can be
+// requested before the change window
+func runPermissionsCommand(args []string) {
+	fs := flag.NewFlagSet("permissions", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap permissions [-config FILE] [-env NAME]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+	if err != nil {
+		logError("Failed to load configuration: %v", err)
+	}
+	writePermissionsReport(stdout, cfg)
+}
+
+// writePermissionsReport writes the permissions of the steps that run for cfg, grouped by
+// the resource they are needed on, followed by the roles granting them
+func writePermissionsReport(w io.Writer, cfg *Config) {
+	byScope := map[string][]requiredPermission{}
+	var scopes []string
+	for _, ps := range buildPlan(cfg) {
+		if ps.Skipped {
+			continue
+		}
+		for _, p := range requiredPermissions(cfg, ps.ID) {
+			if !slices.Contains(scopes, p.Scope) {
+				scopes = append(scopes, p.Scope)
+			}
+			if !slices.ContainsFunc(byScope[p.Scope], func(q requiredPermission) bool { return q.Permission == p.Permission }) {
+				byScope[p.Scope] = append(byScope[p.Scope], p)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "-----------------------------------------------------")
+	fmt.Fprintf(w, " Required permissions for project '%s'\n", cfg.ProjectID)
+	fmt.Fprintln(w, "-----------------------------------------------------")
+	fmt.Fprintln(w, " Needed by the identity gcloud runs as (the active account or -credentials-file):")
+	for _, scope := range scopes {
+		fmt.Fprintf(w, " On %s:\n", scope)
+		for _, p := range byScope[scope] {
+			fmt.Fprintf(w, "   - %-50s (%s)\n", p.Permission, p.Role)
+		}
+	}
+	fmt.Fprintln(w, " Roles to request:")
+	for _, scope := range scopes {
+		var roles []string
+		for _, p := range byScope[scope] {
+			if !slices.Contains(roles, p.Role) {
+				roles = append(roles, p.Role)
+			}
+		}
+		fmt.Fprintf(w, "   - On %s: %s\n", scope, strings.Join(roles, ", "))
+	}
+	if cfg.Terraform.ImpersonateBackend {
+		fmt.Fprintln(w, " Needed afterwards by whoever runs Terraform with the generated impersonating backend:")
+		fmt.Fprintf(w, "   - On service account '%s': iam.serviceAccounts.getAccessToken (roles/iam.serviceAccountTokenCreator)\n", cfg.TFServiceAccountEmail)
+	}
+	fmt.Fprintln(w, "-----------------------------------------------------")
+	fmt.Fprintln(w, " The creator of a new project becomes its owner, which covers all project-level permissions.")
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Supported values for --format
+const (
+	planFormatText   = "text"
+	planFormatGitHub = "github"
+)
+
+// planAction describes one change a step would make, with the equivalent command
+type planAction struct {
+	Description string
+	Command     []string
+}
+
+// plannedStep is a step as it appears in the plan
+type plannedStep struct {
+	ID      string
+	Name    string
+	Skipped bool
+	Reason  string // Why the step is skipped
+	Actions []planAction
+}
+
+// buildPlan lists the actions each bootstrap step would perform for cfg. Steps that
+// have nothing to do for this config are marked as skipped.
+func buildPlan(cfg *Config) []plannedStep {
+	plan := make([]plannedStep, 0, len(bootstrapSteps))
+	for _, step := range bootstrapSteps {
+		ps := plannedStep{ID: step.ID, Name: step.Name}
+		switch {
+		case cfg.isStepDisabled(step.ID):
+			ps.Skipped, ps.Reason = true, "disabled"
+		case step.Plan != nil:
+			ps.Actions = step.Plan(cfg)
+		}
+		if len(ps.Actions) == 0 && !ps.Skipped {
+			ps.Skipped, ps.Reason = true, "nothing to do for this config"
+			if cfg.assumes(step.ID) {
+				ps.Reason = fmt.Sprintf("assumed %s=%s", step.ID, cfg.Assumptions[step.ID])
+			}
+		}
+		plan = append(plan, ps)
+	}
+	return plan
+}
+
+// renderPlan writes the plan in the requested format; explain adds each step's rationale,
+// required permissions and security considerations
+func renderPlan(w io.Writer, cfg *Config, format string, explain bool) error {
+	plan := buildPlan(cfg)
+	switch format {
+	case planFormatText:
+		renderPlanText(w, cfg, plan, explain)
+	case planFormatGitHub:
+		renderPlanGitHub(w, cfg, plan, explain)
+	default:
+		return fmt.Errorf("unsupported plan format '%s' (use '%s' or '%s')", format, planFormatText, planFormatGitHub)
+	}
+	return nil
+}
+
+// renderPlanText writes the plan for terminal output
+func renderPlanText(w io.Writer, cfg *Config, plan []plannedStep, explain bool) {
+	fmt.Fprintln(w, "-----------------------------------------------------")
+	fmt.Fprintf(w, " GCP Bootstrap Plan for project '%s'\n", cfg.ProjectID)
+	fmt.Fprintln(w, "-----------------------------------------------------")
+	for _, ps := range plan {
+		if ps.Skipped {
+			fmt.Fprintf(w, " [skip] %s (%s)\n", ps.Name, ps.Reason)
+			continue
+		}
+		fmt.Fprintf(w, " [run]  %s\n", ps.Name)
+		if explain {
+			writeStepExplanation(w, cfg, "        ", ps.ID)
+		}
+		for _, a := range ps.Actions {
+			fmt.Fprintf(w, "        - %s\n", a.Description)
+			if len(a.Command) > 0 {
+				fmt.Fprintf(w, "          $ %s\n", shellJoin(a.Command))
+			}
+		}
+	}
+	fmt.Fprintln(w, "-----------------------------------------------------")
+	fmt.Fprintln(w, " Existing resources are detected at run time and left unchanged.")
+	if len(cfg.Assumptions) > 0 {
+		fmt.Fprintf(w, " Built without reading GCP, assuming %s.\n", joinLabels(cfg.Assumptions))
+	}
+}
+
+// renderPlanGitHub writes the plan as GitHub-flavored Markdown with a collapsible
+// section per step, suitable for posting to pull requests
+func renderPlanGitHub(w io.Writer, cfg *Config, plan []plannedStep, explain bool) {
+	run := 0
+	for _, ps := range plan {
+		if !ps.Skipped {
+			run++
+		}
+	}
+	fmt.Fprintf(w, "## :rocket: GCP Bootstrap Plan for `%s`\n\n", cfg.ProjectID)
+	fmt.Fprintf(w, "**%d** step(s) to run, **%d** skipped. Region `%s`, state bucket `gs://%s`.\n\n", run, len(plan)-run, cfg.ProjectRegion, cfg.TFStateBucketName)
+	for _, ps := range plan {
+		if ps.Skipped {
+			fmt.Fprintf(w, ":fast_forward: ~~%s~~ (skipped: %s)\n\n", ps.Name, ps.Reason)
+			continue
+		}
+		fmt.Fprintf(w, "<details>\n<summary>:white_check_mark: <b>%s</b> (%d action(s))</summary>\n\n", ps.Name, len(ps.Actions))
+		if e, ok := stepExplanations[ps.ID]; ok && explain {
+			fmt.Fprintf(w, "> **Why:** %s<br>\n> **Permissions:** `%s`<br>\n> **Security:** %s\n\n", e.Purpose, strings.Join(permissionNames(cfg, ps.ID), "`, `"), e.Security)
+		}
+		for _, a := range ps.Actions {
+			fmt.Fprintf(w, "- %s\n", a.Description)
+			if len(a.Command) > 0 {
+				fmt.Fprintf(w, "  ```sh\n  %s\n  ```\n", shellJoin(a.Command))
+			}
+		}
+		fmt.Fprint(w, "\n</details>\n\n")
+	}
+	fmt.Fprintln(w, "_Existing resources are detected at run time and left unchanged._")
+	if len(cfg.Assumptions) > 0 {
+		fmt.Fprintf(w, "\n_Built without reading GCP, assuming `%s`._\n", joinLabels(cfg.Assumptions))
+	}
+}
+
+// shellJoin renders a command line, quoting arguments that contain spaces or shell metacharacters
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t'\"$*()&;|<>") {
+			quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		} else {
+			quoted[i] = a
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// --- Per-step plans ---
+
+func planFolders(cfg *Config) []planAction {
+	actions := planFolderLevel(cfg.Folders, "")
+	if cfg.FolderPath != "" {
+		path := ""
+		for _, name := range strings.Split(cfg.FolderPath, "/") {
+			path += "/" + name
+			actions = append(actions, planAction{Description: fmt.Sprintf("Create folder '%s' if it does not exist", path)})
+		}
+		actions = append(actions, planAction{Description: fmt.Sprintf("Use folder '%s' as the project's parent", path)})
+	}
+	return actions
+}
+
+func planFolderLevel(folders []FolderConfig, path string) []planAction {
+	var actions []planAction
+	for _, f := range folders {
+		folderPath := path + "/" + f.Name
+		actions = append(actions, planAction{Description: fmt.Sprintf("Create folder '%s' if it does not exist", folderPath)})
+		for _, role := range sortedKeys(f.IAM) {
+			actions = append(actions, planAction{Description: fmt.Sprintf("Grant '%s' on folder '%s' to %s", role, folderPath, strings.Join(f.IAM[role], ", "))})
+		}
+		actions = append(actions, planFolderLevel(f.Children, folderPath)...)
+	}
+	return actions
+}
+
+func planProject(cfg *Config) []planAction {
+	var actions []planAction
+	if !cfg.assumes("project") {
+		args := append([]string{"gcloud"}, projectCreateArgs(cfg)...)
+		actions = append(actions, planAction{Description: fmt.Sprintf("Create project '%s' if it does not exist", cfg.ProjectID), Command: args})
+	}
+	if labels := cfg.projectLabels(); len(labels) > 0 {
+		actions = append(actions, planAction{
+			Description: "Add or update the configured labels that differ on an existing project",
+			Command:     []string{"gcloud", "projects", "update", cfg.ProjectID, "--update-labels", joinLabels(labels)},
+		})
+	}
+	return actions
+}
+
+func planBilling(cfg *Config) []planAction {
+	if cfg.assumes("billing") {
+		return nil
+	}
+	account := cfg.BillingAccountID
+	var actions []planAction
+	if sub := cfg.BillingLink.Subaccount; sub.Enabled {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Create billing subaccount '%s' under '%s' unless one with that name exists (Cloud Billing API)", sub.DisplayName, cfg.BillingAccountID),
+		})
+		account = "<subaccount-id>"
+	}
+	return append(actions, planAction{
+		Description: fmt.Sprintf("Link billing account '%s' if not already linked (up to %d attempts with backoff from %s)", account, cfg.BillingLink.Attempts, cfg.BillingLink.InitialBackoff),
+		Command:     []string{"gcloud", "beta", "billing", "projects", "link", cfg.ProjectID, "--billing-account", account},
+	})
+}
+
+func planAPIs(cfg *Config) []planAction {
+	if len(cfg.EnableAPIs) == 0 || cfg.assumes("apis") {
+		return nil
+	}
+	args := append([]string{"gcloud", "services", "enable"}, cfg.EnableAPIs...)
+	args = append(args, "--project", cfg.ProjectID, "--async")
+	return []planAction{{Description: fmt.Sprintf("Enable those of %d API(s) not already enabled", len(cfg.EnableAPIs)), Command: args}}
+}
+
+func planServiceAccount(cfg *Config) []planAction {
+	var actions []planAction
+	if !cfg.assumes("service_account") {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Create Terraform service account '%s' if it does not exist", cfg.TFServiceAccountEmail),
+			Command:     append([]string{"gcloud"}, serviceAccountCreateArgs(cfg)...),
+		})
+	}
+	if description := cfg.TFServiceAccountMetadata.description(); description != "" {
+		actions = append(actions, planAction{
+			Description: "Update the description of an existing service account if its metadata differs",
+			Command:     []string{"gcloud", "iam", "service-accounts", "update", cfg.TFServiceAccountEmail, "--description", description, "--project", cfg.seedProject()},
+		})
+	}
+	return actions
+}
+
+func planIAMRoles(cfg *Config) []planAction {
+	member := fmt.Sprintf("serviceAccount:%s", cfg.TFServiceAccountEmail)
+	var actions []planAction
+	for _, role := range cfg.TFServiceAccountProjectRoles {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Grant project role '%s' to the Terraform SA", role),
+			Command:     []string{"gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID, "--member", member, "--role", role, "--condition=None"},
+		})
+	}
+	if cfg.TFServiceAccountBillingRole != "" {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Grant billing role '%s' to the Terraform SA", cfg.TFServiceAccountBillingRole),
+			Command:     []string{"gcloud", "beta", "billing", "accounts", "add-iam-policy-binding", cfg.BillingAccountID, "--member", member, "--role", cfg.TFServiceAccountBillingRole},
+		})
+	}
+	return actions
+}
+
+func planOrgIAMRoles(cfg *Config) []planAction {
+	if cfg.Mode != bootstrapModeOrg {
+		return nil
+	}
+	var actions []planAction
+	for _, role := range cfg.OrgBootstrap.TFSAOrgRoles {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Grant organization role '%s' to the Terraform SA", role),
+			Command:     []string{"gcloud", "organizations", "add-iam-policy-binding", cfg.OrganizationID, "--member", "serviceAccount:" + cfg.TFServiceAccountEmail, "--role", role, "--condition=None"},
+		})
+	}
+	return actions
+}
+
+func planWIF(cfg *Config) []planAction {
+	if !cfg.WIF.enabled() {
+		return nil
+	}
+	actions := []planAction{
+		{Description: "Enable Workload Identity Federation APIs", Command: append(append([]string{"gcloud", "services", "enable"}, wifRequiredAPIs...), "--project", cfg.seedProject())},
+		{Description: fmt.Sprintf("Create workload identity pool '%s' if it does not exist", cfg.WIF.PoolID)},
+	}
+	if gl := cfg.WIF.GitLab; gl.Enabled {
+		actions = append(actions,
+			planAction{Description: fmt.Sprintf("Create or update GitLab OIDC provider '%s' (issuer %s, condition %s)", gl.ProviderID, gl.IssuerURI, gitlabAttributeCondition(gl))},
+			planAction{Description: fmt.Sprintf("Allow GitLab project '%s' to impersonate the Terraform SA", gl.ProjectPath)},
+		)
+	}
+	if gh := cfg.WIF.GitHub; gh.Enabled {
+		actions = append(actions,
+			planAction{Description: fmt.Sprintf("Create or update GitHub Actions OIDC provider '%s' (issuer %s, condition %s)", gh.ProviderID, githubIssuerURI, githubAttributeCondition(gh))},
+			planAction{Description: fmt.Sprintf("Allow GitHub repository '%s' to impersonate the Terraform SA", gh.Repository)},
+		)
+	}
+	if tfc := cfg.WIF.TerraformCloud; tfc.Enabled {
+		actions = append(actions,
+			planAction{Description: fmt.Sprintf("Create or update Terraform Cloud OIDC provider '%s' (issuer %s, condition %s)", tfc.ProviderID, tfc.IssuerURI, terraformCloudAttributeCondition(tfc))},
+			planAction{Description: fmt.Sprintf("Allow Terraform Cloud organization '%s' to impersonate the Terraform SA", tfc.Organization)},
+		)
+	}
+	for _, pc := range cfg.WIF.Providers {
+		actions = append(actions, planAction{Description: fmt.Sprintf("Create or update OIDC provider '%s' (issuer %s)", pc.ID, pc.IssuerURI)})
+		for _, sa := range pc.ServiceAccounts {
+			actions = append(actions, planAction{Description: fmt.Sprintf("Allow %s to impersonate '%s'", strings.Join(pc.Principals, ", "), sa)})
+		}
+	}
+	return actions
+}
+
+func planOpsServiceAccount(cfg *Config) []planAction {
+	ops := cfg.OpsServiceAccount
+	if !ops.Enabled {
+		return nil
+	}
+	actions := []planAction{{
+		Description: fmt.Sprintf("Create ops service account '%s' if it does not exist", ops.Email),
+		Command:     []string{"gcloud", "iam", "service-accounts", "create", ops.Name, "--display-name", "Ops Observability Service Account", "--project", cfg.ProjectID},
+	}}
+	for _, role := range ops.Roles {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Grant project role '%s' to the ops SA", role),
+			Command:     []string{"gcloud", "projects", "add-iam-policy-binding", cfg.ProjectID, "--member", "serviceAccount:" + ops.Email, "--role", role, "--condition=None"},
+		})
+	}
+	if ops.WIFPrincipal != "" {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Allow '%s' to impersonate the ops SA", ops.WIFPrincipal),
+			Command:     []string{"gcloud", "iam", "service-accounts", "add-iam-policy-binding", ops.Email, "--member", ops.WIFPrincipal, "--role", "roles/iam.workloadIdentityUser", "--project", cfg.ProjectID},
+		})
+	}
+	return actions
+}
+
+func planFleet(cfg *Config) []planAction {
+	fleet := cfg.Fleet
+	if !fleet.Enabled {
+		return nil
+	}
+	actions := []planAction{
+		{Description: "Enable the GKE Hub API", Command: []string{"gcloud", "services", "enable", "gkehub.googleapis.com", "--project", cfg.ProjectID}},
+		{Description: fmt.Sprintf("Grant roles/gkehub.crossProjectServiceAgent to the GKE Hub service agent of '%s'", fleet.HostProjectID)},
+	}
+	for _, role := range fleet.TFSAHostRoles {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Grant role '%s' on fleet host project to the Terraform SA", role),
+			Command:     []string{"gcloud", "projects", "add-iam-policy-binding", fleet.HostProjectID, "--member", "serviceAccount:" + cfg.TFServiceAccountEmail, "--role", role, "--condition=None"},
+		})
+	}
+	return actions
+}
+
+func planDomainRestrictedSharing(cfg *Config) []planAction {
+	drs := cfg.DomainRestrictedSharing
+	if !drs.Enabled {
+		return nil
+	}
+	args := append([]string{"gcloud", "resource-manager", "org-policies", "allow", allowedPolicyMemberDomainsConstraint}, drs.CustomerIDs...)
+	args = append(args, "--project", cfg.seedProject())
+	return []planAction{{Description: "Restrict IAM members to customers " + strings.Join(drs.CustomerIDs, ", "), Command: args}}
+}
+
+func planBucket(cfg *Config) []planAction {
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	var actions []planAction
+	if !cfg.assumes("bucket") {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Create state bucket '%s' in %s '%s' if it does not exist", bucketURL, bucketLocationType(cfg.TFStateBucketLocation), cfg.TFStateBucketLocation),
+			Command:     append([]string{"gcloud"}, bucketCreateArgs(cfg)...),
+		})
+	}
+	if cfg.TFStateBucketRepairUBLA {
+		actions = append(actions, planAction{
+			Description: "On an existing bucket using legacy ACLs, grant its user, group and domain ACL entries the matching legacy bucket IAM roles and enable uniform bucket-level access",
+			Command:     []string{"gcloud", "storage", "buckets", "update", bucketURL, "--uniform-bucket-level-access", "--project", cfg.seedProject()},
+		})
+	}
+	actions = append(actions, planAction{
+		Description: "Enforce public access prevention on an existing bucket if it is not enforced",
+		Command:     []string{"gcloud", "storage", "buckets", "update", bucketURL, "--public-access-prevention", "--project", cfg.seedProject()},
+	})
+	if cfg.TFStateBucketAutoclass {
+		actions = append(actions, planAction{
+			Description: "Enable Autoclass on an existing bucket if it is not enabled",
+			Command:     []string{"gcloud", "storage", "buckets", "update", bucketURL, "--enable-autoclass", "--project", cfg.seedProject()},
+		})
+	}
+	if d := cfg.TFStateBucketSoftDelete; d != "" {
+		command := []string{"gcloud", "storage", "buckets", "update", bucketURL, "--soft-delete-duration", d, "--project", cfg.seedProject()}
+		if d == "0" {
+			command = []string{"gcloud", "storage", "buckets", "update", bucketURL, "--clear-soft-delete", "--project", cfg.seedProject()}
+		}
+		actions = append(actions, planAction{Description: "Set the soft delete duration of an existing bucket if it differs", Command: command})
+	}
+	if len(cfg.TFStateBucketLabels) > 0 {
+		actions = append(actions, planAction{
+			Description: "Add or update the configured labels that differ on the bucket",
+			Command:     []string{"gcloud", "storage", "buckets", "update", bucketURL, "--update-labels", joinLabels(cfg.TFStateBucketLabels), "--project", cfg.seedProject()},
+		})
+	}
+	return actions
+}
+
+func planBucketKMS(cfg *Config) []planAction {
+	kms := cfg.KMS
+	if !kms.Enabled {
+		return nil
+	}
+	return []planAction{
+		{Description: "Enable cloudkms.googleapis.com if not already enabled", Command: []string{"gcloud", "services", "enable", "cloudkms.googleapis.com", "--project", cfg.seedProject()}},
+		{Description: fmt.Sprintf("Create key ring '%s' in '%s' if it does not exist", kms.KeyRing, kmsLocation(cfg)), Command: []string{"gcloud", "kms", "keyrings", "create", kms.KeyRing, "--location", kmsLocation(cfg), "--project", cfg.seedProject()}},
+		{Description: fmt.Sprintf("Create key '%s' (rotated every %s) if it does not exist", kms.Key, kms.RotationPeriod), Command: []string{"gcloud", "kms", "keys", "create", kms.Key, "--keyring", kms.KeyRing, "--location", kmsLocation(cfg), "--purpose", "encryption", "--project", cfg.seedProject()}},
+		{Description: "Grant the Cloud Storage service agent roles/cloudkms.cryptoKeyEncrypterDecrypter on the key"},
+		{Description: "Make the key the state bucket's default encryption key", Command: []string{"gcloud", "storage", "buckets", "update", "gs://" + cfg.TFStateBucketName, "--default-encryption-key", kmsKeyName(cfg), "--project", cfg.seedProject()}},
+	}
+}
+
+func planBucketLifecycle(cfg *Config) []planAction {
+	lc := cfg.TFStateBucketLifecycle
+	if !lc.enabled() {
+		return nil
+	}
+	var rules []string
+	if lc.MaxNoncurrentVersions > 0 {
+		rules = append(rules, fmt.Sprintf("keep at most %d noncurrent version(s)", lc.MaxNoncurrentVersions))
+	}
+	if lc.NoncurrentAgeDays > 0 {
+		rules = append(rules, fmt.Sprintf("delete versions noncurrent for %d day(s)", lc.NoncurrentAgeDays))
+	}
+	return []planAction{{
+		Description: fmt.Sprintf("Replace the state bucket's lifecycle rules: %s", strings.Join(rules, ", ")),
+		Command:     []string{"gcloud", "storage", "buckets", "update", "gs://" + cfg.TFStateBucketName, "--lifecycle-file", "<generated policy>", "--project", cfg.seedProject()},
+	}}
+}
+
+func planBucketRetention(cfg *Config) []planAction {
+	if cfg.TFStateBucketRetention == "" {
+		return nil
+	}
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	actions := []planAction{{
+		Description: fmt.Sprintf("Set a retention period of %s on the state bucket unless its policy is locked", cfg.TFStateBucketRetention),
+		Command:     []string{"gcloud", "storage", "buckets", "update", bucketURL, "--retention-period", cfg.TFStateBucketRetention, "--project", cfg.seedProject()},
+	}}
+	if cfg.TFStateBucketRetentionLock {
+		actions = append(actions, planAction{
+			Description: "PERMANENTLY lock the retention policy after typed confirmation of the bucket name",
+			Command:     []string{"gcloud", "storage", "buckets", "update", bucketURL, "--lock-retention-period", "--project", cfg.seedProject(), "--quiet"},
+		})
+	}
+	return actions
+}
+
+func planBucketVersioning(cfg *Config) []planAction {
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	return []planAction{{
+		Description: "Enable object versioning on the state bucket if not already enabled",
+		Command:     []string{"gcloud", "storage", "buckets", "update", bucketURL, "--versioning", "--project", cfg.seedProject()},
+	}}
+}
+
+func planBucketIAM(cfg *Config) []planAction {
+	if cfg.TFStateBucketSARole == "" {
+		return nil
+	}
+	return []planAction{{
+		Description: fmt.Sprintf("Grant '%s' on the state bucket to the Terraform service account", cfg.TFStateBucketSARole),
+		Command:     []string{"gcloud", "storage", "buckets", "add-iam-policy-binding", fmt.Sprintf("gs://%s", cfg.TFStateBucketName), "--member", "serviceAccount:" + cfg.TFServiceAccountEmail, "--role", cfg.TFStateBucketSARole, "--project", cfg.seedProject()},
+	}}
+}
+
+func planSAKey(cfg *Config) []planAction {
+	if !cfg.GenerateTFSAKey {
+		return nil
+	}
+	if cfg.SAKeyMode == saKeyModeUpload {
+		return []planAction{{
+			Description: fmt.Sprintf("Upload the public key '%s' to the Terraform SA", cfg.TFSAPublicKey),
+			Command:     []string{"gcloud", "iam", "service-accounts", "keys", "upload", cfg.TFSAPublicKey, "--iam-account", cfg.TFServiceAccountEmail, "--project", cfg.seedProject()},
+		}}
+	}
+	if cfg.keyInKeychain() {
+		return []planAction{{
+			Description: fmt.Sprintf("Create a JSON key for the Terraform SA and store it in the OS keychain as '%s'", keychainService+"/"+cfg.TFServiceAccountEmail),
+			Command:     []string{"gcloud", "iam", "service-accounts", "keys", "create", "<temporary file>", "--iam-account", cfg.TFServiceAccountEmail, "--project", cfg.seedProject()},
+		}}
+	}
+	return []planAction{{
+		Description: fmt.Sprintf("Create a JSON key for the Terraform SA at '%s'", cfg.TFSAKeyPath),
+		Command:     []string{"gcloud", "iam", "service-accounts", "keys", "create", cfg.TFSAKeyPath, "--iam-account", cfg.TFServiceAccountEmail, "--project", cfg.seedProject()},
+	}}
+}
+
+func planSAKeyCleanup(cfg *Config) []planAction {
+	kc := cfg.SAKeyCleanup
+	if !kc.Enabled {
+		return nil
+	}
+	description := fmt.Sprintf("Delete user-managed keys of the Terraform SA older than %d days", kc.MaxAgeDays)
+	if kc.KeepNewest {
+		description = "Delete all user-managed keys of the Terraform SA but the newest"
+	}
+	return []planAction{{
+		Description: description,
+		Command:     []string{"gcloud", "iam", "service-accounts", "keys", "list", "--iam-account", cfg.TFServiceAccountEmail, "--managed-by", "user", "--project", cfg.seedProject()},
+	}}
+}
+
+func planGitHubSecrets(cfg *Config) []planAction {
+	gs := cfg.GitHubSecrets
+	if !gs.Enabled {
+		return nil
+	}
+	var actions []planAction
+	if cfg.writesPrivateKey() {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Upload the SA key as secret %s to '%s'", githubSecretCredentials, gs.Repository),
+			Command:     []string{"gh", "secret", "set", githubSecretCredentials, "--repo", gs.Repository},
+		})
+		if !gs.KeepLocalKey && !cfg.keyInKeychain() {
+			actions = append(actions, planAction{Description: fmt.Sprintf("Remove the local SA key '%s'", cfg.TFSAKeyPath)})
+		}
+	}
+	if cfg.WIF.GitHub.Enabled {
+		actions = append(actions, planAction{
+			Description: fmt.Sprintf("Set variable %s on '%s'", githubVariableProvider, gs.Repository),
+			Command:     []string{"gh", "variable", "set", githubVariableProvider, "--repo", gs.Repository},
+		})
+	}
+	return append(actions, planAction{
+		Description: fmt.Sprintf("Set variable %s on '%s'", githubVariableSA, gs.Repository),
+		Command:     []string{"gh", "variable", "set", githubVariableSA, "--repo", gs.Repository},
+	})
+}
+
+func planTerraformFiles(cfg *Config) []planAction {
+	var actions []planAction
+	for _, f := range terraformFiles(cfg, "<project-number>") {
+		actions = append(actions, planAction{Description: fmt.Sprintf("Write '%s' unless it was modified", f.Path)})
+	}
+	return actions
+}
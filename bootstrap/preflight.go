@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// regionPattern matches GCP region names such as europe-west1 or us-central1
+var regionPattern = regexp.MustCompile(`^[a-z]+(-[a-z]+)+[0-9]+$`)
+
+// Multi-regions and predefined dual-regions accepted for the state bucket, mapped to the
+// Cloud KMS location holding keys for buckets there
+var (
+	multiRegionKMSLocations = map[string]string{"US": "us", "EU": "europe", "ASIA": "asia"}
+	dualRegionKMSLocations  = map[string]string{"ASIA1": "asia1", "EUR4": "eur4", "NAM4": "nam4"}
+)
+
+// normalizeBucketLocation validates a bucket location and returns it in canonical case:
+// regions lowercase, multi- and dual-regions uppercase
+func normalizeBucketLocation(location string) (string, error) {
+	upper := strings.ToUpper(location)
+	if _, ok := multiRegionKMSLocations[upper]; ok {
+		return upper, nil
+	}
+	if _, ok := dualRegionKMSLocations[upper]; ok {
+		return upper, nil
+	}
+	if lower := strings.ToLower(location); regionPattern.MatchString(lower) {
+		return lower, nil
+	}
+	return "", fmt.Errorf("'%s' is not a region (e.g. 'europe-west1'), multi-region (US, EU, ASIA) or predefined dual-region (ASIA1, EUR4, NAM4)", location)
+}
+
+// bucketLocationType describes a normalized bucket location for logs and the summary
+func bucketLocationType(location string) string {
+	switch {
+	case multiRegionKMSLocations[location] != "":
+		return "multi-region"
+	case dualRegionKMSLocations[location] != "":
+		return "dual-region"
+	}
+	return "region"
+}
+
+// checkLocations validates that the configured locations are well-formed and compatible
+// with resources that already exist, so mismatches surface before anything is created
+// rather than deep in the run
+func checkLocations(ctx context.Context, cfg *Config) error {
+	logInfo("Checking location configuration...")
+	if !regionPattern.MatchString(cfg.ProjectRegion) {
+		return fmt.Errorf("project_region '%s' is not a valid GCP region (expected e.g. 'europe-west1')", cfg.ProjectRegion)
+	}
+
+	// A bucket named after the number of a project yet to be created cannot exist yet
+	if cfg.Assumptions != nil || cfg.bucketNamePending() {
+		return nil
+	}
+	// An existing state bucket keeps its location forever; flag it if it differs from config
+	location, err := bucketLocation(ctx, cfg.TFStateBucketName, cfg.seedProject())
+	if err != nil {
+		logWarning("Could not check location of existing bucket 'gs://%s': %v", cfg.TFStateBucketName, err)
+		return nil
+	}
+	if location != "" && !strings.EqualFold(location, cfg.TFStateBucketLocation) {
+		msg := fmt.Sprintf("existing bucket 'gs://%s' is located in '%s' but config expects '%s'; bucket locations cannot be changed, use 'gcp-bootstrap migrate-bucket -to NEW_BUCKET' to move the state", cfg.TFStateBucketName, location, cfg.TFStateBucketLocation)
+		if cfg.Strict {
+			return errors.New(msg)
+		}
+		logWarning("Location mismatch: %s.", msg)
+		return nil
+	}
+	logInfo("Location configuration is consistent.")
+	return nil
+}
+
+// checkRoles verifies that every configured IAM role exists in the live roles catalog,
+// so typos fail up front instead of surfacing as warnings during the grant steps.
+// Roles that cannot be checked (e.g. missing permissions) only produce a warning.
+func checkRoles(ctx context.Context, cfg *Config) error {
+	logInfo("Checking configured IAM roles...")
+	var unknown []string
+	for _, role := range configuredRoles(cfg) {
+		args := []string{"iam", "roles", "describe"}
+		switch {
+		case strings.HasPrefix(role, "projects/"), strings.HasPrefix(role, "organizations/"):
+			// Custom role: projects/P/roles/R or organizations/O/roles/R
+			parts := strings.Split(role, "/")
+			if len(parts) != 4 || parts[2] != "roles" {
+				unknown = append(unknown, role)
+				continue
+			}
+			args = append(args, parts[3], "--"+strings.TrimSuffix(parts[0], "s"), parts[1])
+		default:
+			args = append(args, role)
+		}
+		_, err := runCommandGetOutput(ctx, "gcloud", append(args, "--format=value(name)")...)
+		if err == nil {
+			continue
+		}
+		if strings.Contains(err.Error(), "NOT_FOUND") || strings.Contains(err.Error(), "INVALID_ARGUMENT") {
+			unknown = append(unknown, role)
+			continue
+		}
+		logWarning("Could not verify role '%s': %v", role, err)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown IAM role(s): %s", strings.Join(unknown, ", "))
+	}
+	logInfo("All configured IAM roles exist.")
+	return nil
+}
+
+// configuredRoles returns every IAM role referenced by cfg, without duplicates
+func configuredRoles(cfg *Config) []string {
+	var roles []string
+	seen := map[string]bool{}
+	add := func(rs ...string) {
+		for _, r := range rs {
+			if r != "" && !seen[r] {
+				seen[r] = true
+				roles = append(roles, r)
+			}
+		}
+	}
+	add(cfg.TFServiceAccountProjectRoles...)
+	add(cfg.TFServiceAccountBillingRole)
+	add(cfg.TFStateBucketSARole)
+	if cfg.Mode == bootstrapModeOrg {
+		add(cfg.OrgBootstrap.TFSAOrgRoles...)
+	}
+	if cfg.OpsServiceAccount.Enabled {
+		add(cfg.OpsServiceAccount.Roles...)
+	}
+	if cfg.Fleet.Enabled {
+		add(cfg.Fleet.TFSAHostRoles...)
+	}
+	var addFolders func(folders []FolderConfig)
+	addFolders = func(folders []FolderConfig) {
+		for _, f := range folders {
+			add(sortedKeys(f.IAM)...)
+			addFolders(f.Children)
+		}
+	}
+	addFolders(cfg.Folders)
+	return roles
+}
@@ -0,0 +1,22 @@
+//go:build !windows && !nogcloud
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command as the leader of a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup terminates the command and every process in its group
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	// A negative PID signals the whole process group
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,18 @@
+//go:build windows && !nogcloud
+
+package main
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows; child processes are killed individually
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup terminates the command process
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
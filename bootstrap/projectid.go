@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// projectIDPrefixLabel marks projects whose ID was generated from project_id_prefix, so
+// re-runs find and reuse the project instead of generating another ID
+const projectIDPrefixLabel = "gcp-bootstrap-id-prefix"
+
+// Generated project IDs are <prefix>-<suffix>; project IDs are at most 30 characters
+const (
+	projectIDSuffixLength = 6
+	projectIDCandidates   = 5
+	maxProjectIDLength    = 30
+)
+
+// projectIDPrefixPattern matches prefixes that yield valid project IDs
+var projectIDPrefixPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*[a-z0-9]$`)
+
+// configOffline is set with --assume: loadConfig must not read GCP
+var configOffline bool
+
+// projectIDSuffixPlaceholder stands in for the generated suffix until the project step
+// resolves project_id_prefix, so reading a config never calls GCP or invents an ID
+const projectIDSuffixPlaceholder = "<suffix>"
+
+// validateProjectIDPrefix checks that prefix plus a generated suffix is a valid project ID
+func validateProjectIDPrefix(prefix string) error {
+	if !projectIDPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("project_id_prefix '%s' must start with a lowercase letter and contain only lowercase letters, digits and hyphens, not ending with a hyphen", prefix)
+	}
+	if max := maxProjectIDLength - projectIDSuffixLength - 1; len(prefix) > max {
+		return fmt.Errorf("project_id_prefix '%s' is longer than %d characters", prefix, max)
+	}
+	return nil
+}
+
+// projectIDPending reports whether the project ID is still the placeholder for an ID to
+// be generated from project_id_prefix
+func (c *Config) projectIDPending() bool {
+	return c.ProjectIDPrefix != "" && c.ProjectID == c.ProjectIDPrefix+"-"+projectIDSuffixPlaceholder
+}
+
+// setProjectID replaces the placeholder project ID by id, in the fields derived from it too
+func (c *Config) setProjectID(id string) {
+	placeholder := c.ProjectID
+	c.ProjectID = id
+	c.TFServiceAccountEmail = strings.ReplaceAll(c.TFServiceAccountEmail, placeholder, id)
+	c.OpsServiceAccount.Email = strings.ReplaceAll(c.OpsServiceAccount.Email, placeholder, id)
+	c.TFStateBucketName = strings.ReplaceAll(c.TFStateBucketName, placeholder, id)
+}
+
+// resolveProjectIDPrefix sets the project ID generated from project_id_prefix: the ID of
+// the project an earlier run generated, found by its label, or else a new
+// <prefix>-<random suffix> that no visible project uses. Projects of other organizations
+// are invisible, so a taken ID can still surface when the project is created; that run
+// fails and a re-run tries new candidates.
+func resolveProjectIDPrefix(ctx context.Context, cfg *Config) error {
+	if !cfg.projectIDPending() {
+		return nil
+	}
+	prefix := cfg.ProjectIDPrefix
+	id, err := generatedProjectID(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		logInfo("Using project '%s' generated from project_id_prefix '%s' by an earlier run.", id, prefix)
+	}
+	for i := 0; id == "" && i < projectIDCandidates; i++ {
+		candidate := prefix + "-" + randomProjectIDSuffix()
+		exists, err := projectExists(ctx, candidate)
+		if err != nil {
+			return err
+		}
+		if exists {
+			logInfo("Project ID '%s' is taken; trying another suffix.", candidate)
+			continue
+		}
+		logNotice("Generated project ID '%s' from project_id_prefix '%s'.", candidate, prefix)
+		id = candidate
+	}
+	if id == "" {
+		return fmt.Errorf("no available project ID found for project_id_prefix '%s' after %d attempts", prefix, projectIDCandidates)
+	}
+	cfg.setProjectID(id)
+	if err := runCommand(ctx, "gcloud", "config", "set", "project", id); err != nil {
+		return fmt.Errorf("failed to set gcloud project context: %w", err)
+	}
+	return nil
+}
+
+// resolveExistingProjectID sets the project ID generated from project_id_prefix by an
+// earlier run, for subcommands that work on the existing project
+func resolveExistingProjectID(ctx context.Context, cfg *Config) error {
+	if !cfg.projectIDPending() {
+		return nil
+	}
+	id, err := generatedProjectID(ctx, cfg.ProjectIDPrefix)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("no project has been generated from project_id_prefix '%s' yet; run the bootstrap first", cfg.ProjectIDPrefix)
+	}
+	cfg.setProjectID(id)
+	return nil
+}
+
+// generatedProjectID returns the ID of the project labelled as generated from prefix, or ""
+// if there is none
+func generatedProjectID(ctx context.Context, prefix string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	output, err := runCommandGetOutput(ctx, "gcloud", "projects", "list",
+		"--filter", fmt.Sprintf("labels.%s=%s", projectIDPrefixLabel, prefix), "--format=value(project_id)", "--quiet")
+	if err != nil {
+		return "", fmt.Errorf("failed to look up projects generated from prefix '%s': %w", prefix, err)
+	}
+	switch ids := strings.Fields(output); len(ids) {
+	case 0:
+		return "", nil
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("several projects were generated from project_id_prefix '%s' (%s); set project_id to the one to use", prefix, strings.Join(ids, ", "))
+	}
+}
+
+// randomProjectIDSuffix returns lowercase letters and digits to append to a project ID prefix
+func randomProjectIDSuffix() string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, projectIDSuffixLength)
+	rand.Read(b)
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b)
+}
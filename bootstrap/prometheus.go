@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pushgatewayJob is the job name runs are pushed under
+const pushgatewayJob = "gcp_bootstrap"
+
+// Error classes of failed steps, so failures can be aggregated without unbounded label values
+const (
+	errorClassPermission = "permission_denied"
+	errorClassNotFound   = "not_found"
+	errorClassQuota      = "quota_exceeded"
+	errorClassTimeout    = "timeout"
+	errorClassCancelled  = "cancelled"
+	errorClassOther      = "other"
+)
+
+// errorClass maps a step error message to one of the error classes
+func errorClass(msg string) string {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "permission_denied"), strings.Contains(lower, "permission denied"), strings.Contains(lower, "does not have permission"), strings.Contains(lower, "403"):
+		return errorClassPermission
+	case strings.Contains(lower, "not_found"), strings.Contains(lower, "not found"), strings.Contains(lower, "404"):
+		return errorClassNotFound
+	case strings.Contains(lower, "quota"), strings.Contains(lower, "resource_exhausted"), strings.Contains(lower, "429"):
+		return errorClassQuota
+	case strings.Contains(lower, "timed out"), strings.Contains(lower, "deadline exceeded"):
+		return errorClassTimeout
+	case strings.Contains(lower, "cancelled"), strings.Contains(lower, "canceled"), strings.Contains(lower, "interrupted"):
+		return errorClassCancelled
+	}
+	return errorClassOther
+}
+
+// labeledReport is a run report with the Prometheus labels identifying its config
+type labeledReport struct {
+	Labels string
+	Report *runReport
+}
+
+// writeRunMetrics renders the outcome of the given runs in the Prometheus text format
+func writeRunMetrics(w io.Writer, reports []labeledReport) {
+	writeMetricHeader(w, "gcp_bootstrap_last_run_success", "gauge", "Whether the last run succeeded (1) or not (0).")
+	for _, r := range reports {
+		success := 0
+		if r.Report.Status == runStatusSucceeded {
+			success = 1
+		}
+		fmt.Fprintf(w, "gcp_bootstrap_last_run_success{%s} %d\n", r.Labels, success)
+	}
+
+	writeMetricHeader(w, "gcp_bootstrap_last_run_timestamp_seconds", "gauge", "Unix time the last run finished.")
+	for _, r := range reports {
+		fmt.Fprintf(w, "gcp_bootstrap_last_run_timestamp_seconds{%s} %d\n", r.Labels, r.Report.FinishedAt.Unix())
+	}
+
+	writeMetricHeader(w, "gcp_bootstrap_last_run_duration_seconds", "gauge", "Duration of the last run.")
+	for _, r := range reports {
+		fmt.Fprintf(w, "gcp_bootstrap_last_run_duration_seconds{%s} %g\n", r.Labels, r.Report.DurationSeconds)
+	}
+
+	writeMetricHeader(w, "gcp_bootstrap_last_run_step_duration_seconds", "gauge", "Duration of each step of the last run, by step and status.")
+	for _, r := range reports {
+		for _, s := range r.Report.Steps {
+			fmt.Fprintf(w, "gcp_bootstrap_last_run_step_duration_seconds{%s,step=%s,status=%s} %g\n", r.Labels, promQuote(s.ID), promQuote(s.Status), s.DurationSeconds)
+		}
+	}
+
+	writeMetricHeader(w, "gcp_bootstrap_last_run_step_errors", "gauge", "Steps of the last run that failed or warned, by step and error class.")
+	for _, r := range reports {
+		for _, s := range r.Report.Steps {
+			if s.Error != "" {
+				fmt.Fprintf(w, "gcp_bootstrap_last_run_step_errors{%s,step=%s,status=%s,error_class=%s} 1\n", r.Labels, promQuote(s.ID), promQuote(s.Status), promQuote(errorClass(s.Error)))
+			}
+		}
+	}
+
+	// Resources a run had to create were missing, so on an established project they are drift
+	writeMetricHeader(w, "gcp_bootstrap_last_run_resources", "gauge", "Resources of the last run by status; 'created' counts drift corrected on established projects.")
+	for _, r := range reports {
+		counts := map[string]int{resourceCreated: 0, resourceExisted: 0}
+		for _, res := range r.Report.Resources {
+			counts[res.Status]++
+		}
+		for _, status := range sortedKeys(counts) {
+			fmt.Fprintf(w, "gcp_bootstrap_last_run_resources{%s,status=%s} %d\n", r.Labels, promQuote(status), counts[status])
+		}
+	}
+
+	writeMetricHeader(w, "gcp_bootstrap_last_run_warnings", "gauge", "Warnings logged by the last run.")
+	for _, r := range reports {
+		fmt.Fprintf(w, "gcp_bootstrap_last_run_warnings{%s} %d\n", r.Labels, len(r.Report.Warnings))
+	}
+}
+
+// exportRunMetrics writes the run's metrics to a node_exporter textfile and/or pushes them
+// to a Pushgateway; empty destinations are skipped and failures only warn
+func exportRunMetrics(cfg *Config, status, textfile, pushgateway string) {
+	if textfile == "" && pushgateway == "" {
+		return
+	}
+	var buf bytes.Buffer
+	writeRunMetrics(&buf, []labeledReport{{Labels: "project_id=" + promQuote(cfg.ProjectID), Report: buildReport(cfg, status)}})
+
+	if textfile != "" {
+		if err := writeMetricsTextfile(textfile, buf.Bytes()); err != nil {
+			logWarning("%v", err)
+		} else {
+			logInfo("Metrics written to '%s'.", textfile)
+		}
+	}
+	if pushgateway != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := pushMetrics(ctx, pushgateway, cfg.ProjectID, buf.Bytes()); err != nil {
+			logWarning("%v", err)
+		} else {
+			logInfo("Metrics pushed to '%s'.", pushgateway)
+		}
+	}
+}
+
+// writeMetricsTextfile replaces path atomically, so the textfile collector never reads a partial file
+func writeMetricsTextfile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gcp-bootstrap-*.prom")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics to '%s': %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write metrics to '%s': %w", path, err)
+	}
+	return nil
+}
+
+// pushMetrics replaces the metrics of this project's group on a Prometheus Pushgateway
+func pushMetrics(ctx context.Context, gateway, projectID string, data []byte) error {
+	target := fmt.Sprintf("%s/metrics/job/%s/project_id/%s", strings.TrimSuffix(gateway, "/"), pushgatewayJob, url.PathEscape(projectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to '%s': %w", gateway, err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to '%s': %w", gateway, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to push metrics to '%s': %s: %s", gateway, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// writeMetricHeader writes the HELP and TYPE lines of a Prometheus metric
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// promQuote quotes a Prometheus label value
+func promQuote(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
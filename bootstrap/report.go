@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Overall run outcomes written to the report
+const (
+	runStatusSucceeded   = "succeeded"
+	runStatusFailed      = "failed"
+	runStatusInterrupted = "interrupted"
+)
+
+// runStatusForExitCode maps the exit code of an unsuccessful run to a run status
+func runStatusForExitCode(code int) string {
+	if code == exitCodeInterrupted || code == exitCodeTimeout {
+		return runStatusInterrupted
+	}
+	return runStatusFailed
+}
+
+// runReport is the machine-readable summary written by --report-json
+type runReport struct {
+	RunID           string            `json:"run_id"`
+	Status          string            `json:"status"`
+	StartedAt       time.Time         `json:"started_at"`
+	FinishedAt      time.Time         `json:"finished_at"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	ConfigHash      string            `json:"config_hash"`
+	ChangeTicket    string            `json:"change_ticket,omitempty"`
+	Environment     string            `json:"environment,omitempty"`
+	ProjectID       string            `json:"project_id"`
+	ProjectIDPrefix string            `json:"project_id_prefix,omitempty"` // Set when the ID was generated
+	ProjectNumber   string            `json:"project_number,omitempty"`
+	Region          string            `json:"region"`
+	TFSAEmail       string            `json:"tf_service_account_email"`
+	OpsSAEmail      string            `json:"ops_service_account_email,omitempty"`
+	StateBucketURL  string            `json:"state_bucket_url"`
+	SAKeyPath       string            `json:"sa_key_path,omitempty"`
+	BreakGlass      *reportBreakGlass `json:"break_glass,omitempty"`
+	Resources       []reportResource  `json:"resources"`
+	Steps           []reportStep      `json:"steps"`
+	Waits           []reportWait      `json:"waits"`
+	Warnings        []string          `json:"warnings"`
+}
+
+type reportResource struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type reportStep struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// reportBreakGlass is the time-bound emergency binding on the project
+type reportBreakGlass struct {
+	Member    string    `json:"member"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Status    string    `json:"status"` // created or existing
+}
+
+type reportWait struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// buildReport assembles the run report from cfg and the collected metrics
+func buildReport(cfg *Config, status string) *runReport {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	now := time.Now()
+	r := &runReport{
+		RunID:           metrics.start.Format(runIDFormat),
+		Status:          status,
+		ConfigHash:      cfg.ConfigHash,
+		ChangeTicket:    cfg.ChangeTicket,
+		Environment:     cfg.Environment,
+		StartedAt:       metrics.start,
+		FinishedAt:      now,
+		DurationSeconds: now.Sub(metrics.start).Seconds(),
+		ProjectID:       cfg.ProjectID,
+		ProjectIDPrefix: cfg.ProjectIDPrefix,
+		Region:          cfg.ProjectRegion,
+		TFSAEmail:       cfg.TFServiceAccountEmail,
+		OpsSAEmail:      cfg.OpsServiceAccount.Email,
+		StateBucketURL:  fmt.Sprintf("gs://%s", cfg.TFStateBucketName),
+		Resources:       []reportResource{},
+		Steps:           []reportStep{},
+		Waits:           []reportWait{},
+		Warnings:        append([]string{}, metrics.warnings...),
+	}
+	if cfg.writesPrivateKey() && !cfg.keyInKeychain() {
+		r.SAKeyPath = cfg.TFSAKeyPath
+	}
+	if bg := cfg.BreakGlass; bg.Status != "" {
+		r.BreakGlass = &reportBreakGlass{Member: bg.member(), Role: bg.Role, ExpiresAt: bg.Expires, Status: bg.Status}
+	}
+	for _, res := range metrics.resources {
+		r.Resources = append(r.Resources, reportResource{Kind: res.Kind, Name: res.Name, Status: res.Status})
+	}
+	for _, s := range metrics.steps {
+		r.Steps = append(r.Steps, reportStep{ID: s.ID, Name: s.Name, Status: s.Status, DurationSeconds: s.Duration.Seconds(), Error: s.Error})
+	}
+	for _, w := range metrics.waits {
+		r.Waits = append(r.Waits, reportWait{Name: w.Name, DurationSeconds: w.Duration.Seconds()})
+	}
+	return r
+}
+
+// writeRunOutputs builds the run report and writes it to reportPath and/or the run
+// history directory; empty destinations are skipped
+func writeRunOutputs(cfg *Config, status, reportPath, historyDir string) {
+	if reportPath == "" && historyDir == "" {
+		return
+	}
+	r := buildReport(cfg, status)
+
+	// The project number is only known once the project exists; look it up best-effort
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if number, err := projectNumber(ctx, cfg.ProjectID); err == nil {
+		r.ProjectNumber = number
+	}
+
+	if reportPath != "" {
+		if err := writeReport(reportPath, r); err != nil {
+			logWarning("%v", err)
+		} else {
+			logInfo("Run report written to '%s'.", reportPath)
+		}
+	}
+	if historyDir != "" {
+		if err := saveRunHistory(historyDir, r); err != nil {
+			logWarning("%v", err)
+		}
+	}
+}
+
+// writeReport writes the JSON run report to path
+func writeReport(path string, r *runReport) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run report to %s: %w", path, err)
+	}
+	return nil
+}
+
+// readReport loads a JSON run report from path
+func readReport(path string) (*runReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run report %s: %w", path, err)
+	}
+	var r runReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse run report %s: %w", path, err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// retentionPattern matches gcloud storage retention durations such as 7d, 1y or 1y6m
+var retentionPattern = regexp.MustCompile(`^([0-9]+[ymdhs])+$`)
+
+// setupBucketRetention sets the state bucket's retention policy and, if configured,
+// locks it after the user confirms by typing the bucket name
+func setupBucketRetention(ctx context.Context, cfg *Config) error {
+	if cfg.TFStateBucketRetention == "" {
+		logInfo("Skipping state bucket retention policy as per config.")
+		return nil
+	}
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	locked, err := isRetentionLocked(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if locked {
+		logInfo("Retention policy of '%s' is already locked; leaving it unchanged.", bucketURL)
+		return nil
+	}
+
+	logInfo("Setting a retention period of %s on '%s'...", cfg.TFStateBucketRetention, bucketURL)
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL,
+		"--retention-period", cfg.TFStateBucketRetention,
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to set the retention period: %w", err)
+	}
+	if !cfg.TFStateBucketRetentionLock {
+		logInfo("Retention period set.")
+		return nil
+	}
+
+	// Locking is irreversible, so --yes does not skip this confirmation
+	logWarning("Locking the retention policy of '%s' is PERMANENT: the period can never be reduced or removed, and the bucket cannot be deleted while it holds objects.", bucketURL)
+	fmt.Fprintf(stdout, "Type the bucket name '%s' to lock its retention policy: ", cfg.TFStateBucketName)
+	input, err := readConfirmation(ctx)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(input) != cfg.TFStateBucketName {
+		return fmt.Errorf("retention policy lock of '%s' not confirmed", bucketURL)
+	}
+	err = runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL,
+		"--lock-retention-period",
+		"--project", cfg.seedProject(),
+		"--quiet")
+	if err != nil {
+		return fmt.Errorf("failed to lock the retention policy: %w", err)
+	}
+	logInfo("Retention policy locked.")
+	return nil
+}
+
+func isRetentionLocked(ctx context.Context, cfg *Config) (bool, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", fmt.Sprintf("gs://%s", cfg.TFStateBucketName), "--format=value(retention_policy.isLocked)", "--project", cfg.seedProject())
+	if err != nil {
+		return false, fmt.Errorf("failed to check the retention policy: %w", err)
+	}
+	return strings.ToLower(output) == "true", nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// roleDescriptions explains in one line what each recommended role lets Terraform manage
+var roleDescriptions = map[string]string{
+	"roles/artifactregistry.admin":            "Artifact Registry repositories and their IAM",
+	"roles/cloudsql.admin":                    "Cloud SQL instances, databases and users",
+	"roles/compute.admin":                     "all Compute Engine resources (VMs, disks, networks, load balancers)",
+	"roles/compute.networkAdmin":              "VPCs, subnets, routes and load balancers, without firewall rules",
+	"roles/compute.securityAdmin":             "firewall rules and SSL certificates",
+	"roles/container.admin":                   "GKE clusters and the Kubernetes API objects in them",
+	"roles/dns.admin":                         "Cloud DNS zones and record sets",
+	"roles/iam.serviceAccountAdmin":           "service accounts and their IAM policies",
+	"roles/iam.serviceAccountUser":            "attaching service accounts to VMs, Cloud Run services and other workloads",
+	"roles/logging.admin":                     "log sinks, buckets, views and exclusions",
+	"roles/monitoring.admin":                  "alert policies, dashboards, uptime checks and notification channels",
+	"roles/pubsub.admin":                      "Pub/Sub topics, subscriptions and their IAM",
+	"roles/resourcemanager.projectIamAdmin":   "the project IAM policy; powerful, as it can grant any role",
+	"roles/run.admin":                         "Cloud Run services and jobs, including their IAM",
+	"roles/secretmanager.admin":               "Secret Manager secrets, versions and their IAM",
+	"roles/servicenetworking.networksAdmin":   "private service access peering (e.g. for Cloud SQL private IP)",
+	"roles/serviceusage.serviceUsageAdmin":    "enabling and disabling APIs (google_project_service)",
+	"roles/serviceusage.serviceUsageConsumer": "using enabled APIs and quota, without enabling new ones",
+	"roles/storage.admin":                     "all GCS buckets and objects, including the state bucket",
+	"roles/storage.objectAdmin":               "objects only, enough to read and write Terraform state",
+}
+
+// rolePickerEntry is a role offered by the picker
+type rolePickerEntry struct {
+	Role        string
+	Description string
+	Selected    bool
+}
+
+// runRolesCommand implements 'roles': an interactive picker of the TF SA's project roles
+// that explains every recommended role and searches the predefined roles catalog. The
+// prompts go to stderr so the resulting config snippet can be redirected into a file.
+func runRolesCommand(args []string) {
+	fs := flag.NewFlagSet("roles", flag.ExitOnError)
+	preset := fs.String("preset", "", "Start with the roles of this role preset selected ("+strings.Join(rolePresetNames(), ", ")+")")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap roles [-preset NAME] > roles.yaml")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	entries := recommendedRoles()
+	if *preset != "" {
+		if err := selectPreset(entries, *preset); err != nil {
+			logError("%v", err)
+		}
+	}
+	entries, err := pickRoles(os.Stdin, os.Stderr, entries)
+	if err != nil {
+		logError("%v", err)
+	}
+	var selected []string
+	for _, e := range entries {
+		if e.Selected {
+			selected = append(selected, e.Role)
+		}
+	}
+	if len(selected) == 0 {
+		logError("No roles selected.")
+	}
+	fmt.Fprintln(stdout, "tf_service_account_project_roles:")
+	for _, role := range selected {
+		fmt.Fprintf(stdout, "  - %q\n", role)
+	}
+}
+
+// recommendedRoles returns the roles of all presets with their explanations, sorted
+func recommendedRoles() []rolePickerEntry {
+	var roles []string
+	for _, name := range rolePresetNames() {
+		for _, role := range rolePresets[name] {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	slices.Sort(roles)
+	entries := make([]rolePickerEntry, 0, len(roles))
+	for _, role := range roles {
+		entries = append(entries, rolePickerEntry{Role: role, Description: roleDescriptions[role]})
+	}
+	return entries
+}
+
+// selectPreset selects the roles of the named preset
+func selectPreset(entries []rolePickerEntry, preset string) error {
+	roles, ok := rolePresets[preset]
+	if !ok {
+		return fmt.Errorf("unknown role preset '%s' (valid presets: %s)", preset, strings.Join(rolePresetNames(), ", "))
+	}
+	for i := range entries {
+		if slices.Contains(roles, entries[i].Role) {
+			entries[i].Selected = true
+		}
+	}
+	return nil
+}
+
+// pickRoles runs the selection loop on in/out until the user is done
+func pickRoles(in io.Reader, out io.Writer, entries []rolePickerEntry) ([]rolePickerEntry, error) {
+	reader := bufio.NewReader(in)
+	var catalog [][2]string
+	for {
+		fmt.Fprintln(out)
+		for i, e := range entries {
+			mark := " "
+			if e.Selected {
+				mark = "x"
+			}
+			fmt.Fprintf(out, " %2d [%s] %-40s %s\n", i+1, mark, e.Role, e.Description)
+		}
+		fmt.Fprintln(out, "Toggle roles by number (e.g. '1 4 7'), '/TEXT' searches the roles catalog, 'preset NAME' selects a preset, empty line when done:")
+		fmt.Fprint(out, "> ")
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to read selection: %w", err)
+			}
+			return entries, nil
+		}
+
+		switch {
+		case strings.HasPrefix(line, "/"):
+			if catalog == nil {
+				fmt.Fprintln(out, "Loading the predefined roles catalog...")
+				if catalog, err = predefinedRoles(); err != nil {
+					fmt.Fprintf(out, "Could not load the roles catalog: %v\n", err)
+					continue
+				}
+			}
+			entries = appendCatalogMatches(out, entries, catalog, strings.TrimSpace(line[1:]))
+		case strings.HasPrefix(line, "preset "):
+			if err := selectPreset(entries, strings.TrimSpace(strings.TrimPrefix(line, "preset "))); err != nil {
+				fmt.Fprintln(out, err)
+			}
+		default:
+			for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == ',' }) {
+				n, err := strconv.Atoi(field)
+				if err != nil || n < 1 || n > len(entries) {
+					fmt.Fprintf(out, "Ignoring '%s': not a number between 1 and %d.\n", field, len(entries))
+					continue
+				}
+				entries[n-1].Selected = !entries[n-1].Selected
+			}
+		}
+	}
+}
+
+// predefinedRoles lists the name and title of every predefined IAM role
+func predefinedRoles() ([][2]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	output, err := runCommandGetOutput(ctx, "gcloud", "iam", "roles", "list", "--format=value(name,title)")
+	if err != nil {
+		return nil, err
+	}
+	var roles [][2]string
+	for _, line := range strings.Split(output, "\n") {
+		if name, title, ok := strings.Cut(line, "\t"); ok {
+			roles = append(roles, [2]string{name, title})
+		}
+	}
+	return roles, nil
+}
+
+// appendCatalogMatches adds the catalog roles whose name or title contains text to entries,
+// unselected, so they can be toggled like the recommended ones
+func appendCatalogMatches(out io.Writer, entries []rolePickerEntry, catalog [][2]string, text string) []rolePickerEntry {
+	text = strings.ToLower(text)
+	added := 0
+	for _, role := range catalog {
+		if !strings.Contains(strings.ToLower(role[0]), text) && !strings.Contains(strings.ToLower(role[1]), text) {
+			continue
+		}
+		if slices.ContainsFunc(entries, func(e rolePickerEntry) bool { return e.Role == role[0] }) {
+			continue
+		}
+		entries = append(entries, rolePickerEntry{Role: role[0], Description: role[1]})
+		added++
+	}
+	fmt.Fprintf(out, "Added %d role(s) matching '%s' from the catalog.\n", added, text)
+	return entries
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// rolePresets are curated least-privilege project role sets for the TF SA, so configs
+// don't have to fall back to roles/owner or roles/editor. Every preset includes enough
+// storage access for the state bucket and service usage for API enablement.
+var rolePresets = map[string][]string{
+	// Terraform only manages GCS buckets and their IAM
+	"storage-only": {
+		"roles/storage.admin",
+		"roles/serviceusage.serviceUsageConsumer",
+	},
+	// Terraform manages VPCs, subnets, firewall rules, Cloud DNS and private service access
+	"network-admin": {
+		"roles/compute.networkAdmin",
+		"roles/compute.securityAdmin",
+		"roles/dns.admin",
+		"roles/servicenetworking.networksAdmin",
+		"roles/serviceusage.serviceUsageAdmin",
+		"roles/storage.objectAdmin",
+	},
+	// Terraform manages a typical application stack: compute, GKE, Cloud Run, Cloud SQL,
+	// storage, secrets, messaging, registries, observability and service accounts
+	"full-infra": {
+		"roles/artifactregistry.admin",
+		"roles/cloudsql.admin",
+		"roles/compute.admin",
+		"roles/container.admin",
+		"roles/dns.admin",
+		"roles/iam.serviceAccountAdmin",
+		"roles/iam.serviceAccountUser",
+		"roles/logging.admin",
+		"roles/monitoring.admin",
+		"roles/pubsub.admin",
+		"roles/resourcemanager.projectIamAdmin",
+		"roles/run.admin",
+		"roles/secretmanager.admin",
+		"roles/serviceusage.serviceUsageAdmin",
+		"roles/storage.admin",
+	},
+}
+
+// rolePresetNames returns the names of the built-in role presets, sorted
+func rolePresetNames() []string {
+	names := make([]string, 0, len(rolePresets))
+	for name := range rolePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandRolePreset adds the roles of the named preset to the explicitly configured
+// roles, keeping explicit roles first and dropping duplicates
+func expandRolePreset(preset string, roles []string) ([]string, error) {
+	presetRoles, ok := rolePresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown role_preset '%s' (valid presets: %s)", preset, strings.Join(rolePresetNames(), ", "))
+	}
+	expanded := append([]string{}, roles...)
+	for _, role := range presetRoles {
+		if !slices.Contains(expanded, role) {
+			expanded = append(expanded, role)
+		}
+	}
+	return expanded, nil
+}
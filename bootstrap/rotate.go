@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Default maximum ages of user-managed keys before rotate-key and the sa_key_cleanup step delete them
+const (
+	defaultKeyMaxAgeDays        = 30
+	defaultKeyCleanupMaxAgeDays = 90
+)
+
+// serviceAccountKey is the subset of 'gcloud iam service-accounts keys list' output rotate-key needs
+type serviceAccountKey struct {
+	Name           string    `json:"name"` // projects/P/serviceAccounts/SA/keys/KEY_ID
+	ValidAfterTime time.Time `json:"validAfterTime"`
+}
+
+// runRotateKeyCommand implements 'rotate-key': it creates a new key for the Terraform SA,
+// stores it at tf_sa_key_path, in Secret Manager or in the OS keychain, and deletes
+// user-managed keys older than the configured maximum age
+func runRotateKeyCommand(args []string) {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigFilename, "Path to the configuration YAML file")
+	fs.StringVar(&configEnvironment, "env", "", "Environment of the config's 'environments' to use")
+	addVariableFlags(fs)
+	maxAgeDays := fs.Int("max-age-days", 0, "Delete user-managed keys older than this many days (default: key_rotation.max_age_days)")
+	credentialsFile := fs.String("credentials-file", "", "Authenticate gcloud with this credential JSON file instead of the active gcloud account")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gcp-bootstrap rotate-key [-config FILE] [-env NAME] [-max-age-days N]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	checkGcloud(ctx, *credentialsFile)
+	cfg, err := loadConfig(ctx, *configPath, bootstrapModeProject, nil)
+	if err != nil {
+		logError("Failed to load configuration: %v", err)
+	}
+	if err := resolveExistingProjectID(ctx, cfg); err != nil {
+		logError("%v", err)
+	}
+	if cfg.isProduction() {
+		logError("Service account keys are not allowed for production configs; use Workload Identity Federation or impersonation instead.")
+	}
+	if cfg.SAKeyMode == saKeyModeUpload {
+		logError("rotate-key creates Google-generated keys and cannot be used with sa_key_mode '%s'; upload a new public key instead.", saKeyModeUpload)
+	}
+	if cfg.KeyRotation.SecretID == "" && !cfg.keyInKeychain() && cfg.TFSAKeyPath == "" {
+		logError("Set tf_sa_key_path or key_rotation.secret_id in %s to tell rotate-key where to store the new key.", *configPath)
+	}
+	if *maxAgeDays > 0 {
+		cfg.KeyRotation.MaxAgeDays = *maxAgeDays
+	}
+
+	keyID, err := rotateSAKey(ctx, cfg)
+	if err != nil {
+		logError("Key rotation failed: %v", err)
+	}
+	keys, err := listSAKeys(ctx, cfg)
+	if err != nil {
+		logError("New key '%s' is in place, but listing old keys failed: %v", keyID, err)
+	}
+	deleted, err := deleteSAKeys(ctx, cfg, staleSAKeys(keys, keyID, time.Duration(cfg.KeyRotation.MaxAgeDays)*24*time.Hour, false))
+	if err != nil {
+		logError("New key '%s' is in place, but cleaning up old keys failed: %v", keyID, err)
+	}
+	logNotice("Rotated the key of '%s': new key '%s', %d old key(s) deleted.", cfg.TFServiceAccountEmail, keyID, deleted)
+}
+
+// rotateSAKey creates a new key for the TF SA and stores it in the configured
+// destination, returning the new key ID
+func rotateSAKey(ctx context.Context, cfg *Config) (string, error) {
+	// Create the key next to its final location so the rename below is atomic
+	dir := filepath.Dir(cfg.TFSAKeyPath)
+	if cfg.KeyRotation.SecretID != "" || cfg.keyInKeychain() {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory for SA key '%s': %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, ".rotate-key-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary key file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	logInfo("Creating a new key for '%s'...", cfg.TFServiceAccountEmail)
+	err = runCommand(ctx, "gcloud", "iam", "service-accounts", "keys", "create", tmp.Name(),
+		"--iam-account", cfg.TFServiceAccountEmail,
+		"--project", cfg.seedProject())
+	if err != nil {
+		return "", fmt.Errorf("failed to create service account key: %w", err)
+	}
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read new key: %w", err)
+	}
+	var key struct {
+		PrivateKeyID string `json:"private_key_id"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil || key.PrivateKeyID == "" {
+		return "", fmt.Errorf("failed to read the key ID from the new key: %v", err)
+	}
+
+	if secret := cfg.KeyRotation.SecretID; secret != "" {
+		if err := storeKeyInSecretManager(ctx, cfg, secret, tmp.Name()); err != nil {
+			return "", err
+		}
+		logInfo("New key stored as a new version of secret '%s'.", secret)
+		return key.PrivateKeyID, nil
+	}
+	if cfg.keyInKeychain() {
+		if err := keychainStore(ctx, cfg.TFServiceAccountEmail, data); err != nil {
+			return "", err
+		}
+		logInfo("New key stored in the OS keychain.")
+		return key.PrivateKeyID, nil
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return "", fmt.Errorf("failed to restrict permissions of the new key: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cfg.TFSAKeyPath); err != nil {
+		return "", fmt.Errorf("failed to replace '%s': %w", cfg.TFSAKeyPath, err)
+	}
+	logWarning("New service account key saved to '%s'. HANDLE THIS FILE SECURELY!", cfg.TFSAKeyPath)
+	return key.PrivateKeyID, nil
+}
+
+// storeKeyInSecretManager adds the key file as a new version of secret, creating the secret if needed
+func storeKeyInSecretManager(ctx context.Context, cfg *Config, secret, keyFile string) error {
+	if err := ensureServicesEnabled(ctx, cfg.seedProject(), []string{"secretmanager.googleapis.com"}); err != nil {
+		return fmt.Errorf("failed to enable the Secret Manager API: %w", err)
+	}
+	if _, err := runCommandGetOutput(ctx, "gcloud", "secrets", "describe", secret, "--project", cfg.seedProject()); err != nil {
+		logInfo("Secret '%s' does not exist, creating it...", secret)
+		err = runCommand(ctx, "gcloud", "secrets", "create", secret, "--replication-policy", "automatic", "--project", cfg.seedProject())
+		if err != nil {
+			return fmt.Errorf("failed to create secret '%s': %w", secret, err)
+		}
+	}
+	if err := runCommand(ctx, "gcloud", "secrets", "versions", "add", secret, "--data-file", keyFile, "--project", cfg.seedProject()); err != nil {
+		return fmt.Errorf("failed to add a version to secret '%s': %w", secret, err)
+	}
+	return nil
+}
+
+// listSAKeys returns the TF SA's user-managed keys
+func listSAKeys(ctx context.Context, cfg *Config) ([]serviceAccountKey, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "iam", "service-accounts", "keys", "list",
+		"--iam-account", cfg.TFServiceAccountEmail,
+		"--managed-by", "user",
+		"--format", "json",
+		"--project", cfg.seedProject())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	var keys []serviceAccountKey
+	if err := json.Unmarshal([]byte(output), &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse key list: %w", err)
+	}
+	return keys, nil
+}
+
+// staleSAKeys selects the keys to delete: those older than maxAge, or with keepNewest
+// all but the most recent one. keepID is never selected.
+func staleSAKeys(keys []serviceAccountKey, keepID string, maxAge time.Duration, keepNewest bool) []serviceAccountKey {
+	newest := -1
+	for i, k := range keys {
+		if newest < 0 || k.ValidAfterTime.After(keys[newest].ValidAfterTime) {
+			newest = i
+		}
+	}
+	var stale []serviceAccountKey
+	for i, k := range keys {
+		switch {
+		case path.Base(k.Name) == keepID:
+		case keepNewest && i != newest:
+			stale = append(stale, k)
+		case !keepNewest && time.Since(k.ValidAfterTime) >= maxAge:
+			stale = append(stale, k)
+		}
+	}
+	return stale
+}
+
+// deleteSAKeys warns about and then deletes each of keys, returning how many were deleted
+func deleteSAKeys(ctx context.Context, cfg *Config, keys []serviceAccountKey) (int, error) {
+	for _, k := range keys {
+		logWarning("Deleting key '%s' of '%s' (created %s ago); anything still using it will stop working.", path.Base(k.Name), cfg.TFServiceAccountEmail, time.Since(k.ValidAfterTime).Round(time.Hour))
+	}
+	deleted := 0
+	for _, k := range keys {
+		id := path.Base(k.Name)
+		err := runCommand(ctx, "gcloud", "iam", "service-accounts", "keys", "delete", id,
+			"--iam-account", cfg.TFServiceAccountEmail,
+			"--project", cfg.seedProject())
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete key '%s': %w", id, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// cleanupSAKeys deletes stale user-managed keys of the TF SA as configured in sa_key_cleanup
+func cleanupSAKeys(ctx context.Context, cfg *Config) error {
+	kc := cfg.SAKeyCleanup
+	if !kc.Enabled {
+		logInfo("Skipping service account key cleanup as per config.")
+		return nil
+	}
+	keys, err := listSAKeys(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	stale := staleSAKeys(keys, "", time.Duration(kc.MaxAgeDays)*24*time.Hour, kc.KeepNewest)
+	if len(stale) == 0 {
+		logInfo("No stale keys found (%d user-managed key(s)).", len(keys))
+		return nil
+	}
+	deleted, err := deleteSAKeys(ctx, cfg, stale)
+	if err != nil {
+		return err
+	}
+	logInfo("Deleted %d stale key(s).", deleted)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxSADescriptionLength is the limit IAM enforces on service account descriptions
+const maxSADescriptionLength = 256
+
+// fields returns the metadata as ordered key/value pairs, omitting empty values
+func (m ServiceAccountMetadata) fields() [][2]string {
+	var fields [][2]string
+	for _, f := range [][2]string{{"purpose", m.Purpose}, {"owner", m.Owner}, {"ticket", m.Ticket}, {"change_ticket", m.ChangeTicket}} {
+		if f[1] != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// description renders the metadata as a service account description, or "" if none is set
+func (m ServiceAccountMetadata) description() string {
+	var parts []string
+	for _, f := range m.fields() {
+		parts = append(parts, f[0]+"="+f[1])
+	}
+	return strings.Join(parts, "; ")
+}
+
+// validate rejects values that would make the description ambiguous or too long
+func (m ServiceAccountMetadata) validate() error {
+	for _, f := range m.fields() {
+		if strings.ContainsAny(f[1], ";=\n") {
+			return fmt.Errorf("tf_service_account_metadata.%s must not contain ';', '=' or newlines", f[0])
+		}
+	}
+	if n := len(m.description()); n > maxSADescriptionLength {
+		return fmt.Errorf("tf_service_account_metadata renders to %d characters, more than the %d a service account description allows", n, maxSADescriptionLength)
+	}
+	return nil
+}
+
+// parseSAMetadata reads "key=value; key=value" pairs from a service account description
+func parseSAMetadata(description string) map[string]string {
+	values := map[string]string{}
+	for _, part := range strings.Split(description, ";") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// saDescription returns the current description of a service account
+func saDescription(ctx context.Context, projectID, email string) (string, error) {
+	output, err := runCommandGetOutput(ctx, "gcloud", "iam", "service-accounts", "describe", email, "--format=value(description)", "--project", projectID)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe service account '%s': %w", email, err)
+	}
+	return output, nil
+}
+
+// ensureSAMetadata updates the description of an existing TF SA to match the configured metadata
+func ensureSAMetadata(ctx context.Context, cfg *Config) error {
+	want := cfg.TFServiceAccountMetadata.description()
+	if want == "" {
+		return nil
+	}
+	current, err := saDescription(ctx, cfg.seedProject(), cfg.TFServiceAccountEmail)
+	if err != nil {
+		return err
+	}
+	if current == want {
+		logInfo("Service account metadata is up to date.")
+		return nil
+	}
+	logInfo("Updating metadata of '%s' to '%s'...", cfg.TFServiceAccountEmail, want)
+	err = runCommand(ctx, "gcloud", "iam", "service-accounts", "update", cfg.TFServiceAccountEmail,
+		"--description", want,
+		"--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to update service account description: %w", err)
+	}
+	return nil
+}
+
+// auditSAMetadata reports configured metadata fields missing from or differing in the TF SA description
+func auditSAMetadata(ctx context.Context, cfg *Config) ([]auditFinding, error) {
+	fields := cfg.TFServiceAccountMetadata.fields()
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	logInfo("Auditing metadata of '%s'...", cfg.TFServiceAccountEmail)
+	description, err := saDescription(ctx, cfg.seedProject(), cfg.TFServiceAccountEmail)
+	if err != nil {
+		return nil, err
+	}
+	actual := parseSAMetadata(description)
+	var findings []auditFinding
+	for _, f := range fields {
+		switch got, ok := actual[f[0]]; {
+		case !ok:
+			findings = append(findings, auditFinding{severityMedium, cfg.TFServiceAccountEmail, fmt.Sprintf("metadata '%s' is missing from the description, expected '%s'", f[0], f[1])})
+		case got != f[1]:
+			findings = append(findings, auditFinding{severityMedium, cfg.TFServiceAccountEmail, fmt.Sprintf("metadata '%s' is '%s', expected '%s'", f[0], got, f[1])})
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultSeedBucketSARole is granted to the TF SA on the state bucket in a seed project,
+// where its project roles on the workload project do not reach the bucket
+const defaultSeedBucketSARole = "roles/storage.objectAdmin"
+
+// seedProject returns the project hosting the Terraform SA, its workload identity pool and
+// the state bucket: seed_project_id, or the bootstrapped project itself
+func (c *Config) seedProject() string {
+	if c.SeedProjectID != "" {
+		return c.SeedProjectID
+	}
+	return c.ProjectID
+}
+
+// checkSeedProject verifies that the configured seed project exists; unlike the workload
+// project it is never created by the bootstrap
+func checkSeedProject(ctx context.Context, cfg *Config) error {
+	if cfg.SeedProjectID == "" || cfg.Assumptions != nil {
+		return nil
+	}
+	logInfo("Checking seed project '%s'...", cfg.SeedProjectID)
+	exists, err := projectExists(ctx, cfg.SeedProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to check seed project '%s': %w", cfg.SeedProjectID, err)
+	}
+	if !exists {
+		return fmt.Errorf("seed project '%s' does not exist or is not accessible; it must be created before bootstrapping workload projects", cfg.SeedProjectID)
+	}
+	return nil
+}
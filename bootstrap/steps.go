@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeout policies applied when a step exceeds its configured timeout
+const (
+	timeoutPolicyAbort = "abort"
+	timeoutPolicyRetry = "retry"
+
+	// defaultTimeoutRetries is used when timeout_policy is retry and no retry count is set
+	defaultTimeoutRetries = 1
+)
+
+// errStepTimeout marks a step that exceeded its timeout on every attempt; it always aborts the run
+var errStepTimeout = errors.New("step timed out")
+
+// Exit codes used when the run is stopped before completion
+const (
+	exitCodeTimeout     = 124
+	exitCodeInterrupted = 130
+)
+
+// bootstrapStep is a single named stage of the bootstrap run
+type bootstrapStep struct {
+	ID   string // Stable identifier used in config (e.g. step_timeouts)
+	Name string
+	Run  func(ctx context.Context, cfg *Config) error
+	// Plan lists the actions the step would perform; no actions means it is skipped
+	Plan func(cfg *Config) []planAction
+	// NonFatal steps only log a warning on failure instead of aborting the run
+	NonFatal bool
+}
+
+// bootstrapSteps lists the bootstrap stages in execution order
+var bootstrapSteps = []bootstrapStep{
+	// Folders come first so the hierarchy exists before any project is created
+	{ID: "folders", Name: "folder hierarchy reconciliation", Run: reconcileFolders, Plan: planFolders},
+	{ID: "project", Name: "project creation", Run: createProject, Plan: planProject},
+	{ID: "billing", Name: "billing linking", Run: linkBilling, Plan: planBilling},
+	{ID: "apis", Name: "API enablement", Run: enableAPIs, Plan: planAPIs},
+	{ID: "budget", Name: "billing budget creation", Run: createBudget, Plan: planBudget},
+	{ID: "billing_export", Name: "billing export dataset setup", Run: setupBillingExport, Plan: planBillingExport},
+	{ID: "service_account", Name: "service account creation", Run: createServiceAccount, Plan: planServiceAccount},
+	{ID: "iam_roles", Name: "IAM role granting", Run: grantIAMRoles, Plan: planIAMRoles, NonFatal: true}, // Roles might already exist
+	{ID: "org_iam_roles", Name: "organization role granting", Run: grantOrgRoles, Plan: planOrgIAMRoles, NonFatal: true},
+	{ID: "wif", Name: "Workload Identity Federation setup", Run: setupWIF, Plan: planWIF},
+	{ID: "ops_service_account", Name: "ops service account setup", Run: setupOpsServiceAccount, Plan: planOpsServiceAccount},
+	{ID: "fleet", Name: "fleet registration", Run: registerFleet, Plan: planFleet},
+	{ID: "break_glass", Name: "break-glass binding", Run: setupBreakGlass, Plan: planBreakGlass},
+	{ID: "audit_logs", Name: "audit log configuration", Run: configureAuditLogs, Plan: planAuditLogs},
+	{ID: "essential_contacts", Name: "Essential Contacts registration", Run: setupEssentialContacts, Plan: planEssentialContacts},
+	{ID: "data_classification", Name: "data classification policies", Run: applyClassificationPolicies, Plan: planClassificationPolicies},
+	{ID: "org_policies", Name: "organization policy constraints", Run: applyOrgPolicies, Plan: planOrgPolicies},
+	{ID: "default_service_accounts", Name: "default service account hardening", Run: hardenDefaultServiceAccounts, Plan: planDefaultServiceAccounts},
+	{ID: "firebase", Name: "Firebase enablement", Run: enableFirebase, Plan: planFirebase},
+	{ID: "api_keys", Name: "restricted API key creation", Run: createAPIKeys, Plan: planAPIKeys},
+	{ID: "bucket", Name: "GCS bucket creation", Run: createBucket, Plan: planBucket},
+	{ID: "bucket_iam", Name: "state bucket IAM grant", Run: grantBucketRole, Plan: planBucketIAM},
+	{ID: "bucket_versioning", Name: "bucket versioning enablement", Run: enableBucketVersioning, Plan: planBucketVersioning},
+	{ID: "bucket_lifecycle", Name: "state bucket lifecycle rules", Run: applyBucketLifecycle, Plan: planBucketLifecycle},
+	{ID: "bucket_kms", Name: "state bucket CMEK setup", Run: setupBucketKMS, Plan: planBucketKMS},
+	{ID: "bucket_retention", Name: "state bucket retention policy", Run: setupBucketRetention, Plan: planBucketRetention},
+	{ID: "bucket_logging", Name: "state bucket access logging", Run: setupBucketAccessLogging, Plan: planBucketAccessLogging},
+	{ID: "log_sinks", Name: "log sink setup", Run: setupLogSinks, Plan: planLogSinks},
+	// After the last IAM grant (bucket, KMS, log bucket and sink writer bindings), so the
+	// restriction cannot block the bootstrap's own bindings
+	{ID: "domain_restricted_sharing", Name: "domain restricted sharing policy", Run: applyDomainRestrictedSharing, Plan: planDomainRestrictedSharing},
+	{ID: "sa_key", Name: "service account key generation", Run: generateSAKey, Plan: planSAKey},
+	{ID: "sa_key_cleanup", Name: "stale service account key cleanup", Run: cleanupSAKeys, Plan: planSAKeyCleanup},
+	{ID: "github_secrets", Name: "GitHub secrets upload", Run: pushGitHubSecrets, Plan: planGitHubSecrets},
+	{ID: "terraform_files", Name: "Terraform file generation", Run: generateTerraformFiles, Plan: planTerraformFiles},
+	{ID: "cmdb", Name: "CMDB registration", Run: registerCMDB, Plan: planCMDB, NonFatal: true},
+	// Last, so a failed run leaves a project that can still be deleted
+	{ID: "project_lien", Name: "project deletion lien", Run: placeProjectLien, Plan: planProjectLien},
+}
+
+// isStepID reports whether id identifies one of the bootstrap steps
+func isStepID(id string) bool {
+	for _, s := range bootstrapSteps {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// stepIDs returns the identifiers of all bootstrap steps, in execution order
+func stepIDs() []string {
+	ids := make([]string, 0, len(bootstrapSteps))
+	for _, s := range bootstrapSteps {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// runSteps executes the steps sequentially. If ctx is cancelled (e.g. by SIGINT or the
+// global --timeout) the run stops and reports which steps completed so the user knows
+// where things stand.
+func runSteps(ctx context.Context, cfg *Config, steps []bootstrapStep) {
+	var completed []string
+	defer setLogStep("")
+	for i, step := range steps {
+		if cfg.isStepDisabled(step.ID) {
+			logNotice("Step '%s' skipped (disabled).", step.Name)
+			finishStep(stepRecord{ID: step.ID, Name: step.Name, Status: stepStatusSkipped})
+			continue
+		}
+		setLogStep(step.ID)
+		events.emit(progressEvent{Type: eventStepStart, Step: step.ID, StepName: step.Name})
+		start := time.Now()
+		err := runStep(ctx, cfg, step)
+		record := stepRecord{ID: step.ID, Name: step.Name, Status: stepStatusCompleted, Duration: time.Since(start)}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if ctx.Err() != nil {
+			record.Status = stepStatusFailed
+			finishStep(record)
+			reportInterrupted(ctx, completed, step.Name, steps[i+1:])
+			exitProcess(cancelExitCode(ctx))
+		}
+		if err != nil {
+			if !step.NonFatal || cfg.Strict || errors.Is(err, errStepTimeout) {
+				record.Status = stepStatusFailed
+				finishStep(record)
+				logError("Bootstrap failed during %s: %v", step.Name, err)
+			}
+			record.Status = stepStatusWarning
+			logWarning("Potential issue during %s: %v", step.Name, err)
+		} else {
+			logNotice("Step '%s' completed.", step.Name)
+		}
+		finishStep(record)
+		completed = append(completed, step.Name)
+	}
+}
+
+// finishStep records the outcome of a step in metrics and on the event stream
+func finishStep(r stepRecord) {
+	metrics.recordStep(r)
+	ev := progressEvent{Type: eventStepFinish, Step: r.ID, StepName: r.Name, Status: r.Status, DurationSeconds: r.Duration.Seconds(), Error: r.Error}
+	if r.Status == stepStatusFailed {
+		ev.Type = eventStepError
+	}
+	events.emit(ev)
+}
+
+// runStep runs a single step, applying its configured timeout. A timed-out step is
+// retried according to the configured timeout policy; once attempts are exhausted
+// an error wrapping errStepTimeout is returned.
+func runStep(ctx context.Context, cfg *Config, step bootstrapStep) error {
+	timeout, ok := cfg.StepTimeouts[step.ID]
+	if !ok {
+		return step.Run(ctx, cfg)
+	}
+	attempts := 1
+	if cfg.TimeoutPolicy == timeoutPolicyRetry {
+		attempts += cfg.TimeoutRetries
+	}
+	for attempt := 1; ; attempt++ {
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := step.Run(stepCtx, cfg)
+		timedOut := ctx.Err() == nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		if !timedOut {
+			return err
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("timed out after %s (attempt %d of %d): %w", timeout, attempt, attempts, errStepTimeout)
+		}
+		logWarning("Step '%s' timed out after %s (attempt %d of %d). Retrying...", step.Name, timeout, attempt, attempts)
+	}
+}
+
+// reportInterrupted prints where the run stood when it was cancelled
+func reportInterrupted(ctx context.Context, completed []string, current string, remaining []bootstrapStep) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logWarning("Bootstrap timed out during step '%s'; it may be partially applied.", current)
+	} else {
+		logWarning("Bootstrap interrupted during step '%s'; it may be partially applied.", current)
+	}
+	if len(completed) > 0 {
+		logWarning("Completed steps: %s", strings.Join(completed, ", "))
+	} else {
+		logWarning("Completed steps: none")
+	}
+	if len(remaining) > 0 {
+		names := make([]string, 0, len(remaining))
+		for _, s := range remaining {
+			names = append(names, s.Name)
+		}
+		logWarning("Not started: %s", strings.Join(names, ", "))
+	}
+	logWarning("Re-run the bootstrap to resume; completed steps will be skipped.")
+}
+
+// validateTimeoutConfig checks the step timeout settings of cfg
+func validateTimeoutConfig(cfg *Config) error {
+	for id, d := range cfg.StepTimeouts {
+		if !isStepID(id) {
+			return fmt.Errorf("unknown step '%s' in step_timeouts (valid steps: %s)", id, strings.Join(stepIDs(), ", "))
+		}
+		if d <= 0 {
+			return fmt.Errorf("step_timeouts.%s must be a positive duration", id)
+		}
+	}
+	switch cfg.TimeoutPolicy {
+	case "":
+		cfg.TimeoutPolicy = timeoutPolicyAbort
+	case timeoutPolicyAbort, timeoutPolicyRetry:
+	default:
+		return fmt.Errorf("timeout_policy must be '%s' or '%s', got '%s'", timeoutPolicyAbort, timeoutPolicyRetry, cfg.TimeoutPolicy)
+	}
+	if cfg.TimeoutRetries < 0 {
+		return fmt.Errorf("timeout_retries must not be negative")
+	}
+	if cfg.TimeoutPolicy == timeoutPolicyRetry && cfg.TimeoutRetries == 0 {
+		cfg.TimeoutRetries = defaultTimeoutRetries
+	}
+	return nil
+}
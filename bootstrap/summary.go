@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runSummary is the only output of a --summary-only run: its outcome and key outputs
+type runSummary struct {
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	ProjectID       string  `json:"project_id"`
+	TFSAEmail       string  `json:"tf_service_account_email"`
+	StateBucketURL  string  `json:"state_bucket_url"`
+	SAKeyPath       string  `json:"sa_key_path,omitempty"`
+	ReportPath      string  `json:"report_path,omitempty"`
+	Error           string  `json:"error,omitempty"` // Error of the step that failed the run
+}
+
+// writeRunSummary writes the summary of the run as one line: a JSON object with
+// --log-format json, key=value pairs otherwise
+func writeRunSummary(w io.Writer, cfg *Config, status, reportPath string) {
+	r := buildReport(cfg, status)
+	s := runSummary{
+		Status:          status,
+		DurationSeconds: r.DurationSeconds,
+		ProjectID:       r.ProjectID,
+		TFSAEmail:       r.TFSAEmail,
+		StateBucketURL:  r.StateBucketURL,
+		SAKeyPath:       r.SAKeyPath,
+		ReportPath:      reportPath,
+	}
+	for _, step := range r.Steps {
+		if step.Status == stepStatusFailed {
+			s.Error = step.Error
+		}
+	}
+
+	if jsonLogging {
+		line, _ := json.Marshal(s)
+		fmt.Fprintln(w, string(line))
+		return
+	}
+	fields := []string{
+		"status=" + s.Status,
+		fmt.Sprintf("duration=%.1fs", s.DurationSeconds),
+		"project=" + s.ProjectID,
+		"tf_sa=" + s.TFSAEmail,
+		"bucket=" + s.StateBucketURL,
+	}
+	if s.SAKeyPath != "" {
+		fields = append(fields, "sa_key="+s.SAKeyPath)
+	}
+	if s.ReportPath != "" {
+		fields = append(fields, "report="+s.ReportPath)
+	}
+	if s.Error != "" {
+		fields = append(fields, fmt.Sprintf("error=%q", s.Error))
+	}
+	fmt.Fprintln(w, strings.Join(fields, " "))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// generatedFileHeader marks files written by the bootstrap
+const generatedFileHeader = "# Generated by gcp-bootstrap. Safe to edit; re-runs leave modified files untouched.\n"
+
+// terraformFile is a file generated for the Terraform root module or its CI
+type terraformFile struct {
+	Path    string
+	Content string
+}
+
+// terraformFiles returns the files to generate for cfg. projectNumber is only known
+// once the project exists; the plan passes a placeholder.
+func terraformFiles(cfg *Config, projectNumber string) []terraformFile {
+	dir := cfg.Terraform.OutputDir
+	var files []terraformFile
+	if cfg.Backstage.Enabled {
+		files = append(files, terraformFile{Path: cfg.Backstage.Path, Content: renderBackstageCatalog(cfg)})
+	}
+	if cfg.Terraform.Format == terraformFormatTerragrunt {
+		return append(files, terraformFile{Path: filepath.Join(dir, "terragrunt.hcl"), Content: renderTerragruntHCL(cfg)})
+	}
+	files = append(files, []terraformFile{
+		{Path: filepath.Join(dir, "backend.tf"), Content: renderBackendTF(cfg)},
+		{Path: filepath.Join(dir, "provider.tf"), Content: renderProviderTF(cfg)},
+		{Path: filepath.Join(dir, "bootstrap_variables.tf"), Content: renderBootstrapVariablesTF(cfg)},
+		{Path: filepath.Join(dir, "bootstrap.auto.tfvars"), Content: renderBootstrapTFVars(cfg)},
+	}...)
+	if cfg.WIF.GitHub.Enabled {
+		files = append(files, terraformFile{Path: cfg.WIF.GitHub.WorkflowPath, Content: renderGitHubWorkflow(cfg, projectNumber)})
+	}
+	if cfg.WIF.GitLab.Enabled {
+		files = append(files, terraformFile{Path: cfg.WIF.GitLab.PipelinePath, Content: renderGitLabPipeline(cfg, projectNumber)})
+	}
+	return files
+}
+
+// bootstrapVariable is a bootstrap output handed to the downstream Terraform code
+type bootstrapVariable struct {
+	Name        string
+	Description string
+	Value       string
+}
+
+// bootstrapVariables returns the values downstream Terraform needs instead of hardcoding them
+func bootstrapVariables(cfg *Config) []bootstrapVariable {
+	return []bootstrapVariable{
+		{Name: "project_id", Description: "ID of the bootstrapped project", Value: cfg.ProjectID},
+		{Name: "region", Description: "Default region for regional resources", Value: cfg.ProjectRegion},
+		{Name: "tf_service_account_email", Description: "Email of the Terraform service account", Value: cfg.TFServiceAccountEmail},
+		{Name: "tf_state_bucket", Description: "Name of the GCS bucket holding Terraform state", Value: cfg.TFStateBucketName},
+	}
+}
+
+// renderBootstrapVariablesTF declares the variables set by bootstrap.auto.tfvars
+func renderBootstrapVariablesTF(cfg *Config) string {
+	var b bytes.Buffer
+	b.WriteString(generatedFileHeader)
+	for i, v := range bootstrapVariables(cfg) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "variable %q {\n", v.Name)
+		fmt.Fprintf(&b, "  description = %q\n", v.Description)
+		b.WriteString("  type        = string\n")
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// renderBootstrapTFVars renders the bootstrap outputs as automatically loaded variable values
+func renderBootstrapTFVars(cfg *Config) string {
+	var b bytes.Buffer
+	b.WriteString(generatedFileHeader)
+	for _, v := range bootstrapVariables(cfg) {
+		fmt.Fprintf(&b, "%-24s = %q\n", v.Name, v.Value)
+	}
+	return b.String()
+}
+
+// renderBackendTF renders the GCS backend configuration for the state bucket
+func renderBackendTF(cfg *Config) string {
+	var b bytes.Buffer
+	b.WriteString(generatedFileHeader)
+	b.WriteString("terraform {\n")
+	b.WriteString("  backend \"gcs\" {\n")
+	fmt.Fprintf(&b, "    bucket = %q\n", cfg.TFStateBucketName)
+	fmt.Fprintf(&b, "    prefix = %q\n", cfg.Terraform.StatePrefix)
+	if cfg.Terraform.ImpersonateBackend {
+		fmt.Fprintf(&b, "\n    impersonate_service_account = %q\n", cfg.TFServiceAccountEmail)
+	}
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// renderProviderTF renders a pinned google provider that impersonates the TF SA, so
+// local runs need only the user's own application default credentials. Values come
+// from bootstrap.auto.tfvars.
+func renderProviderTF(cfg *Config) string {
+	var b bytes.Buffer
+	b.WriteString(generatedFileHeader)
+	writeRequiredProviders(&b, cfg)
+	b.WriteString("\n")
+	b.WriteString("variable \"impersonate_tf_service_account\" {\n")
+	b.WriteString("  description = \"Impersonate the Terraform SA; disable when already authenticated as it (e.g. in CI)\"\n")
+	b.WriteString("  type        = bool\n")
+	b.WriteString("  default     = true\n")
+	b.WriteString("}\n\n")
+	b.WriteString("provider \"google\" {\n")
+	b.WriteString("  project = var.project_id\n")
+	b.WriteString("  region  = var.region\n\n")
+	b.WriteString("  impersonate_service_account = var.impersonate_tf_service_account ? var.tf_service_account_email : null\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// writeRequiredProviders writes the terraform block pinning Terraform and the google provider
+func writeRequiredProviders(b *bytes.Buffer, cfg *Config) {
+	b.WriteString("terraform {\n")
+	fmt.Fprintf(b, "  required_version = %q\n\n", cfg.Terraform.RequiredVersion)
+	b.WriteString("  required_providers {\n")
+	b.WriteString("    google = {\n")
+	b.WriteString("      source  = \"hashicorp/google\"\n")
+	fmt.Fprintf(b, "      version = %q\n", cfg.Terraform.GoogleProviderVersion)
+	b.WriteString("    }\n")
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+}
+
+// renderTerragruntHCL renders a root terragrunt.hcl whose remote_state block generates
+// the GCS backend for every unit (one state per unit path) and whose generate block
+// writes a provider impersonating the TF SA. The bootstrap values are passed as inputs.
+func renderTerragruntHCL(cfg *Config) string {
+	var b bytes.Buffer
+	b.WriteString(generatedFileHeader)
+	b.WriteString("remote_state {\n")
+	b.WriteString("  backend = \"gcs\"\n")
+	b.WriteString("  generate = {\n")
+	b.WriteString("    path      = \"backend.tf\"\n")
+	b.WriteString("    if_exists = \"overwrite_terragrunt\"\n")
+	b.WriteString("  }\n")
+	b.WriteString("  config = {\n")
+	fmt.Fprintf(&b, "    project  = %q\n", cfg.ProjectID)
+	fmt.Fprintf(&b, "    location = %q\n", cfg.TFStateBucketLocation)
+	fmt.Fprintf(&b, "    bucket   = %q\n", cfg.TFStateBucketName)
+	fmt.Fprintf(&b, "    prefix   = \"%s/${path_relative_to_include()}\"\n\n", cfg.Terraform.StatePrefix)
+	fmt.Fprintf(&b, "    impersonate_service_account = %q\n", cfg.TFServiceAccountEmail)
+	b.WriteString("  }\n")
+	b.WriteString("}\n\n")
+	b.WriteString("generate \"provider\" {\n")
+	b.WriteString("  path      = \"provider.tf\"\n")
+	b.WriteString("  if_exists = \"overwrite_terragrunt\"\n")
+	b.WriteString("  contents  = <<EOF\n")
+	writeRequiredProviders(&b, cfg)
+	b.WriteString("\n")
+	b.WriteString("provider \"google\" {\n")
+	fmt.Fprintf(&b, "  project = %q\n", cfg.ProjectID)
+	fmt.Fprintf(&b, "  region  = %q\n\n", cfg.ProjectRegion)
+	fmt.Fprintf(&b, "  impersonate_service_account = %q\n", cfg.TFServiceAccountEmail)
+	b.WriteString("}\n")
+	b.WriteString("EOF\n")
+	b.WriteString("}\n\n")
+	b.WriteString("inputs = {\n")
+	for _, v := range bootstrapVariables(cfg) {
+		fmt.Fprintf(&b, "  %-24s = %q\n", v.Name, v.Value)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// generateTerraformFiles writes the generated files, creating their directories.
+// Existing files with different content are left alone unless overwrite is enabled.
+func generateTerraformFiles(ctx context.Context, cfg *Config) error {
+	number := "<project-number>"
+	if cfg.WIF.GitHub.Enabled || cfg.WIF.GitLab.Enabled {
+		var err error
+		if number, err = projectNumber(ctx, cfg.seedProject()); err != nil {
+			return err
+		}
+	}
+	for _, f := range terraformFiles(cfg, number) {
+		path := f.Path
+		existing, err := os.ReadFile(path)
+		switch {
+		case err == nil && string(existing) == f.Content:
+			logInfo("'%s' is up to date.", path)
+			metrics.recordResource("file", path, resourceExisted)
+			continue
+		case err == nil && !cfg.Terraform.Overwrite:
+			logWarning("'%s' exists with different content; leaving it unchanged (set terraform.overwrite to replace it).", path)
+			metrics.recordResource("file", path, resourceExisted)
+			continue
+		case err != nil && !os.IsNotExist(err):
+			return fmt.Errorf("failed to read '%s': %w", path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for '%s': %w", path, err)
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", path, err)
+		}
+		logInfo("Wrote '%s'.", path)
+		metrics.recordResource("file", path, resourceCreated)
+	}
+	return nil
+}
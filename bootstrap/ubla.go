@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// bucketACLRoles maps bucket ACL roles to the IAM roles granting the same bucket access
+var bucketACLRoles = map[string]string{
+	"OWNER":  "roles/storage.legacyBucketOwner",
+	"WRITER": "roles/storage.legacyBucketWriter",
+	"READER": "roles/storage.legacyBucketReader",
+}
+
+// bucketACLEntry is an entry of a bucket's legacy access control list
+type bucketACLEntry struct {
+	Entity string `json:"entity"` // e.g. user-alice@example.com, group-ops@example.com, project-owners-123
+	Role   string `json:"role"`
+}
+
+// aclMember returns the IAM member of an ACL entity. Project entities are skipped: GCS
+// keeps the project convenience bindings when uniform access is enabled. Public entities
+// are never migrated.
+func aclMember(entity string) (member string, ok bool) {
+	for prefix, kind := range map[string]string{"user-": "user:", "group-": "group:", "domain-": "domain:"} {
+		if rest, found := strings.CutPrefix(entity, prefix); found {
+			return kind + rest, true
+		}
+	}
+	return "", false
+}
+
+// ensureUniformBucketLevelAccess flags an existing state bucket that still uses legacy
+// ACLs. With tf_state_bucket_repair_ubla it migrates the bucket ACL to IAM bindings and
+// enables uniform bucket-level access; otherwise a warning is logged (an error with strict).
+func ensureUniformBucketLevelAccess(ctx context.Context, cfg *Config) error {
+	bucketURL := fmt.Sprintf("gs://%s", cfg.TFStateBucketName)
+	ubla, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", bucketURL, "--format=value(uniform_bucket_level_access)", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to read uniform bucket-level access of '%s': %w", bucketURL, err)
+	}
+	if strings.EqualFold(ubla, "true") {
+		logInfo("Uniform bucket-level access is enabled on '%s'.", bucketURL)
+		return nil
+	}
+	if !cfg.TFStateBucketRepairUBLA {
+		err := fmt.Errorf("bucket '%s' uses legacy ACLs (uniform bucket-level access is disabled), so object ACLs can expose state files; set tf_state_bucket_repair_ubla: true to migrate its ACL to IAM and enable it", bucketURL)
+		if cfg.Strict {
+			return err
+		}
+		logWarning("%v", err)
+		return nil
+	}
+
+	logInfo("Migrating the ACL of '%s' to IAM before enabling uniform bucket-level access...", bucketURL)
+	output, err := runCommandGetOutput(ctx, "gcloud", "storage", "buckets", "describe", bucketURL, "--format=json(acl)", "--project", cfg.seedProject())
+	if err != nil {
+		return fmt.Errorf("failed to read the ACL of '%s': %w", bucketURL, err)
+	}
+	var described struct {
+		ACL []bucketACLEntry `json:"acl"`
+	}
+	if err := json.Unmarshal([]byte(output), &described); err != nil {
+		return fmt.Errorf("failed to parse the ACL of '%s': %w", bucketURL, err)
+	}
+	for _, entry := range described.ACL {
+		role, known := bucketACLRoles[entry.Role]
+		member, ok := aclMember(entry.Entity)
+		switch {
+		case entry.Entity == "allUsers" || entry.Entity == "allAuthenticatedUsers":
+			logWarning("Not migrating public ACL entry '%s' (%s) of '%s'.", entry.Entity, entry.Role, bucketURL)
+			continue
+		case !ok:
+			logInfo("Skipping ACL entry '%s' (%s); project roles keep their bucket access.", entry.Entity, entry.Role)
+			continue
+		case !known:
+			return fmt.Errorf("unknown role '%s' of ACL entry '%s' on '%s'", entry.Role, entry.Entity, bucketURL)
+		}
+		logInfo("Granting '%s' to '%s' (from ACL %s)...", role, member, entry.Role)
+		if err := runCommand(ctx, "gcloud", "storage", "buckets", "add-iam-policy-binding", bucketURL, "--member", member, "--role", role, "--project", cfg.seedProject()); err != nil {
+			return fmt.Errorf("failed to migrate ACL entry '%s' of '%s': %w", entry.Entity, bucketURL, err)
+		}
+	}
+
+	logWarning("Enabling uniform bucket-level access on '%s': object ACLs stop applying and are not migrated. It can be disabled again within 90 days; after that the change is permanent.", bucketURL)
+	if err := runCommand(ctx, "gcloud", "storage", "buckets", "update", bucketURL, "--uniform-bucket-level-access", "--project", cfg.seedProject()); err != nil {
+		return fmt.Errorf("failed to enable uniform bucket-level access on '%s': %w", bucketURL, err)
+	}
+	logInfo("Uniform bucket-level access enabled on '%s'.", bucketURL)
+	return nil
+}
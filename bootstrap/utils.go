@@ -2,108 +2,512 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"os/exec"
 	"strings"
+	"time"
 )
 
-// logInfo prints an informational message
-func logInfo(format string, v ...interface{}) {
-	log.Printf("[INFO] "+format+"\n", v...)
+// exitHooks run before the process exits via exitProcess, e.g. to write reports
+var exitHooks []func(code int)
+
+// exiting guards against hooks that fail and try to exit again
+var exiting bool
+
+// registerExitHook adds a function to run before the process exits via exitProcess
+func registerExitHook(hook func(code int)) {
+	exitHooks = append(exitHooks, hook)
+}
+
+// exitProcess runs the registered exit hooks and terminates with the given code
+func exitProcess(code int) {
+	if !exiting {
+		exiting = true
+		for _, hook := range exitHooks {
+			hook(code)
+		}
+	}
+	os.Exit(code)
 }
 
-// logWarning prints a warning message
-func logWarning(format string, v ...interface{}) {
-	log.Printf("[WARN] "+format+"\n", v...)
+// commandEnv holds extra environment variables passed to every gcloud invocation
+var commandEnv []string
+
+// gcloudQuietEnv disables gcloud's interactive prompts, update checks, surveys and usage
+// reporting, which otherwise appear on first runs and pollute captured output
+var gcloudQuietEnv = []string{
+	"CLOUDSDK_CORE_DISABLE_PROMPTS=1",
+	"CLOUDSDK_CORE_DISABLE_USAGE_REPORTING=true",
+	"CLOUDSDK_COMPONENT_MANAGER_DISABLE_UPDATE_CHECK=true",
+	"CLOUDSDK_SURVEY_DISABLE_PROMPTS=true",
 }
 
-// logError prints an error message and exits
-func logError(format string, v ...interface{}) {
-	log.Fatalf("[ERROR] "+format+"\n", v...)
+// gcloudBannerStarts begin informational blocks gcloud may still print despite
+// gcloudQuietEnv (e.g. with older SDKs); each block ends with the suggested command
+var gcloudBannerStarts = []string{
+	"Updates are available for some",
+	"To take a quick anonymous survey",
 }
 
+// stripGcloudBanners removes known gcloud update/survey banners from captured output
+func stripGcloudBanners(output string) string {
+	lines := strings.Split(output, "\n")
+	kept := make([]string, 0, len(lines))
+	inBanner := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !inBanner {
+			for _, start := range gcloudBannerStarts {
+				if strings.HasPrefix(trimmed, start) {
+					inBanner = true
+					break
+				}
+			}
+		}
+		if inBanner {
+			// The block ends with "$ gcloud <command>" or, failing that, a blank line
+			if strings.HasPrefix(trimmed, "$ gcloud") || trimmed == "" {
+				inBanner = false
+			}
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// errNoGcloudBackend is returned for external commands in builds with the nogcloud tag
+var errNoGcloudBackend = errors.New("external commands are not available in this build (built with the nogcloud tag)")
+
 // runCommand executes a command and streams its output
-func runCommand(name string, args ...string) error {
-	logInfo("Executing: %s %s", name, strings.Join(args, " "))
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
-		return fmt.Errorf("command failed: %s %s: %w", name, strings.Join(args, " "), err)
+func runCommand(ctx context.Context, name string, args ...string) error {
+	commandLine := name + " " + strings.Join(args, " ")
+	logAt(slog.LevelInfo, "Executing: "+commandLine, slog.String("command", commandLine))
+	if fakeGCP != nil && name == "gcloud" {
+		if _, err := fakeGCP.run(args); err != nil {
+			return fmt.Errorf("command failed: %s %s: %w", name, strings.Join(args, " "), err)
+		}
+		logInfo("Command finished successfully.")
+		return nil
+	}
+	if err := execCommand(ctx, name, args...); err != nil {
+		return err
 	}
 	logInfo("Command finished successfully.")
 	return nil
 }
 
 // runCommandGetOutput executes a command and returns its stdout, suppressing command logs
-func runCommandGetOutput(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	outputBytes, err := cmd.Output() // Runs command and captures stdout
+func runCommandGetOutput(ctx context.Context, name string, args ...string) (string, error) {
+	logDebug("Executing (captured): %s %s", name, strings.Join(args, " "))
+	if fakeGCP != nil && name == "gcloud" {
+		output, err := fakeGCP.run(args)
+		if err != nil {
+			return "", fmt.Errorf("command failed: %s %s: %w", name, strings.Join(args, " "), err)
+		}
+		return output, nil
+	}
+	return execCommandGetOutput(ctx, name, args...)
+}
+
+// logCapturedCommand logs a finished captured command at debug level with its duration,
+// exit code and stderr, both in the message and as structured fields
+func logCapturedCommand(commandLine string, duration time.Duration, state *os.ProcessState, stderr string) {
+	exitCode := -1 // Not started or killed by a signal
+	if state != nil {
+		exitCode = state.ExitCode()
+	}
+	stderr = strings.TrimSpace(stderr)
+	msg := fmt.Sprintf("Finished (captured): %s (exit code %d, %s)", commandLine, exitCode, duration.Round(time.Millisecond))
+	if stderr != "" {
+		msg += "\nStderr:\n" + stderr
+	}
+	logAt(slog.LevelDebug, msg,
+		slog.String("command", commandLine),
+		slog.Int64("duration_ms", duration.Milliseconds()),
+		slog.Int("exit_code", exitCode),
+		slog.String("stderr", stderr))
+}
+
+// waitForPropagation pauses for eventual consistency as a named sub-step, showing a live
+// countdown and recording the wait duration in metrics. Returns early if ctx is cancelled.
+func waitForPropagation(ctx context.Context, name string, d time.Duration) error {
+	if fakeGCP != nil {
+		logDebug("Skipping wait for %s with --fake-gcp.", name)
+		metrics.recordWait(name, 0)
+		return nil
+	}
+	logInfo("Waiting for %s (%s)...", name, d)
+	start := time.Now()
+	err := countdown(ctx, name, start.Add(d))
+	metrics.recordWait(name, time.Since(start))
 	if err != nil {
-		// If there's an error, capture stderr as well for better debugging
-		stderr := ""
-		if ee, ok := err.(*exec.ExitError); ok {
-			stderr = string(ee.Stderr)
+		return fmt.Errorf("wait for %s interrupted: %w", name, err)
+	}
+	logInfo("Finished waiting for %s.", name)
+	return nil
+}
+
+// countdown blocks until deadline, rendering the remaining seconds on a single line
+func countdown(ctx context.Context, name string, deadline time.Time) error {
+	interactive := !jsonLogging && !quietLogging
+	// Through outputMu, so the countdown never lands in the middle of another writer's line
+	console := lockedWriter{os.Stderr}
+	if interactive {
+		defer fmt.Fprint(console, "\r\033[K")
+	}
+	for remaining := time.Until(deadline); remaining > 0; remaining = time.Until(deadline) {
+		if interactive {
+			fmt.Fprintf(console, "\r    %s: %ds remaining ", name, int(remaining.Round(time.Second).Seconds()))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(remaining, time.Second)):
 		}
-		return "", fmt.Errorf("command failed: %s %s: %w\nStderr: %s", name, strings.Join(args, " "), err, stderr)
 	}
-	return strings.TrimSpace(string(outputBytes)), nil
+	return nil
 }
 
-// checkGcloud checks if gcloud exists and is authenticated
-func checkGcloud() {
-	logInfo("Checking gcloud installation and authentication...")
-	_, err := exec.LookPath("gcloud")
+// useCredentialsFile makes every gcloud invocation authenticate with the given credential
+// file instead of the active gcloud account, and returns the identity it contains
+func useCredentialsFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
+		return "", fmt.Errorf("error reading credentials file %s: %w", path, err)
+	}
+	var creds struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", fmt.Errorf("error parsing credentials file %s: %w", path, err)
+	}
+	identity := creds.ClientEmail
+	if identity == "" {
+		// External account (WIF) and authorized user files carry no email
+		identity = fmt.Sprintf("%s credentials", creds.Type)
+	}
+	commandEnv = append(commandEnv, "CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE="+path)
+	return identity, nil
+}
+
+// checkGcloud checks if gcloud exists and is authenticated. If credentialsFile is set,
+// gcloud is pointed at it instead of relying on the active account.
+func checkGcloud(ctx context.Context, credentialsFile string) {
+	logInfo("Checking gcloud installation and authentication...")
+	if fakeGCP != nil {
+		logWarning("Using the in-process fake GCP (--fake-gcp): no cloud calls are made and nothing is really created.")
+	} else if err := lookGcloud(); errors.Is(err, errNoGcloudBackend) {
+		logError("This binary was built with the nogcloud tag and cannot call GCP; use -fake-gcp, or -plan with -assume.")
+	} else if err != nil {
 		logError("'gcloud' command not found in PATH. Please install the Google Cloud SDK: https://cloud.google.com/sdk/docs/install")
 	}
 
+	if credentialsFile != "" {
+		identity, err := useCredentialsFile(credentialsFile)
+		if err != nil {
+			logError("Failed to use credentials file: %v", err)
+		}
+		// Verify the credentials are usable before doing anything else
+		if _, err := runCommandGetOutput(ctx, "gcloud", "auth", "print-access-token"); err != nil {
+			logError("Failed to obtain an access token from credentials file %s: %v", credentialsFile, err)
+		}
+		logInfo("gcloud authenticating with credentials file as: %s", identity)
+		return
+	}
+
 	// Check authentication
-	output, err := runCommandGetOutput("gcloud", "auth", "list", "--filter=status:ACTIVE", "--format=value(account)")
+	output, err := runCommandGetOutput(ctx, "gcloud", "auth", "list", "--filter=status:ACTIVE", "--format=value(account)")
 	if err != nil {
 		logError("Failed to check gcloud authentication status: %v. Please run 'gcloud auth login' and 'gcloud auth application-default login'.", err)
 	}
+	if output == "" && inCloudShell() {
+		logError("Cloud Shell is not authorized to use your credentials. Click 'Authorize' when prompted, or run 'gcloud auth login'.")
+	}
 	if output == "" {
 		logError("Not authenticated to GCP via gcloud. Please run 'gcloud auth login' and 'gcloud auth application-default login'.")
 	}
 	logInfo("gcloud authenticated as: %s", output)
 }
 
-// confirmExecution displays the plan and asks for user confirmation
-func confirmExecution(cfg *Config) {
-	fmt.Println("-----------------------------------------------------")
-	fmt.Println(" GCP Bootstrap Configuration Summary")
-	fmt.Println("-----------------------------------------------------")
-	fmt.Printf(" Project ID:              %s\n", cfg.ProjectID)
-	fmt.Printf(" Project Name:            %s\n", cfg.ProjectName)
-	fmt.Printf(" Project Region:          %s\n", cfg.ProjectRegion)
-	fmt.Printf(" Billing Account ID:      %s\n", cfg.BillingAccountID)
+// confirmExecution displays the plan and asks for user confirmation. With assumeYes the
+// prompt is skipped, except for production configs which still require the project ID
+// to be typed unless productionAck is also set.
+func confirmExecution(ctx context.Context, cfg *Config, assumeYes, productionAck bool) {
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	fmt.Fprintln(stdout, colorize(colorBold+colorCyan, " GCP Bootstrap Configuration Summary"))
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	if cfg.EnvironmentClass != "" {
+		envClass := cfg.EnvironmentClass
+		if cfg.isProduction() {
+			envClass = colorize(colorBold+colorRed, envClass)
+		}
+		fmt.Fprintf(stdout, " Environment Class:       %s\n", envClass)
+	}
+	if cfg.Mode == bootstrapModeOrg {
+		fmt.Fprintf(stdout, " Bootstrap Mode:          %s\n", colorize(colorBold, "org (landing zone seed project)"))
+	}
+	if cfg.Extends != "" {
+		fmt.Fprintf(stdout, " Catalog Template:        %s\n", cfg.Extends)
+	}
+	if cfg.Environment != "" {
+		fmt.Fprintf(stdout, " Environment:             %s\n", colorize(colorBold, cfg.Environment))
+	}
+	fmt.Fprintf(stdout, " Strict Mode:             %t\n", cfg.Strict)
+	if cfg.ProjectIDPrefix != "" {
+		if cfg.projectIDPending() {
+			fmt.Fprintf(stdout, " Project ID:              %s (generated from prefix '%s' by the project step, or reused from an earlier run)\n", cfg.ProjectID, cfg.ProjectIDPrefix)
+		} else {
+			fmt.Fprintf(stdout, " Project ID:              %s (generated from prefix '%s')\n", cfg.ProjectID, cfg.ProjectIDPrefix)
+		}
+	} else {
+		fmt.Fprintf(stdout, " Project ID:              %s\n", cfg.ProjectID)
+	}
+	if cfg.SeedProjectID != "" {
+		fmt.Fprintf(stdout, " Seed Project ID:         %s (hosts the TF SA and state bucket)\n", cfg.SeedProjectID)
+	}
+	if cfg.ChangeTicket != "" {
+		fmt.Fprintf(stdout, " Change Ticket:           %s\n", cfg.ChangeTicket)
+	}
+	fmt.Fprintf(stdout, " Project Name:            %s\n", cfg.ProjectName)
+	if labels := cfg.projectLabels(); len(labels) > 0 {
+		fmt.Fprintf(stdout, " Project Labels:          %s\n", joinLabels(labels))
+	}
+	fmt.Fprintf(stdout, " Project Region:          %s\n", cfg.ProjectRegion)
+	fmt.Fprintf(stdout, " Billing Account ID:      %s\n", cfg.BillingAccountID)
+	if sub := cfg.BillingLink.Subaccount; sub.Enabled {
+		fmt.Fprintf(stdout, " Billing Subaccount:      %s (created if missing)\n", sub.DisplayName)
+	}
+	if be := cfg.BillingExport; be.Enabled {
+		fmt.Fprintf(stdout, " Billing Export Dataset:  %s:%s (%s)\n", be.project(cfg), be.DatasetID, be.Location)
+	}
+	if b := cfg.Budget; b.enabled() {
+		fmt.Fprintf(stdout, " Budget:                  %g %s (%s)\n", b.Amount, b.Currency, b.DisplayName)
+	}
 	if cfg.OrganizationID != "" {
-		fmt.Printf(" Organization ID:         %s\n", cfg.OrganizationID)
+		fmt.Fprintf(stdout, " Organization ID:         %s\n", cfg.OrganizationID)
 	}
-	fmt.Printf(" TF State Bucket Name:    gs://%s\n", cfg.TFStateBucketName)
-	fmt.Printf(" TF Service Account Name: %s\n", cfg.TFServiceAccountName)
-	fmt.Printf(" TF Service Account Email:%s\n", cfg.TFServiceAccountEmail)
-	fmt.Printf(" Generate TF SA Key:      %t\n", cfg.GenerateTFSAKey)
-	if cfg.GenerateTFSAKey {
-		fmt.Printf(" TF SA Key Path:          %s\n", cfg.TFSAKeyPath)
+	if cfg.FolderID != "" {
+		fmt.Fprintf(stdout, " Folder ID:               %s\n", cfg.FolderID)
 	}
-	fmt.Printf(" APIs to Enable:          %s\n", strings.Join(cfg.EnableAPIs, ", "))
-	fmt.Printf(" TF SA Project Roles:     %s\n", strings.Join(cfg.TFServiceAccountProjectRoles, ", "))
+	if cfg.FolderPath != "" {
+		fmt.Fprintf(stdout, " Folder Path:             %s (created if missing)\n", cfg.FolderPath)
+	}
+	if len(cfg.Folders) > 0 {
+		fmt.Fprintf(stdout, " Folder Hierarchy:        %d folder(s)\n", countFolders(cfg.Folders))
+	}
+	if dc := cfg.classification(); dc != nil {
+		placement := "default parent"
+		if dc.ParentFolder != "" {
+			placement = "folder " + dc.ParentFolder
+		}
+		fmt.Fprintf(stdout, " Data Classification:     %s (%s, %d policy constraint(s))\n", cfg.DataClassification, placement, len(dc.OrgPolicies))
+	}
+	if cfg.bucketNamePending() {
+		fmt.Fprintf(stdout, " TF State Bucket Name:    gs://%s (resolved in the project step)\n", cfg.TFStateBucketName)
+	} else {
+		fmt.Fprintf(stdout, " TF State Bucket Name:    gs://%s\n", cfg.TFStateBucketName)
+	}
+	if cfg.TFStateBucketLocation != cfg.ProjectRegion {
+		fmt.Fprintf(stdout, " TF State Location:       %s (%s)\n", cfg.TFStateBucketLocation, bucketLocationType(cfg.TFStateBucketLocation))
+	}
+	if cfg.TFStateBucketAccessLogging.Enabled {
+		fmt.Fprintf(stdout, " TF State Access Logs:    gs://%s\n", cfg.logBucketName())
+	}
+	if cfg.TFStateBucketSARole != "" {
+		fmt.Fprintf(stdout, " TF State Bucket SA Role: %s\n", cfg.TFStateBucketSARole)
+	}
+	if cfg.KMS.Enabled {
+		fmt.Fprintf(stdout, " TF State Bucket CMEK:    %s\n", kmsKeyName(cfg))
+	}
+	if cfg.TFStateBucketRetention != "" {
+		retention := cfg.TFStateBucketRetention
+		if cfg.TFStateBucketRetentionLock {
+			retention += " " + colorize(colorYellow, "(locked permanently)")
+		}
+		fmt.Fprintf(stdout, " TF State Retention:      %s\n", retention)
+	}
+	fmt.Fprintf(stdout, " TF Service Account Name: %s\n", cfg.TFServiceAccountName)
+	fmt.Fprintf(stdout, " TF Service Account Email:%s\n", cfg.TFServiceAccountEmail)
+	switch {
+	case cfg.GenerateTFSAKey && cfg.SAKeyMode == saKeyModeUpload:
+		fmt.Fprintf(stdout, " Upload TF SA Public Key: %s\n", cfg.TFSAPublicKey)
+	case cfg.GenerateTFSAKey:
+		fmt.Fprintf(stdout, " Generate TF SA Key:      %s\n", colorize(colorYellow, "true"))
+		if cfg.keyInKeychain() {
+			fmt.Fprintf(stdout, " TF SA Key Storage:       %s\n", "OS keychain")
+		} else {
+			fmt.Fprintf(stdout, " TF SA Key Path:          %s\n", cfg.TFSAKeyPath)
+		}
+	default:
+		fmt.Fprintf(stdout, " Generate TF SA Key:      %t\n", cfg.GenerateTFSAKey)
+	}
+	fmt.Fprintf(stdout, " APIs to Enable:          %s\n", strings.Join(cfg.EnableAPIs, ", "))
+	if cfg.RolePreset != "" {
+		fmt.Fprintf(stdout, " TF SA Role Preset:       %s\n", cfg.RolePreset)
+	}
+	fmt.Fprintf(stdout, " TF SA Project Roles:     %s\n", strings.Join(cfg.TFServiceAccountProjectRoles, ", "))
 	if cfg.TFServiceAccountBillingRole != "" {
-		fmt.Printf(" TF SA Billing Role:      %s\n", cfg.TFServiceAccountBillingRole)
+		fmt.Fprintf(stdout, " TF SA Billing Role:      %s\n", cfg.TFServiceAccountBillingRole)
+	}
+	if cfg.Mode == bootstrapModeOrg {
+		fmt.Fprintf(stdout, " TF SA Org Roles:         %s\n", strings.Join(cfg.OrgBootstrap.TFSAOrgRoles, ", "))
+	}
+	if len(cfg.Steps.Disabled) > 0 {
+		fmt.Fprintf(stdout, " Disabled Steps:          %s\n", colorize(colorYellow, strings.Join(cfg.Steps.Disabled, ", ")))
+	}
+	if len(cfg.StepTimeouts) > 0 {
+		timeouts := make([]string, 0, len(cfg.StepTimeouts))
+		for _, id := range stepIDs() {
+			if d, ok := cfg.StepTimeouts[id]; ok {
+				timeouts = append(timeouts, fmt.Sprintf("%s=%s", id, d))
+			}
+		}
+		fmt.Fprintf(stdout, " Step Timeouts:           %s (on timeout: %s)\n", strings.Join(timeouts, ", "), cfg.TimeoutPolicy)
+	}
+	if cfg.WIF.GitLab.Enabled {
+		fmt.Fprintf(stdout, " WIF GitLab Project:      %s (pool %s, issuer %s)\n", cfg.WIF.GitLab.ProjectPath, cfg.WIF.PoolID, cfg.WIF.GitLab.IssuerURI)
+	}
+	if kc := cfg.SAKeyCleanup; kc.Enabled {
+		policy := fmt.Sprintf("older than %d days", kc.MaxAgeDays)
+		if kc.KeepNewest {
+			policy = "all but the newest"
+		}
+		fmt.Fprintf(stdout, " TF SA Key Cleanup:       %s\n", colorize(colorYellow, "delete keys "+policy))
+	}
+	if cfg.GitHubSecrets.Enabled {
+		fmt.Fprintf(stdout, " GitHub Secrets Repo:     %s\n", cfg.GitHubSecrets.Repository)
+	}
+	if cfg.OpsServiceAccount.Enabled {
+		fmt.Fprintf(stdout, " Ops Service Account:     %s\n", cfg.OpsServiceAccount.Email)
+		fmt.Fprintf(stdout, " Ops SA Project Roles:    %s\n", strings.Join(cfg.OpsServiceAccount.Roles, ", "))
+		if cfg.OpsServiceAccount.WIFPrincipal != "" {
+			fmt.Fprintf(stdout, " Ops SA WIF Principal:    %s\n", cfg.OpsServiceAccount.WIFPrincipal)
+		}
+	}
+	if bg := cfg.BreakGlass; bg.Enabled {
+		fmt.Fprintf(stdout, " Break-Glass Group:       %s\n", colorize(colorYellow, fmt.Sprintf("%s (%s for %s)", bg.Group, bg.Role, bg.Duration)))
+	}
+	if cfg.ProjectLien.Enabled {
+		fmt.Fprintf(stdout, " Project Lien:            %s\n", cfg.ProjectLien.Reason)
+	}
+	if cfg.Fleet.Enabled {
+		fmt.Fprintf(stdout, " Fleet Host Project:      %s\n", cfg.Fleet.HostProjectID)
+		fmt.Fprintf(stdout, " TF SA Fleet Host Roles:  %s\n", strings.Join(cfg.Fleet.TFSAHostRoles, ", "))
+	}
+	if len(cfg.EssentialContacts) > 0 {
+		fmt.Fprintf(stdout, " Essential Contacts:      %s\n", strings.Join(sortedKeys(contactCategories(cfg.EssentialContacts)), ", "))
+	}
+	if len(cfg.AuditLogs) > 0 {
+		fmt.Fprintf(stdout, " Audit Logs:              %s\n", strings.Join(sortedKeys(cfg.AuditLogs), ", "))
+	}
+	if cfg.DomainRestrictedSharing.Enabled {
+		fmt.Fprintf(stdout, " Allowed Member Domains:  %s\n", strings.Join(cfg.DomainRestrictedSharing.CustomerIDs, ", "))
+	}
+	if actions := cfg.OrgPolicies.actions(); len(actions) > 0 {
+		var constraints []string
+		for _, a := range actions {
+			constraints = append(constraints, strings.TrimPrefix(a.Constraint, "constraints/"))
+		}
+		fmt.Fprintf(stdout, " Org Policy Constraints:  %s\n", strings.Join(constraints, ", "))
+	}
+	if cfg.EnableFirebase {
+		fmt.Fprintln(stdout, " Firebase:                enabled")
+	}
+	if len(cfg.APIKeys) > 0 {
+		var ids []string
+		for _, k := range cfg.APIKeys {
+			ids = append(ids, k.ID)
+		}
+		fmt.Fprintf(stdout, " API Keys:                %s\n", strings.Join(ids, ", "))
+	}
+	if dsa := cfg.DefaultServiceAccounts; dsa.RemoveEditor || dsa.Disable {
+		fmt.Fprintf(stdout, " Default SAs:             remove editor %t, disable %t\n", dsa.RemoveEditor, dsa.Disable)
+	}
+	if len(cfg.LogSinks) > 0 {
+		var sinks []string
+		for _, s := range cfg.LogSinks {
+			sinks = append(sinks, fmt.Sprintf("%s -> %s", s.Name, s.Destination))
+		}
+		fmt.Fprintf(stdout, " Log Sinks:               %s\n", strings.Join(sinks, ", "))
+	}
+	if cfg.CMDB.Enabled {
+		fmt.Fprintf(stdout, " CMDB:                    %s (%s)\n", cfg.CMDB.URL, cfg.CMDB.Table)
+	}
+	if cfg.Backstage.Enabled {
+		fmt.Fprintf(stdout, " Backstage Entry:         %s (owner %s)\n", cfg.Backstage.Path, cfg.Backstage.Owner)
+	}
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+
+	if cfg.isProduction() {
+		if assumeYes && productionAck {
+			logWarning("Production config confirmed via --yes and --production-ack.")
+			return
+		}
+		if assumeYes {
+			logWarning("Production config: --yes alone is not sufficient, typed confirmation required (pass --production-ack to skip).")
+		}
+		fmt.Fprintf(stdout, "%s Type the project ID '%s' to proceed: ", colorize(colorBold+colorRed, "This is a PRODUCTION config."), cfg.ProjectID)
+		input, err := readConfirmation(ctx)
+		if err != nil {
+			exitCancelled(ctx)
+		}
+		if strings.TrimSpace(input) != cfg.ProjectID {
+			logInfo("Aborted by user.")
+			os.Exit(0)
+		}
+		logInfo("User confirmed. Starting bootstrap process...")
+		return
 	}
-	fmt.Println("-----------------------------------------------------")
 
-	fmt.Print("Proceed with bootstrapping using these settings? (yes/no): ")
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
+	if assumeYes {
+		logInfo("Confirmation skipped via --yes.")
+		return
+	}
+	fmt.Fprint(stdout, "Proceed with bootstrapping using these settings? (yes/no): ")
+	input, err := readConfirmation(ctx)
+	if err != nil {
+		exitCancelled(ctx)
+	}
 	if strings.TrimSpace(strings.ToLower(input)) != "yes" {
 		logInfo("Aborted by user.")
 		os.Exit(0)
 	}
 	logInfo("User confirmed. Starting bootstrap process...")
 }
+
+// readConfirmation reads a line from stdin, returning ctx's error if it is cancelled while
+// waiting so the caller can stop the way it would for any other cancellation
+func readConfirmation(ctx context.Context) (string, error) {
+	answer := make(chan string, 1)
+	go func() {
+		input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer <- input
+	}()
+	select {
+	case <-ctx.Done():
+		fmt.Fprintln(stdout)
+		return "", ctx.Err()
+	case input := <-answer:
+		return input, nil
+	}
+}
+
+// cancelExitCode returns the exit code for a cancelled ctx: 124 on timeout, 130 otherwise
+func cancelExitCode(ctx context.Context) int {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return exitCodeTimeout
+	}
+	return exitCodeInterrupted
+}
+
+// exitCancelled exits after a prompt was cancelled before the run started
+func exitCancelled(ctx context.Context) {
+	logInfo("Aborted by user.")
+	exitProcess(cancelExitCode(ctx))
+}
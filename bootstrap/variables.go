@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variablesKey is the top-level config key declaring the config's input variables
+const variablesKey = "variables"
+
+// Variable types; values given on the command line are converted to the declared type
+const (
+	variableTypeString = "string"
+	variableTypeNumber = "number"
+	variableTypeBool   = "bool"
+	variableTypeList   = "list"
+)
+
+var (
+	// variableRefPattern matches a {{var.NAME}} reference
+	variableRefPattern = regexp.MustCompile(`\{\{\s*var\.([A-Za-z0-9_-]*)\s*\}\}`)
+	// unquotedVariableRefPattern matches a reference that YAML would parse as a flow mapping
+	unquotedVariableRefPattern = regexp.MustCompile(`(?m)(:|^\s*-)\s+\{\{\s*var\.`)
+	variableNamePattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+)
+
+// Values of --var and --var-file, set by the flags of the bootstrap run and the subcommands
+// reading the config. Prompted values are added to configVariables so later loads reuse them.
+var (
+	configVariables = map[string]string{}
+	configVarFiles  []string
+	// variablePrompts is false where stdin belongs to someone else, e.g. the children of bulk
+	variablePrompts = true
+)
+
+// varFlag is the repeatable --var NAME=VALUE flag
+type varFlag struct{}
+
+func (varFlag) String() string { return "" }
+
+func (varFlag) Set(arg string) error {
+	name, value, ok := strings.Cut(arg, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=VALUE, got '%s'", arg)
+	}
+	configVariables[name] = value
+	return nil
+}
+
+// varFileFlag is the repeatable --var-file FILE flag
+type varFileFlag struct{}
+
+func (varFileFlag) String() string { return "" }
+
+func (varFileFlag) Set(path string) error {
+	configVarFiles = append(configVarFiles, path)
+	return nil
+}
+
+// addVariableFlags registers --var and --var-file on fs
+func addVariableFlags(fs *flag.FlagSet) {
+	fs.Var(varFlag{}, "var", "Set a variable declared under 'variables' in the config, e.g. region=europe-west3 (repeatable)")
+	fs.Var(varFileFlag{}, "var-file", "Read variable values from a YAML/JSON file of NAME: VALUE pairs (repeatable; later files win, --var wins over files)")
+}
+
+// variableArgs returns --var-file and --var arguments reproducing the variable flags, for
+// child processes
+func variableArgs() []string {
+	var args []string
+	for _, path := range configVarFiles {
+		args = append(args, "-var-file", path)
+	}
+	names := make([]string, 0, len(configVariables))
+	for name := range configVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-var", name+"="+configVariables[name])
+	}
+	return args
+}
+
+// checkVariableRefs rejects unquoted {{var.NAME}} references, which YAML would otherwise
+// report as a confusing "invalid map key" error
+func checkVariableRefs(doc []byte) error {
+	if unquotedVariableRefPattern.Match(doc) {
+		return fmt.Errorf("{{var.NAME}} references must be quoted, e.g. project_region: \"{{var.region}}\"")
+	}
+	return nil
+}
+
+// resolveVariables returns the config document with every {{var.NAME}} reference replaced
+// by the variable's value. A reference that is a whole YAML value takes the variable's type;
+// one inside a longer string is interpolated.
+func resolveVariables(doc []byte) ([]byte, error) {
+	root := map[string]any{}
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	raw, declared := root[variablesKey]
+	if !declared && !variableRefPattern.Match(doc) {
+		return doc, nil
+	}
+	var decls map[string]VariableConfig
+	if declared {
+		data, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &decls); err != nil {
+			return nil, fmt.Errorf("%s must be a mapping of variable name to type, default and description: %w", variablesKey, err)
+		}
+	}
+
+	values, err := variableValues(decls)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range root {
+		if key == variablesKey {
+			continue
+		}
+		if root[key], err = substituteVariables(value, values); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return yaml.Marshal(root)
+}
+
+// variableValues returns the value of each declared variable: its default, overridden by
+// the --var-file files in order and then by --var, or else prompted for on a terminal
+func variableValues(decls map[string]VariableConfig) (map[string]any, error) {
+	for name, decl := range decls {
+		if !variableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("'%s' is not a valid variable name", name)
+		}
+		switch decl.Type {
+		case "", variableTypeString, variableTypeNumber, variableTypeBool, variableTypeList:
+		default:
+			return nil, fmt.Errorf("variable '%s' has type '%s'; must be string, number, bool or list", name, decl.Type)
+		}
+	}
+
+	given := map[string]any{}
+	for _, path := range configVarFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading var file: %w", err)
+		}
+		var file map[string]any
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("error parsing var file %s: %w", path, err)
+		}
+		for name, value := range file {
+			if _, ok := decls[name]; !ok {
+				return nil, fmt.Errorf("var file %s sets '%s', which is not declared under %s", path, name, variablesKey)
+			}
+			given[name] = value
+		}
+	}
+	for name, value := range configVariables {
+		if _, ok := decls[name]; !ok {
+			return nil, fmt.Errorf("--var %s: '%s' is not declared under %s", name, name, variablesKey)
+		}
+		given[name] = value
+	}
+
+	names := make([]string, 0, len(decls))
+	for name := range decls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := map[string]any{}
+	var reader *bufio.Reader
+	for _, name := range names {
+		decl := decls[name]
+		value, ok := given[name]
+		if !ok {
+			value, ok = decl.Default, decl.Default != nil
+		}
+		if !ok {
+			if !variablePrompts || !isTerminal(os.Stdin) {
+				return nil, fmt.Errorf("variable '%s' has no value; pass --var %s=VALUE or set it in a --var-file", name, name)
+			}
+			if reader == nil {
+				reader = bufio.NewReader(os.Stdin)
+			}
+			v, err := promptVariable(reader, name, decl)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = v
+			continue
+		}
+		v, err := convertVariable(decl, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable '%s': %w", name, err)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// promptVariable asks for the value of a variable until it converts to the declared type,
+// and remembers the answer for later config loads of this run
+func promptVariable(reader *bufio.Reader, name string, decl VariableConfig) (any, error) {
+	fmt.Fprintf(stdout, "var.%s\n", name)
+	if decl.Description != "" {
+		fmt.Fprintf(stdout, "  %s\n", decl.Description)
+	}
+	for {
+		fmt.Fprintf(stdout, "  Enter a value (%s): ", decl.typeName())
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("no value entered for variable '%s'; pass --var %s=VALUE", name, name)
+		}
+		input = strings.TrimRight(input, "\r\n")
+		v, err := convertVariable(decl, input)
+		if err != nil {
+			fmt.Fprintf(stdout, "  %v\n", err)
+			continue
+		}
+		configVariables[name] = input
+		return v, nil
+	}
+}
+
+// typeName returns the declared type, defaulting to string
+func (v VariableConfig) typeName() string {
+	if v.Type == "" {
+		return variableTypeString
+	}
+	return v.Type
+}
+
+// convertVariable converts a value from the config, a var file or the command line to the
+// declared type. Command-line values are strings and are parsed as YAML for non-string types.
+func convertVariable(decl VariableConfig, value any) (any, error) {
+	s, isString := value.(string)
+	switch decl.typeName() {
+	case variableTypeString:
+		switch value.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("expected a string, got %v", value)
+		}
+		return fmt.Sprint(value), nil
+	case variableTypeNumber:
+		if isString {
+			n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected a number, got '%s'", s)
+			}
+			if n == float64(int64(n)) {
+				return int64(n), nil
+			}
+			return n, nil
+		}
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return value, nil
+		}
+		return nil, fmt.Errorf("expected a number, got %v", value)
+	case variableTypeBool:
+		if isString {
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("expected true or false, got '%s'", s)
+			}
+			return b, nil
+		}
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected true or false, got %v", value)
+	default:
+		if isString {
+			// Accept YAML/JSON lists ([a, b]) as well as plain comma-separated values
+			var list []any
+			if err := yaml.Unmarshal([]byte(s), &list); err == nil && strings.HasPrefix(strings.TrimSpace(s), "[") {
+				return list, nil
+			}
+			list = nil
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list, nil
+		}
+		if list, ok := value.([]any); ok {
+			return list, nil
+		}
+		return nil, fmt.Errorf("expected a list, got %v", value)
+	}
+}
+
+// substituteVariables replaces the {{var.NAME}} references in the strings of value
+func substituteVariables(value any, values map[string]any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			sub, err := substituteVariables(item, values)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = sub
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			sub, err := substituteVariables(item, values)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			v[i] = sub
+		}
+		return v, nil
+	case string:
+		return interpolateVariables(v, values)
+	}
+	return value, nil
+}
+
+// interpolateVariables resolves the references in s; a string that is just one reference
+// takes the variable's value with its type
+func interpolateVariables(s string, values map[string]any) (any, error) {
+	matches := variableRefPattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s, nil
+	}
+	for _, m := range matches {
+		if _, ok := values[s[m[2]:m[3]]]; !ok {
+			return nil, fmt.Errorf("reference to undeclared variable '%s'; declare it under %s", s[m[2]:m[3]], variablesKey)
+		}
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return values[s[matches[0][2]:matches[0][3]]], nil
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		value := values[s[m[2]:m[3]]]
+		if _, ok := value.([]any); ok {
+			return nil, fmt.Errorf("list variable '%s' cannot be used inside a string", s[m[2]:m[3]])
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(fmt.Sprint(value))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// wifRequiredAPIs must be enabled for Workload Identity Federation token exchange
+var wifRequiredAPIs = []string{"iamcredentials.googleapis.com", "sts.googleapis.com"}
+
+// githubIssuerURI issues GitHub Actions OIDC tokens
+const githubIssuerURI = "https://token.actions.githubusercontent.com"
+
+// githubAttributeMapping maps GitHub Actions OIDC token claims to Google attributes
+var githubAttributeMapping = map[string]string{
+	"google.subject":             "assertion.sub",
+	"attribute.repository":       "assertion.repository",
+	"attribute.repository_owner": "assertion.repository_owner",
+	"attribute.ref":              "assertion.ref",
+}
+
+// gitlabAttributeMapping maps GitLab CI id_token claims to Google attributes
+var gitlabAttributeMapping = map[string]string{
+	"google.subject":           "assertion.sub",
+	"attribute.project_path":   "assertion.project_path",
+	"attribute.namespace_path": "assertion.namespace_path",
+	"attribute.ref":            "assertion.ref",
+	"attribute.ref_type":       "assertion.ref_type",
+}
+
+// terraformCloudAttributeMapping maps Terraform Cloud workload identity token claims to Google attributes
+var terraformCloudAttributeMapping = map[string]string{
+	"google.subject":                        "assertion.sub",
+	"attribute.terraform_organization_name": "assertion.terraform_organization_name",
+	"attribute.terraform_project_name":      "assertion.terraform_project_name",
+	"attribute.terraform_workspace_name":    "assertion.terraform_workspace_name",
+	"attribute.terraform_run_phase":         "assertion.terraform_run_phase",
+}
+
+// oidcProvider describes a workload identity pool OIDC provider to create or update
+type oidcProvider struct {
+	ID               string
+	DisplayName      string
+	IssuerURI        string
+	AllowedAudiences []string
+	AttributeMapping map[string]string
+	Condition        string
+}
+
+// setupWIF creates the workload identity pool and the configured CI providers and
+// allows their identities to impersonate the Terraform SA
+func setupWIF(ctx context.Context, cfg *Config) error {
+	if !cfg.WIF.enabled() {
+		logInfo("Skipping Workload Identity Federation setup as per config.")
+		return nil
+	}
+	if err := ensureServicesEnabled(ctx, cfg.seedProject(), wifRequiredAPIs); err != nil {
+		return fmt.Errorf("failed to enable Workload Identity Federation APIs: %w", err)
+	}
+	if err := ensureWorkloadIdentityPool(ctx, cfg.seedProject(), cfg.WIF.PoolID); err != nil {
+		return err
+	}
+	number, err := projectNumber(ctx, cfg.seedProject())
+	if err != nil {
+		return err
+	}
+	poolName := fmt.Sprintf("projects/%s/locations/global/workloadIdentityPools/%s", number, cfg.WIF.PoolID)
+
+	if gh := cfg.WIF.GitHub; gh.Enabled {
+		provider := oidcProvider{
+			ID:               gh.ProviderID,
+			DisplayName:      "GitHub Actions",
+			IssuerURI:        githubIssuerURI,
+			AttributeMapping: githubAttributeMapping,
+			Condition:        githubAttributeCondition(gh),
+		}
+		if err := ensureOIDCProvider(ctx, cfg.seedProject(), cfg.WIF.PoolID, provider); err != nil {
+			return err
+		}
+		principal := fmt.Sprintf("principalSet://iam.googleapis.com/%s/attribute.repository/%s", poolName, gh.Repository)
+		if err := bindWorkloadIdentityUser(ctx, cfg.seedProject(), cfg.TFServiceAccountEmail, principal); err != nil {
+			return err
+		}
+		logNotice("GitHub Actions federation ready. Use with google-github-actions/auth: workload_identity_provider=%s/providers/%s service_account=%s", poolName, gh.ProviderID, cfg.TFServiceAccountEmail)
+	}
+
+	if gl := cfg.WIF.GitLab; gl.Enabled {
+		provider := oidcProvider{
+			ID:               gl.ProviderID,
+			DisplayName:      "GitLab CI",
+			IssuerURI:        gl.IssuerURI,
+			AllowedAudiences: gl.AllowedAudiences,
+			AttributeMapping: gitlabAttributeMapping,
+			Condition:        gitlabAttributeCondition(gl),
+		}
+		if err := ensureOIDCProvider(ctx, cfg.seedProject(), cfg.WIF.PoolID, provider); err != nil {
+			return err
+		}
+		principal := fmt.Sprintf("principalSet://iam.googleapis.com/%s/attribute.project_path/%s", poolName, gl.ProjectPath)
+		if err := bindWorkloadIdentityUser(ctx, cfg.seedProject(), cfg.TFServiceAccountEmail, principal); err != nil {
+			return err
+		}
+		logNotice("GitLab CI federation ready. Use in .gitlab-ci.yml: GCP_WORKLOAD_IDENTITY_PROVIDER=%s/providers/%s GCP_SERVICE_ACCOUNT=%s", poolName, gl.ProviderID, cfg.TFServiceAccountEmail)
+	}
+
+	if tfc := cfg.WIF.TerraformCloud; tfc.Enabled {
+		provider := oidcProvider{
+			ID:               tfc.ProviderID,
+			DisplayName:      "Terraform Cloud",
+			IssuerURI:        tfc.IssuerURI,
+			AttributeMapping: terraformCloudAttributeMapping,
+			Condition:        terraformCloudAttributeCondition(tfc),
+		}
+		if err := ensureOIDCProvider(ctx, cfg.seedProject(), cfg.WIF.PoolID, provider); err != nil {
+			return err
+		}
+		principal := fmt.Sprintf("principalSet://iam.googleapis.com/%s/attribute.terraform_organization_name/%s", poolName, tfc.Organization)
+		if err := bindWorkloadIdentityUser(ctx, cfg.seedProject(), cfg.TFServiceAccountEmail, principal); err != nil {
+			return err
+		}
+		logNotice("Terraform Cloud dynamic credentials ready.")
+		printTerraformCloudVariables(cfg, number)
+	}
+
+	for _, pc := range cfg.WIF.Providers {
+		provider := oidcProvider{
+			ID:               pc.ID,
+			DisplayName:      pc.DisplayName,
+			IssuerURI:        pc.IssuerURI,
+			AllowedAudiences: pc.AllowedAudiences,
+			AttributeMapping: pc.AttributeMapping,
+			Condition:        pc.AttributeCondition,
+		}
+		if err := ensureOIDCProvider(ctx, cfg.seedProject(), cfg.WIF.PoolID, provider); err != nil {
+			return err
+		}
+		for _, sa := range pc.ServiceAccounts {
+			for _, p := range pc.Principals {
+				if err := bindWorkloadIdentityUser(ctx, cfg.seedProject(), sa, expandWIFPrincipal(poolName, p)); err != nil {
+					return err
+				}
+			}
+		}
+		logNotice("OIDC provider '%s' ready: %s/providers/%s", pc.ID, poolName, pc.ID)
+	}
+	return nil
+}
+
+// printTerraformCloudVariables prints the workspace environment variables that enable
+// dynamic GCP credentials on the Terraform Cloud side
+func printTerraformCloudVariables(cfg *Config, projectNumber string) {
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+	fmt.Fprintln(stdout, " Set these environment variables on your Terraform Cloud workspace (or variable set):")
+	fmt.Fprintln(stdout, "  TFC_GCP_PROVIDER_AUTH=true")
+	fmt.Fprintf(stdout, "  TFC_GCP_RUN_SERVICE_ACCOUNT_EMAIL=%s\n", cfg.TFServiceAccountEmail)
+	fmt.Fprintf(stdout, "  TFC_GCP_PROJECT_NUMBER=%s\n", projectNumber)
+	fmt.Fprintf(stdout, "  TFC_GCP_WORKLOAD_POOL_ID=%s\n", cfg.WIF.PoolID)
+	fmt.Fprintf(stdout, "  TFC_GCP_WORKLOAD_PROVIDER_ID=%s\n", cfg.WIF.TerraformCloud.ProviderID)
+	fmt.Fprintln(stdout, "-----------------------------------------------------")
+}
+
+// ensureWorkloadIdentityPool creates the global workload identity pool if it does not exist
+func ensureWorkloadIdentityPool(ctx context.Context, projectID, poolID string) error {
+	logInfo("Ensuring workload identity pool '%s'...", poolID)
+	err := runCommand(ctx, "gcloud", "iam", "workload-identity-pools", "create", poolID,
+		"--location", "global",
+		"--display-name", "CI/CD Workload Identity Pool",
+		"--project", projectID)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "ALREADY_EXISTS") {
+			logInfo("Workload identity pool '%s' already exists.", poolID)
+			metrics.recordResource("workload_identity_pool", poolID, resourceExisted)
+			return nil
+		}
+		return fmt.Errorf("failed to create workload identity pool: %w", err)
+	}
+	metrics.recordResource("workload_identity_pool", poolID, resourceCreated)
+	return nil
+}
+
+// ensureOIDCProvider creates the OIDC provider, or updates it to match config if it already exists
+func ensureOIDCProvider(ctx context.Context, projectID, poolID string, p oidcProvider) error {
+	logInfo("Ensuring OIDC provider '%s' (issuer %s)...", p.ID, p.IssuerURI)
+	args := []string{"iam", "workload-identity-pools", "providers", "create-oidc", p.ID}
+	args = append(args, oidcProviderFlags(projectID, poolID, p)...)
+	err := runCommand(ctx, "gcloud", args...)
+	if err == nil {
+		metrics.recordResource("workload_identity_provider", p.ID, resourceCreated)
+		return nil
+	}
+	if !strings.Contains(err.Error(), "already exists") && !strings.Contains(err.Error(), "ALREADY_EXISTS") {
+		return fmt.Errorf("failed to create OIDC provider '%s': %w", p.ID, err)
+	}
+	logInfo("OIDC provider '%s' already exists, reconciling its settings...", p.ID)
+	args = []string{"iam", "workload-identity-pools", "providers", "update-oidc", p.ID}
+	args = append(args, oidcProviderFlags(projectID, poolID, p)...)
+	if err := runCommand(ctx, "gcloud", args...); err != nil {
+		return fmt.Errorf("failed to update OIDC provider '%s': %w", p.ID, err)
+	}
+	metrics.recordResource("workload_identity_provider", p.ID, resourceExisted)
+	return nil
+}
+
+// oidcProviderFlags returns the gcloud flags shared by create-oidc and update-oidc
+func oidcProviderFlags(projectID, poolID string, p oidcProvider) []string {
+	flags := []string{
+		"--location", "global",
+		"--workload-identity-pool", poolID,
+		"--display-name", p.DisplayName,
+		"--issuer-uri", p.IssuerURI,
+		"--attribute-mapping", formatAttributeMapping(p.AttributeMapping),
+		"--project", projectID,
+	}
+	if len(p.AllowedAudiences) > 0 {
+		flags = append(flags, "--allowed-audiences", strings.Join(p.AllowedAudiences, ","))
+	}
+	if p.Condition != "" {
+		flags = append(flags, "--attribute-condition", p.Condition)
+	}
+	return flags
+}
+
+// formatAttributeMapping renders a mapping as the comma-separated list gcloud expects, in stable order
+func formatAttributeMapping(mapping map[string]string) string {
+	keys := make([]string, 0, len(mapping))
+	for k := range mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+mapping[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// bindWorkloadIdentityUser allows a federated principal to impersonate the service account saEmail
+func bindWorkloadIdentityUser(ctx context.Context, projectID, saEmail, principal string) error {
+	logInfo("Allowing '%s' to impersonate '%s'...", principal, saEmail)
+	err := runCommand(ctx, "gcloud", "iam", "service-accounts", "add-iam-policy-binding", saEmail,
+		"--member", principal,
+		"--role", "roles/iam.workloadIdentityUser",
+		"--project", projectID)
+	if err != nil {
+		return fmt.Errorf("failed to bind federated principal to '%s': %w", saEmail, err)
+	}
+	return nil
+}
+
+// expandWIFPrincipal turns a pool-relative principal ("subject/...", "attribute.X/...", "*")
+// into a full principal URI; full URIs are returned unchanged
+func expandWIFPrincipal(poolName, p string) string {
+	switch {
+	case strings.HasPrefix(p, "principal://"), strings.HasPrefix(p, "principalSet://"):
+		return p
+	case strings.HasPrefix(p, "subject/"):
+		return fmt.Sprintf("principal://iam.googleapis.com/%s/%s", poolName, p)
+	case p == "*":
+		return fmt.Sprintf("principalSet://iam.googleapis.com/%s/*", poolName)
+	default:
+		return fmt.Sprintf("principalSet://iam.googleapis.com/%s/%s", poolName, p)
+	}
+}
+
+// validateOIDCProviders checks the generic wif.providers entries and applies defaults
+func validateOIDCProviders(cfg *Config) error {
+	seen := map[string]bool{}
+	if cfg.WIF.GitHub.Enabled {
+		seen[cfg.WIF.GitHub.ProviderID] = true
+	}
+	if cfg.WIF.GitLab.Enabled {
+		seen[cfg.WIF.GitLab.ProviderID] = true
+	}
+	if cfg.WIF.TerraformCloud.Enabled {
+		seen[cfg.WIF.TerraformCloud.ProviderID] = true
+	}
+	for i := range cfg.WIF.Providers {
+		pc := &cfg.WIF.Providers[i]
+		if pc.ID == "" {
+			return fmt.Errorf("entry %d has no id", i+1)
+		}
+		if seen[pc.ID] {
+			return fmt.Errorf("provider id '%s' is used more than once", pc.ID)
+		}
+		seen[pc.ID] = true
+		if pc.IssuerURI == "" {
+			return fmt.Errorf("provider '%s' has no issuer_uri", pc.ID)
+		}
+		if _, ok := pc.AttributeMapping["google.subject"]; !ok {
+			return fmt.Errorf("provider '%s' attribute_mapping must map google.subject", pc.ID)
+		}
+		if len(pc.Principals) == 0 {
+			return fmt.Errorf("provider '%s' has no principals allowed to impersonate", pc.ID)
+		}
+		for _, p := range pc.Principals {
+			if !strings.HasPrefix(p, "principal://") && !strings.HasPrefix(p, "principalSet://") &&
+				!strings.HasPrefix(p, "subject/") && !strings.HasPrefix(p, "attribute.") && p != "*" {
+				return fmt.Errorf("provider '%s' principal '%s' must be 'subject/VALUE', 'attribute.NAME/VALUE', '*' or a full principal URI", pc.ID, p)
+			}
+		}
+		if pc.DisplayName == "" {
+			pc.DisplayName = pc.ID
+		}
+		if len(pc.ServiceAccounts) == 0 {
+			pc.ServiceAccounts = []string{cfg.TFServiceAccountEmail}
+		}
+	}
+	return nil
+}
+
+// githubAttributeCondition restricts tokens to the configured repository
+func githubAttributeCondition(gh GitHubWIFConfig) string {
+	return fmt.Sprintf("assertion.repository=='%s'", gh.Repository)
+}
+
+// gitlabAttributeCondition restricts tokens to the configured project (and ref, if set)
+func gitlabAttributeCondition(gl GitLabWIFConfig) string {
+	condition := fmt.Sprintf("assertion.project_path=='%s'", gl.ProjectPath)
+	if gl.Ref != "" {
+		condition += fmt.Sprintf(" && assertion.ref=='%s'", gl.Ref)
+	}
+	return condition
+}
+
+// terraformCloudAttributeCondition restricts tokens to the configured organization (and project/workspace, if set)
+func terraformCloudAttributeCondition(tfc TerraformCloudWIFConfig) string {
+	condition := fmt.Sprintf("assertion.terraform_organization_name=='%s'", tfc.Organization)
+	if tfc.Project != "" {
+		condition += fmt.Sprintf(" && assertion.terraform_project_name=='%s'", tfc.Project)
+	}
+	if tfc.Workspace != "" {
+		condition += fmt.Sprintf(" && assertion.terraform_workspace_name=='%s'", tfc.Workspace)
+	}
+	return condition
+}